@@ -31,5 +31,5 @@ var (
 )
 
 func init() {
-	SchemeBuilder.Register(&Runner{}, &RunnerList{})
+	SchemeBuilder.Register(&Runner{}, &RunnerList{}, &RunnerClass{}, &RunnerClassList{}, &RunnerScaler{}, &RunnerScalerList{})
 }
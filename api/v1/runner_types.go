@@ -1,6 +1,7 @@
 package v1
 
 import (
+	appsV1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -12,12 +13,198 @@ type RunnerSpec struct {
 	// GitHub Repository Name to use runner
 	// +kubebuilder:validation:XValidation:rule="self.find('[^/]+/[^/]+') != ''",message="must be /[^\\/]+\\/[^\\/]+/"
 	Repository string `json:"repository"`
+	// Pattern of the URL the runner registers with, where %s is replaced with the repository,
+	// e.g. https://github.example.com/%s for GitHub Enterprise Server.
+	// Defaults to https://github.com/%s.
+	// +optional
+	RegistrationURLPattern string `json:"registrationURLPattern,omitempty"`
 	// Selects a key of a GitHub Token secret in the runner's namespace
-	TokenSecretKeyRef    *v1.SecretKeySelector `json:"tokenSecretKeyRef,omitempty"`
-	AppSecretRef         *v1.SecretEnvSource   `json:"appSecretRef,omitempty"`
-	Template             Template              `json:"template,omitempty"`
-	BuilderContainerSpec BuilderContainerSpec  `json:"builderContainerSpec,omitempty"`
-	RunnerContainerSpec  RunnerContainerSpec   `json:"runnerContainerSpec,omitempty"`
+	TokenSecretKeyRef *v1.SecretKeySelector `json:"tokenSecretKeyRef,omitempty"`
+	// GitHub Personal Access Token used to register runner.
+	// The referenced secret is validated at reconciliation.
+	// TokenSecretKeyRef takes precedence when both are specified.
+	// Ignored when AppSecretRef is specified.
+	// +optional
+	PersonalAccessTokenRef *PersonalAccessTokenRef `json:"personalAccessTokenRef,omitempty"`
+	AppSecretRef           *v1.SecretEnvSource     `json:"appSecretRef,omitempty"`
+	Template               Template                `json:"template,omitempty"`
+	BuilderContainerSpec   BuilderContainerSpec    `json:"builderContainerSpec,omitempty"`
+	RunnerContainerSpec    RunnerContainerSpec     `json:"runnerContainerSpec,omitempty"`
+	// Permissions of the token issued by the GitHub App configured at the controller, e.g. to reduce its scope.
+	// Defaults to actions: read, administration: write, and metadata: read, which registering repository runners requires.
+	// +optional
+	RequiredPermissions map[string]string `json:"requiredPermissions,omitempty"`
+	// Selects a key of a GitHub App installation ID secret in the runner's namespace.
+	// Used to issue the token with the GitHub App configured at the controller,
+	// and takes precedence over the installation ID configured at the controller.
+	// +optional
+	AppInstallationSecretRef *v1.SecretKeySelector `json:"appInstallationSecretRef,omitempty"`
+	// Share the token secret issued by the GitHub App configured at the controller with other runners
+	// of the same repository and required permissions in the namespace, instead of issuing one per runner.
+	// +optional
+	SharedTokenSecret bool `json:"sharedTokenSecret,omitempty"`
+	// PersistentVolumeClaim spec used as the writable workspace storing the generated Dockerfile instead of a ConfigMap.
+	// The Dockerfile is written into it by the workspace init container from its arguments,
+	// so the Deployment still carries the whole Dockerfile and is subject to the size limit of objects.
+	// +optional
+	WorkspacePVC *v1.PersistentVolumeClaimSpec `json:"workspacePVC,omitempty"`
+	// Store the generated Dockerfile in a Secret instead of a ConfigMap,
+	// so that the image embedded in it is visible only to those allowed to read secrets.
+	// Ignored when workspacePVC is specified.
+	// +optional
+	UseSecretForDockerfile bool `json:"useSecretForDockerfile,omitempty"`
+	// Proxy settings injected into builder and runner containers
+	// +optional
+	ProxySettings *ProxySettings `json:"proxySettings,omitempty"`
+	// Selects a key of a custom CA certificate bundle secret in the runner's namespace.
+	// The bundle is trusted by both builder and runner containers.
+	// +optional
+	CACertSecretRef *v1.SecretKeySelector `json:"caCertSecretRef,omitempty"`
+	// Secrets mounted into the runner container as files, e.g. kubeconfig, .npmrc or pip.conf.
+	// Mount paths must not conflict with /workspace reserved by the controller.
+	// +optional
+	SecretVolumeMounts []SecretVolumeMount `json:"secretVolumeMounts,omitempty"`
+	// Duration in seconds the runner pod needs to terminate gracefully.
+	// Defaults to 30 seconds.
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// Number of desired runner pods.
+	// Defaults to 1 on creation, and replicas changed externally (e.g. by HPA) are kept when unspecified.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Number of runner processes registered in each runner pod.
+	// When greater than 1, the runner container runs /usr/local/bin/runner as many times with hostnames suffixed by their index.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RunnerProcesses int `json:"runnerProcesses,omitempty"`
+	// Inject NODE_NAME, NAMESPACE and POD_IP of the runner pod into the runner container by Downward API.
+	// Useful for jobs to know where they are running for debugging.
+	// +optional
+	InjectPodMetadata bool `json:"injectPodMetadata,omitempty"`
+	// Name of the scheduler dispatching runner pods, e.g. a custom batch scheduler.
+	// Defaults to the one configured at the controller, or the default scheduler of Kubernetes.
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+	// Whether to omit the anti-affinity spreading runner pods across nodes, e.g. in single-node development clusters.
+	// +optional
+	DisablePodAntiAffinity bool `json:"disablePodAntiAffinity,omitempty"`
+	// Interval at which the runner is reconciled even without changes, to repair drift such as a manually deleted deployment.
+	// Disabled by default to avoid unnecessary API calls in large clusters.
+	// +optional
+	PeriodicResyncInterval *metaV1.Duration `json:"periodicResyncInterval,omitempty"`
+	// Labels added to the resources created for the runner, i.e. the deployment, the token secret, and the workspace.
+	// Labels set by the controller take precedence.
+	// +optional
+	ManagedResourceLabels map[string]string `json:"managedResourceLabels,omitempty"`
+	// Annotations added to the resources created for the runner, i.e. the deployment, the token secret, and the workspace.
+	// Annotations set by the controller take precedence.
+	// +optional
+	ManagedResourceAnnotations map[string]string `json:"managedResourceAnnotations,omitempty"`
+	// Additional Spec for exporter container.
+	// Used only when runner metrics are enabled.
+	// +optional
+	ExporterContainerSpec *ContainerSpec `json:"exporterContainerSpec,omitempty"`
+	// Type of deployment strategy used to replace runner pods.
+	// Defaults to RollingUpdate.
+	// +kubebuilder:validation:Enum=RollingUpdate;Recreate
+	// +optional
+	DeploymentStrategy *appsV1.DeploymentStrategyType `json:"deploymentStrategy,omitempty"`
+	// Rolling update parameters used when deploymentStrategy is RollingUpdate.
+	// Defaults to maxSurge 1 and maxUnavailable 0 for a single replica so that a runner is always available,
+	// and maxSurge 25% and maxUnavailable 1 otherwise.
+	// +optional
+	RollingUpdateStrategy *appsV1.RollingUpdateDeployment `json:"rollingUpdateStrategy,omitempty"`
+	// Skip building runner image by the builder container, and use the image already pushed to the registry.
+	// The image is identified by the hash of image, binary version and runner version,
+	// so it is valid only if a runner with the same image has been built by the controller with the same versions.
+	// +optional
+	SkipBuild bool `json:"skipBuild,omitempty"`
+	// Pre-built runner image used instead of building by the builder container.
+	// The image must contain the runner binary as its entrypoint like the one built by the controller.
+	// +optional
+	PreBuiltImage string `json:"preBuiltImage,omitempty"`
+	// Namespace where the runner image is built by a job instead of the init container of runner pods,
+	// to isolate the privileged builder container from the namespace of the runner.
+	// Runner pods are rolled out once the job completes, and volumes mounted by the builder container must exist in the namespace.
+	// +optional
+	BuildNamespace string `json:"buildNamespace,omitempty"`
+	// Service account of the build job in the build namespace, e.g. to authenticate the builder container to registries
+	// by IAM Roles for Service Accounts or Workload Identity instead of credentials mounted into it.
+	// Requires BuildNamespace since the builder container shares the service account of runner pods otherwise.
+	// +optional
+	BuilderServiceAccountName string `json:"builderServiceAccountName,omitempty"`
+	// Skip the warm-up job, which builds the runner image without pushing it before the deployment of a new runner is created
+	// so that the layer cache in the registry is filled before runner pods start.
+	// The warm-up job is not run when the build is skipped.
+	// +optional
+	SkipWarmup bool `json:"skipWarmup,omitempty"`
+	// Pin the runner image to the digest its tag points to at reconciliation, so that pods are rolled out when the tag is moved.
+	// Requires skipBuild or preBuiltImage since images built by runner pods change on every start.
+	// +optional
+	PinImageDigest bool `json:"pinImageDigest,omitempty"`
+	// Architecture of the runner binary installed into runner image.
+	// Defaults to the one configured at the controller.
+	// +kubebuilder:validation:Enum=amd64;arm64
+	// +optional
+	BinaryArch string `json:"binaryArch,omitempty"`
+	// UID of the runner user created in runner image and used by the runner container.
+	// Must be greater than 999 to avoid system users. Defaults to 60000.
+	// +kubebuilder:validation:Minimum=1000
+	// +optional
+	RunnerUID *int64 `json:"runnerUID,omitempty"`
+	// GID of the runner group created in runner image and used by the runner container.
+	// Must be greater than 999 to avoid system groups. Defaults to 60000.
+	// +kubebuilder:validation:Minimum=1000
+	// +optional
+	RunnerGID *int64 `json:"runnerGID,omitempty"`
+	// Preset of the security context of the runner container named after Pod Security Standards.
+	// Fields of runnerContainerSpec.securityContext take precedence over it. Defaults to baseline.
+	// +optional
+	SecurityProfile SecurityProfile `json:"securityProfile,omitempty"`
+	// A special supplemental group that applies to all containers in the runner pod.
+	// Volumes supporting ownership management are owned by this group.
+	// +optional
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+	// A list of groups applied to the first process run in each container of the runner pod,
+	// in addition to the container's primary GID.
+	// +optional
+	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
+}
+
+// PersonalAccessTokenRef defines GitHub Personal Access Token credential
+type PersonalAccessTokenRef struct {
+	// Selects a key of a GitHub Personal Access Token secret in the runner's namespace
+	SecretRef v1.SecretKeySelector `json:"secretRef"`
+	// Scopes granted to the Personal Access Token
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// SecretVolumeMount defines a secret mounted into the runner container as files
+type SecretVolumeMount struct {
+	// Name of the secret in the runner's namespace
+	SecretName string `json:"secretName"`
+	// Path within the runner container at which the secret is mounted read-only
+	MountPath string `json:"mountPath"`
+	// Keys of the secret projected into files under the mount path.
+	// All keys are projected with their names when unspecified.
+	// +optional
+	Items []v1.KeyToPath `json:"items,omitempty"`
+}
+
+// ProxySettings defines HTTP proxy settings used by builder and runner containers
+type ProxySettings struct {
+	// Value of HTTP_PROXY environment variable
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+	// Value of HTTPS_PROXY environment variable
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	// Value of NO_PROXY environment variable.
+	// Cluster-internal addresses are always appended.
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
 }
 
 // Template defines the pod template generated by runner
@@ -36,6 +223,35 @@ type Spec struct {
 	// +patchMergeKey=name
 	// +patchStrategy=merge,retainKeys
 	Volumes []v1.Volume `json:"volumes,omitempty" patchStrategy:"merge,retainKeys" patchMergeKey:"name" protobuf:"bytes,1,rep,name=volumes"`
+	// List of sidecar containers added after the containers managed by the controller.
+	// Names "runner" and "exporter" are reserved.
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Containers []v1.Container `json:"containers,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,2,rep,name=containers"`
+	// List of init containers run before the builder container.
+	// Names "workspace" and "kaniko" are reserved.
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	InitContainers []v1.Container `json:"initContainers,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,3,rep,name=initContainers"`
+	// HostAliases is an optional list of hosts and IPs that will be injected into the pod's hosts
+	// file if specified.
+	// +optional
+	// +patchMergeKey=ip
+	// +patchStrategy=merge
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty" patchStrategy:"merge" patchMergeKey:"ip" protobuf:"bytes,4,rep,name=hostAliases"`
+	// Set DNS policy for the pod.
+	// Defaults to "ClusterFirst".
+	// Valid values are 'ClusterFirstWithHostNet', 'ClusterFirst', 'Default' or 'None'.
+	// DNS parameters given in DNSConfig will be merged with the policy selected with DNSPolicy.
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	// +optional
+	DNSPolicy *v1.DNSPolicy `json:"dnsPolicy,omitempty" protobuf:"bytes,5,opt,name=dnsPolicy,casttype=DNSPolicy"`
+	// Specifies the DNS parameters of a pod.
+	// Parameters specified here will be merged to the generated DNS
+	// configuration based on DNSPolicy.
+	// At least one nameserver is required when DNSPolicy is None.
+	// +optional
+	DNSConfig *v1.PodDNSConfig `json:"dnsConfig,omitempty" protobuf:"bytes,6,opt,name=dnsConfig"`
 }
 
 // Additional Spec for builder container.
@@ -49,6 +265,7 @@ type BuilderContainerSpec struct {
 	// +optional
 	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty" protobuf:"bytes,19,rep,name=envFrom"`
 	// List of environment variables to set in the runner container.
+	// Variables with the same name as the global ones configured at the controller take precedence.
 	// +patchMergeKey=name
 	// +patchStrategy=merge
 	Env []v1.EnvVar `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=env"`
@@ -60,6 +277,40 @@ type BuilderContainerSpec struct {
 	// +patchMergeKey=mountPath
 	// +patchStrategy=merge
 	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty" patchStrategy:"merge" patchMergeKey:"mountPath" protobuf:"bytes,9,rep,name=volumeMounts"`
+	// Mirrors of Docker Hub used to pull base images, in the form of host[:port][/path] such as mirror.gcr.io.
+	// They are tried in order before Docker Hub.
+	// +optional
+	RegistryMirrors []string `json:"registryMirrors,omitempty"`
+	// Registries in the form of host[:port] accessed over plain HTTP, e.g. private mirrors without TLS.
+	// +optional
+	InsecureRegistries []string `json:"insecureRegistries,omitempty"`
+	// Whether to push the built image to the push registry of the controller over plain HTTP.
+	// +optional
+	InsecurePushRegistry bool `json:"insecurePushRegistry,omitempty"`
+	// Whether to skip TLS verification of the push registry, e.g. with a self-signed certificate.
+	// +optional
+	SkipTLSVerifyPush bool `json:"skipTLSVerifyPush,omitempty"`
+	// Whether to pull base images over plain HTTP.
+	// +optional
+	InsecurePullRegistry bool `json:"insecurePullRegistry,omitempty"`
+	// Whether to skip TLS verification of registries base images are pulled from.
+	// +optional
+	SkipTLSVerifyPull bool `json:"skipTLSVerifyPull,omitempty"`
+	// Path of the file the termination message of the container is written to.
+	// Defaults to /dev/termination-log.
+	// +optional
+	TerminationMessagePath string `json:"terminationMessagePath,omitempty"`
+	// How the termination message of the container is populated.
+	// FallbackToLogsOnError uses the last lines of the log when the message file is empty and the container fails, which helps debugging failed starts.
+	// Defaults to File.
+	// +kubebuilder:validation:Enum=File;FallbackToLogsOnError
+	// +optional
+	TerminationMessagePolicy *v1.TerminationMessagePolicy `json:"terminationMessagePolicy,omitempty"`
+	// Maximum duration of the build, after which the builder container is killed and fails, e.g. on a hung network call.
+	// The container is run by a shell wrapping the executor, so the kaniko image of the controller must have one, e.g. the debug variant.
+	// Build and warm-up jobs are also bounded by it as activeDeadlineSeconds.
+	// +optional
+	BuildTimeout *metaV1.Duration `json:"buildTimeout,omitempty"`
 }
 
 // Additional Spec for runner container.
@@ -84,12 +335,134 @@ type RunnerContainerSpec struct {
 	// +patchMergeKey=mountPath
 	// +patchStrategy=merge
 	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty" patchStrategy:"merge" patchMergeKey:"mountPath" protobuf:"bytes,9,rep,name=volumeMounts"`
+	// PreStop is called immediately before a runner container is terminated.
+	// Useful to wait for the running job to finish before SIGTERM is sent.
+	// +optional
+	PreStopHook *v1.LifecycleHandler `json:"preStopHook,omitempty"`
+	// Security options overriding the defaults of the runner container.
+	// +optional
+	SecurityContext *RunnerSecurityContext `json:"securityContext,omitempty"`
+	// Working directory of the runner container.
+	// The runner image uses /home/runner, which is the home directory owned by the runner user, when not specified.
+	// Typically /home/runner or its subdirectory should be used since other directories may not be writable by the runner user.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+	// Path of the file the termination message of the container is written to.
+	// Defaults to /dev/termination-log.
+	// +optional
+	TerminationMessagePath string `json:"terminationMessagePath,omitempty"`
+	// How the termination message of the container is populated.
+	// FallbackToLogsOnError uses the last lines of the log when the message file is empty and the container fails, which helps debugging failed starts.
+	// Defaults to File.
+	// +kubebuilder:validation:Enum=File;FallbackToLogsOnError
+	// +optional
+	TerminationMessagePolicy *v1.TerminationMessagePolicy `json:"terminationMessagePolicy,omitempty"`
+}
+
+// SecurityProfile is a preset of the security context of the runner container
+// +kubebuilder:validation:Enum=restricted;baseline;privileged
+type SecurityProfile string
+
+const (
+	// SecurityProfileRestricted additionally forbids privilege escalation and drops all capabilities, so sudo is not available in the runner container
+	SecurityProfileRestricted SecurityProfile = "restricted"
+	// SecurityProfileBaseline represents the default security context of the runner container
+	SecurityProfileBaseline SecurityProfile = "baseline"
+	// SecurityProfilePrivileged runs the runner container privileged, e.g. for Docker in Docker
+	SecurityProfilePrivileged SecurityProfile = "privileged"
+)
+
+// RunnerSecurityContext defines security options of runner container
+type RunnerSecurityContext struct {
+	// The UID to run the entrypoint of the runner container process.
+	// Defaults to 60000, which is the user created in the runner image.
+	// +optional
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+	// The seccomp options used by the runner container.
+	// Defaults to RuntimeDefault.
+	// +optional
+	SeccompProfile *v1.SeccompProfile `json:"seccompProfile,omitempty"`
+	// The AppArmor options used by the runner container.
+	// Applied by the beta annotation of AppArmor since the field is not available in the supported Kubernetes versions.
+	// +optional
+	AppArmorProfile *AppArmorProfile `json:"appArmorProfile,omitempty"`
+}
+
+// AppArmorProfileType is the type of AppArmor profile
+// +kubebuilder:validation:Enum=RuntimeDefault;Localhost;Unconfined
+type AppArmorProfileType string
+
+const (
+	// AppArmorProfileTypeRuntimeDefault represents the default AppArmor profile of the container runtime
+	AppArmorProfileTypeRuntimeDefault AppArmorProfileType = "RuntimeDefault"
+	// AppArmorProfileTypeLocalhost represents a profile pre-loaded on the node
+	AppArmorProfileTypeLocalhost AppArmorProfileType = "Localhost"
+	// AppArmorProfileTypeUnconfined represents no AppArmor enforcement
+	AppArmorProfileTypeUnconfined AppArmorProfileType = "Unconfined"
+)
+
+// AppArmorProfile defines a pod or container's AppArmor settings
+type AppArmorProfile struct {
+	// Type indicates which kind of AppArmor profile will be applied.
+	Type AppArmorProfileType `json:"type"`
+	// LocalhostProfile indicates a profile loaded on the node that should be used.
+	// Must be set if and only if type is "Localhost".
+	// +optional
+	LocalhostProfile *string `json:"localhostProfile,omitempty"`
+}
+
+// Overriding Spec for sidecar container.
+type ContainerSpec struct {
+	// Docker image name.
+	// Defaults to the image configured at the controller.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Arguments appended to the default arguments.
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// List of environment variables to set in the container.
+	// Variables with the same name as the default ones take precedence.
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Env []v1.EnvVar `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=env"`
+	// Compute Resources required by this container.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-compute-resources-container/
+	Resources v1.ResourceRequirements `json:"resources,omitempty" protobuf:"bytes,8,opt,name=resources"`
 }
 
+// Condition types of Runner
+const (
+	// RunnerConditionDeploymentReady indicates whether all replicas of the runner deployment are updated and ready
+	RunnerConditionDeploymentReady = "DeploymentReady"
+	// RunnerConditionImageNotAllowed indicates that the runner is not reconciled since its image is not allowed by the controller
+	RunnerConditionImageNotAllowed = "ImageNotAllowed"
+)
+
 // RunnerStatus defines the observed state of Runner
-type RunnerStatus struct{}
+type RunnerStatus struct {
+	// Repository name of the image built for runner, which is pushed to and pulled from the registry configured at the controller
+	BuiltImageRepository string `json:"builtImageRepository,omitempty"`
+	// Digest of the runner image resolved last when pinImageDigest is set
+	// +optional
+	ResolvedImageDigest string `json:"resolvedImageDigest,omitempty"`
+	// Expiry of the token issued by GitHub App, which is renewed before it
+	// +optional
+	TokenExpiresAt *metaV1.Time `json:"tokenExpiresAt,omitempty"`
+	// Latest observations of the state of runner
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metaV1.Condition `json:"conditions,omitempty"`
+}
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Repository",type=string,JSONPath=`.spec.repository`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="DeploymentReady")].status`
+// +kubebuilder:printcolumn:name="Token Expires At",type=date,JSONPath=`.status.tokenExpiresAt`
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
+// +kubebuilder:printcolumn:name="Built Image Repository",type=string,JSONPath=`.status.builtImageRepository`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Runner is the schema for the runners API
 type Runner struct {
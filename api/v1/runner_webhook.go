@@ -0,0 +1,443 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	dockerref "github.com/docker/distribution/reference"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var repositoryPattern = regexp.MustCompile(`^[^/]+/[^/]+$`)
+
+// Path at which the controller mounts the workspace
+const workspaceMountPath = "/workspace"
+
+// Defaults set at admission, which the controller also falls back to for runners admitted without the mutating webhook
+const (
+	DefaultBuilderMemoryLimit                  = "4Gi"
+	DefaultTerminationGracePeriodSeconds int64 = 30
+)
+
+// Scopes which personal access tokens (classic) can have
+var personalAccessTokenScopes = map[string]struct{}{
+	"repo":                      {},
+	"repo:status":               {},
+	"repo_deployment":           {},
+	"public_repo":               {},
+	"repo:invite":               {},
+	"security_events":           {},
+	"workflow":                  {},
+	"write:packages":            {},
+	"read:packages":             {},
+	"delete:packages":           {},
+	"admin:org":                 {},
+	"write:org":                 {},
+	"read:org":                  {},
+	"manage_runners:org":        {},
+	"admin:public_key":          {},
+	"write:public_key":          {},
+	"read:public_key":           {},
+	"admin:repo_hook":           {},
+	"write:repo_hook":           {},
+	"read:repo_hook":            {},
+	"admin:org_hook":            {},
+	"gist":                      {},
+	"notifications":             {},
+	"user":                      {},
+	"read:user":                 {},
+	"user:email":                {},
+	"user:follow":               {},
+	"delete_repo":               {},
+	"write:discussion":          {},
+	"read:discussion":           {},
+	"admin:enterprise":          {},
+	"manage_runners:enterprise": {},
+	"manage_billing:enterprise": {},
+	"read:enterprise":           {},
+	"audit_log":                 {},
+	"read:audit_log":            {},
+	"codespace":                 {},
+	"codespace:secrets":         {},
+	"copilot":                   {},
+	"manage_billing:copilot":    {},
+	"project":                   {},
+	"read:project":              {},
+	"admin:gpg_key":             {},
+	"write:gpg_key":             {},
+	"read:gpg_key":              {},
+	"admin:ssh_signing_key":     {},
+	"write:ssh_signing_key":     {},
+	"read:ssh_signing_key":      {},
+}
+
+// Permissions of GitHub App installation access tokens and their allowed levels
+var gitHubAppPermissions = map[string][]string{
+	"actions":                          {"read", "write"},
+	"administration":                   {"read", "write"},
+	"checks":                           {"read", "write"},
+	"contents":                         {"read", "write"},
+	"deployments":                      {"read", "write"},
+	"environments":                     {"read", "write"},
+	"issues":                           {"read", "write"},
+	"metadata":                         {"read", "write"},
+	"packages":                         {"read", "write"},
+	"pages":                            {"read", "write"},
+	"pull_requests":                    {"read", "write"},
+	"repository_hooks":                 {"read", "write"},
+	"secrets":                          {"read", "write"},
+	"statuses":                         {"read", "write"},
+	"workflows":                        {"write"},
+	"members":                          {"read", "write"},
+	"organization_administration":      {"read", "write"},
+	"organization_self_hosted_runners": {"read", "write"},
+}
+
+// Names of containers built by the controller, which must not be used by sidecar containers
+var reservedContainerNames = map[string]struct{}{
+	"runner":   {},
+	"exporter": {},
+}
+
+// Names of init containers built by the controller, which must not be used by user init containers
+var reservedInitContainerNames = map[string]struct{}{
+	"workspace": {},
+	"kaniko":    {},
+}
+
+func (r *Runner) SetupWebhookWithManager(mgr ctrl.Manager, validator *RunnerValidator) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&RunnerDefaulter{
+			Client: mgr.GetClient(),
+		}).
+		WithValidator(validator).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-github-actions-runner-kaidotdev-github-io-v1-runner,mutating=true,failurePolicy=fail,sideEffects=None,groups=github-actions-runner.kaidotdev.github.io,resources=runners,verbs=create;update,versions=v1,name=mrunner.github-actions-runner.kaidotdev.github.io,admissionReviewVersions=v1
+
+// RunnerDefaulter sets default values on Runner including the ones inherited from RunnerClass
+// +kubebuilder:object:generate=false
+type RunnerDefaulter struct {
+	Client client.Reader
+}
+
+var _ webhook.CustomDefaulter = &RunnerDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type
+func (d *RunnerDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	r := obj.(*Runner)
+
+	runnerClass, err := r.MatchRunnerClass(ctx, d.Client)
+	if err != nil {
+		return err
+	}
+	if runnerClass != nil {
+		if err := r.ApplyRunnerClass(runnerClass); err != nil {
+			return err
+		}
+	}
+	r.Default()
+	r.defaultRollingUpdateStrategy()
+	return nil
+}
+
+// Default sets static default values on Runner
+func (r *Runner) Default() {
+	if r.Spec.BuilderContainerSpec.Resources.Limits == nil {
+		r.Spec.BuilderContainerSpec.Resources.Limits = make(v1.ResourceList)
+	}
+	if r.Spec.BuilderContainerSpec.Resources.Limits.Memory().IsZero() {
+		r.Spec.BuilderContainerSpec.Resources.Limits[v1.ResourceMemory] = resource.MustParse(DefaultBuilderMemoryLimit)
+	}
+	if r.Spec.TerminationGracePeriodSeconds == nil {
+		r.Spec.TerminationGracePeriodSeconds = func(i int64) *int64 { return &i }(DefaultTerminationGracePeriodSeconds)
+	}
+}
+
+// defaultRollingUpdateStrategy surges runners of a single replica first since they have no availability during rolling update otherwise.
+// It is applied at admission only, so that existing runners including ones scaled by HPA without replicas keep the strategy of 25%/1.
+func (r *Runner) defaultRollingUpdateStrategy() {
+	singleReplica := r.Spec.Replicas != nil && *r.Spec.Replicas == 1
+	recreate := r.Spec.DeploymentStrategy != nil && *r.Spec.DeploymentStrategy == appsV1.RecreateDeploymentStrategyType
+	if r.Spec.RollingUpdateStrategy == nil && singleReplica && !recreate {
+		r.Spec.RollingUpdateStrategy = &appsV1.RollingUpdateDeployment{
+			MaxSurge:       func(i intstr.IntOrString) *intstr.IntOrString { return &i }(intstr.FromInt32(1)),
+			MaxUnavailable: func(i intstr.IntOrString) *intstr.IntOrString { return &i }(intstr.FromInt32(0)),
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-github-actions-runner-kaidotdev-github-io-v1-runner,mutating=false,failurePolicy=fail,sideEffects=None,groups=github-actions-runner.kaidotdev.github.io,resources=runners,verbs=create;update,versions=v1,name=vrunner.github-actions-runner.kaidotdev.github.io,admissionReviewVersions=v1
+
+// RunnerValidator validates Runner against the controller-level configuration
+// +kubebuilder:object:generate=false
+type RunnerValidator struct {
+	// Whether GitHub App client ID and private key are configured at the controller level
+	GitHubAppConfigured bool
+	// Whether GitHub App installation ID is configured or discovered at the controller level
+	GitHubAppInstallationConfigured bool
+}
+
+var _ webhook.CustomValidator = &RunnerValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *RunnerValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(obj.(*Runner)), v.validate(obj.(*Runner))
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *RunnerValidator) ValidateUpdate(_ context.Context, _ runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
+	return v.warnings(newObj.(*Runner)), v.validate(newObj.(*Runner))
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *RunnerValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// warnings returns warnings of the runner accepted but worth reviewing
+func (v *RunnerValidator) warnings(r *Runner) admission.Warnings {
+	var warnings admission.Warnings
+	if r.Spec.RequiredPermissions["administration"] == "write" {
+		warnings = append(warnings, "spec.requiredPermissions grants administration: write, which allows the token to administer the repository")
+	}
+	if r.Spec.SecurityProfile == SecurityProfilePrivileged {
+		warnings = append(warnings, "spec.securityProfile is privileged, which gives jobs of the runner full access to the node")
+	}
+	return warnings
+}
+
+func (v *RunnerValidator) validate(r *Runner) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if !repositoryPattern.MatchString(r.Spec.Repository) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("repository"), r.Spec.Repository, "must be in owner/repo format"))
+	}
+
+	if r.Spec.RegistrationURLPattern != "" {
+		if err := validateRegistrationURLPattern(r.Spec.RegistrationURLPattern); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("registrationURLPattern"), r.Spec.RegistrationURLPattern, err.Error()))
+		}
+	}
+
+	if _, err := dockerref.ParseNormalizedNamed(r.Spec.Image); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("image"), r.Spec.Image, err.Error()))
+	}
+
+	if r.Spec.PreBuiltImage != "" {
+		if _, err := dockerref.ParseNormalizedNamed(r.Spec.PreBuiltImage); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("preBuiltImage"), r.Spec.PreBuiltImage, err.Error()))
+		}
+	}
+
+	for name, level := range r.Spec.RequiredPermissions {
+		levels, ok := gitHubAppPermissions[name]
+		if !ok {
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("requiredPermissions").Key(name), name, sortedKeys(gitHubAppPermissions)))
+			continue
+		}
+		if !slices.Contains(levels, level) {
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("requiredPermissions").Key(name), level, levels))
+		}
+	}
+
+	if r.Spec.PinImageDigest && !r.Spec.SkipBuild && r.Spec.PreBuiltImage == "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("pinImageDigest"), "requires skipBuild or preBuiltImage since images built by runner pods change on every start"))
+	}
+
+	if r.Spec.BuildNamespace != "" {
+		if errs := validation.IsDNS1123Label(r.Spec.BuildNamespace); len(errs) != 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("buildNamespace"), r.Spec.BuildNamespace, strings.Join(errs, ", ")))
+		}
+		if r.Spec.CACertSecretRef != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("buildNamespace"), "must not be specified together with caCertSecretRef since the secret cannot be mounted across namespaces"))
+		}
+	}
+
+	if r.Spec.BuilderServiceAccountName != "" {
+		if r.Spec.BuildNamespace == "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("builderServiceAccountName"), "requires buildNamespace since the builder container shares the service account of runner pods otherwise"))
+		}
+		if errs := validation.IsDNS1123Subdomain(r.Spec.BuilderServiceAccountName); len(errs) != 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("builderServiceAccountName"), r.Spec.BuilderServiceAccountName, strings.Join(errs, ", ")))
+		}
+	}
+
+	if r.Spec.PersonalAccessTokenRef != nil {
+		for i, scope := range r.Spec.PersonalAccessTokenRef.Scopes {
+			if _, ok := personalAccessTokenScopes[scope]; !ok {
+				allErrs = append(allErrs, field.NotSupported(specPath.Child("personalAccessTokenRef", "scopes").Index(i), scope, sortedKeys(personalAccessTokenScopes)))
+			}
+		}
+	}
+
+	hasToken := r.Spec.TokenSecretKeyRef != nil || r.Spec.PersonalAccessTokenRef != nil
+	hasApp := r.Spec.AppSecretRef != nil
+	if hasToken && hasApp {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("appSecretRef"), "must not be specified together with tokenSecretKeyRef or personalAccessTokenRef"))
+	}
+	hasInstallation := r.Spec.AppInstallationSecretRef != nil || v.GitHubAppInstallationConfigured
+	if !hasToken && !hasApp && !(v.GitHubAppConfigured && hasInstallation) {
+		allErrs = append(allErrs, field.Required(specPath.Child("tokenSecretKeyRef"), "one of tokenSecretKeyRef, personalAccessTokenRef, or appSecretRef is required unless GitHub App and its installation are configured"))
+	}
+	if r.Spec.AppInstallationSecretRef != nil && !v.GitHubAppConfigured {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("appInstallationSecretRef"), "requires GitHub App configured at the controller"))
+	}
+	if r.Spec.SharedTokenSecret && (hasToken || hasApp) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("sharedTokenSecret"), "must not be specified together with tokenSecretKeyRef, personalAccessTokenRef, or appSecretRef since only tokens issued by the controller are shared"))
+	}
+
+	for i, container := range r.Spec.Template.Spec.Containers {
+		if _, ok := reservedContainerNames[container.Name]; ok {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("template", "spec", "containers").Index(i).Child("name"), container.Name, "must not be a name reserved by the controller"))
+		}
+	}
+	for i, container := range r.Spec.Template.Spec.InitContainers {
+		if _, ok := reservedInitContainerNames[container.Name]; ok {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("template", "spec", "initContainers").Index(i).Child("name"), container.Name, "must not be a name reserved by the controller"))
+		}
+	}
+
+	if p := r.Spec.Template.Spec.DNSPolicy; p != nil && *p == v1.DNSNone {
+		if c := r.Spec.Template.Spec.DNSConfig; c == nil || len(c.Nameservers) == 0 {
+			allErrs = append(allErrs, field.Required(specPath.Child("template", "spec", "dnsConfig", "nameservers"), "at least one nameserver must be specified when dnsPolicy is None"))
+		}
+	}
+
+	if securityContext := r.Spec.RunnerContainerSpec.SecurityContext; securityContext != nil {
+		securityContextPath := specPath.Child("runnerContainerSpec", "securityContext")
+		if p := securityContext.SeccompProfile; p != nil && p.Type == v1.SeccompProfileTypeLocalhost && (p.LocalhostProfile == nil || *p.LocalhostProfile == "") {
+			allErrs = append(allErrs, field.Required(securityContextPath.Child("seccompProfile", "localhostProfile"), "must be specified when type is Localhost"))
+		}
+		if p := securityContext.AppArmorProfile; p != nil && p.Type == AppArmorProfileTypeLocalhost && (p.LocalhostProfile == nil || *p.LocalhostProfile == "") {
+			allErrs = append(allErrs, field.Required(securityContextPath.Child("appArmorProfile", "localhostProfile"), "must be specified when type is Localhost"))
+		}
+	}
+
+	if r.Spec.RunnerUID != nil && *r.Spec.RunnerUID <= 999 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("runnerUID"), *r.Spec.RunnerUID, "must be greater than 999"))
+	}
+	if r.Spec.RunnerGID != nil && *r.Spec.RunnerGID <= 999 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("runnerGID"), *r.Spec.RunnerGID, "must be greater than 999"))
+	}
+
+	for i, mount := range r.Spec.SecretVolumeMounts {
+		mountPath := specPath.Child("secretVolumeMounts").Index(i)
+		if mount.SecretName == "" {
+			allErrs = append(allErrs, field.Required(mountPath.Child("secretName"), "must be specified"))
+		}
+		if err := validateSecretMountPath(mount.MountPath); err != nil {
+			allErrs = append(allErrs, field.Invalid(mountPath.Child("mountPath"), mount.MountPath, err.Error()))
+		}
+	}
+
+	builderContainerSpecPath := specPath.Child("builderContainerSpec")
+	for i, mirror := range r.Spec.BuilderContainerSpec.RegistryMirrors {
+		if err := validateRegistry(mirror, true); err != nil {
+			allErrs = append(allErrs, field.Invalid(builderContainerSpecPath.Child("registryMirrors").Index(i), mirror, err.Error()))
+		}
+	}
+	for i, registry := range r.Spec.BuilderContainerSpec.InsecureRegistries {
+		if err := validateRegistry(registry, false); err != nil {
+			allErrs = append(allErrs, field.Invalid(builderContainerSpecPath.Child("insecureRegistries").Index(i), registry, err.Error()))
+		}
+	}
+
+	if t := r.Spec.BuilderContainerSpec.BuildTimeout; t != nil && t.Duration < time.Second {
+		allErrs = append(allErrs, field.Invalid(builderContainerSpecPath.Child("buildTimeout"), t.Duration.String(), "must be at least 1s"))
+	}
+
+	if i := r.Spec.PeriodicResyncInterval; i != nil && i.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("periodicResyncInterval"), i.Duration.String(), "must be positive"))
+	}
+
+	if r.Spec.Replicas != nil && *r.Spec.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *r.Spec.Replicas, "must be positive"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Runner").GroupKind(), r.Name, allErrs)
+}
+
+// validateSecretMountPath checks the path is absolute and neither contains nor is contained by /workspace,
+// where the controller mounts the workspace
+func validateSecretMountPath(mountPath string) error {
+	if !path.IsAbs(mountPath) {
+		return errors.New("must be an absolute path")
+	}
+	cleaned := path.Clean(mountPath)
+	if cleaned == workspaceMountPath || strings.HasPrefix(cleaned, workspaceMountPath+"/") || strings.HasPrefix(workspaceMountPath, strings.TrimSuffix(cleaned, "/")+"/") {
+		return errors.New("must not conflict with " + workspaceMountPath + " reserved by the controller")
+	}
+	return nil
+}
+
+// validateRegistrationURLPattern checks the pattern has exactly one %s for the repository and forms an absolute HTTP(S) URL
+func validateRegistrationURLPattern(pattern string) error {
+	if strings.Count(pattern, "%") != 1 || !strings.Contains(pattern, "%s") {
+		return errors.New("must contain exactly one %s replaced with the repository")
+	}
+	u, err := url.Parse(strings.Replace(pattern, "%s", "owner/repo", 1))
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("must be an absolute HTTP(S) URL")
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// validateRegistry checks the registry is in the form of host[:port], followed by /path when allowed, as kaniko expects
+func validateRegistry(registry string, allowPath bool) error {
+	if strings.Contains(registry, "://") {
+		return errors.New("must not contain scheme")
+	}
+	u, err := url.Parse("//" + registry)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("must be in the form of host[:port][/path]")
+	}
+	if u.Port() != "" {
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			return err
+		}
+	}
+	if !allowPath && strings.Trim(u.Path, "/") != "" {
+		return errors.New("must be in the form of host[:port]")
+	}
+	return nil
+}
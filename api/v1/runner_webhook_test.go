@@ -0,0 +1,1324 @@
+package v1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRunnerValidatorValidate(t *testing.T) {
+	type in struct {
+		containers      []v1.Container
+		initContainers  []v1.Container
+		securityContext *RunnerSecurityContext
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"no sidecar",
+			in{
+				nil,
+				nil,
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"sidecar",
+			in{
+				[]v1.Container{
+					{
+						Name:  "dind",
+						Image: "docker:dind",
+					},
+				},
+				nil,
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"sidecar named runner",
+			in{
+				[]v1.Container{
+					{
+						Name:  "runner",
+						Image: "docker:dind",
+					},
+				},
+				nil,
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"sidecar named exporter",
+			in{
+				[]v1.Container{
+					{
+						Name:  "exporter",
+						Image: "docker:dind",
+					},
+				},
+				nil,
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"init container",
+			in{
+				nil,
+				[]v1.Container{
+					{
+						Name:  "cache",
+						Image: "busybox",
+					},
+				},
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"init container named kaniko",
+			in{
+				nil,
+				[]v1.Container{
+					{
+						Name:  "kaniko",
+						Image: "busybox",
+					},
+				},
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"localhost seccomp profile",
+			in{
+				nil,
+				nil,
+				&RunnerSecurityContext{
+					SeccompProfile: &v1.SeccompProfile{
+						Type:             v1.SeccompProfileTypeLocalhost,
+						LocalhostProfile: func(s string) *string { return &s }("profiles/runner.json"),
+					},
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"localhost seccomp profile without path",
+			in{
+				nil,
+				nil,
+				&RunnerSecurityContext{
+					SeccompProfile: &v1.SeccompProfile{
+						Type: v1.SeccompProfileTypeLocalhost,
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"localhost apparmor profile without name",
+			in{
+				nil,
+				nil,
+				&RunnerSecurityContext{
+					AppArmorProfile: &AppArmorProfile{
+						Type: AppArmorProfileTypeLocalhost,
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			runner.Spec.Template.Spec.Containers = tt.in.containers
+			runner.Spec.Template.Spec.InitContainers = tt.in.initContainers
+			runner.Spec.RunnerContainerSpec.SecurityContext = tt.in.securityContext
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateCredentials(t *testing.T) {
+	type in struct {
+		validator                *RunnerValidator
+		appInstallationSecretRef *v1.SecretKeySelector
+		tokenSecretKeyRef        *v1.SecretKeySelector
+		sharedTokenSecret        bool
+	}
+
+	type want struct {
+		err bool
+	}
+
+	installationSecretRef := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{
+			Name: "installation",
+		},
+		Key: "INSTALLATION_ID",
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"installation configured at controller",
+			in{
+				&RunnerValidator{
+					GitHubAppConfigured:             true,
+					GitHubAppInstallationConfigured: true,
+				},
+				nil,
+				nil,
+				false,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"installation configured at runner",
+			in{
+				&RunnerValidator{
+					GitHubAppConfigured: true,
+				},
+				installationSecretRef,
+				nil,
+				false,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"no installation",
+			in{
+				&RunnerValidator{
+					GitHubAppConfigured: true,
+				},
+				nil,
+				nil,
+				false,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"installation without GitHub App",
+			in{
+				&RunnerValidator{},
+				installationSecretRef,
+				nil,
+				false,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"shared token secret",
+			in{
+				&RunnerValidator{
+					GitHubAppConfigured:             true,
+					GitHubAppInstallationConfigured: true,
+				},
+				nil,
+				nil,
+				true,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"shared token secret with token",
+			in{
+				&RunnerValidator{
+					GitHubAppConfigured:             true,
+					GitHubAppInstallationConfigured: true,
+				},
+				nil,
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+					Key: "TOKEN",
+				},
+				true,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:                    "ubuntu:22.04",
+					Repository:               "kaidotdev/github-actions-runner-controller",
+					AppInstallationSecretRef: tt.in.appInstallationSecretRef,
+					TokenSecretKeyRef:        tt.in.tokenSecretKeyRef,
+					SharedTokenSecret:        tt.in.sharedTokenSecret,
+				},
+			}
+
+			err := tt.in.validator.validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerDefaultRollingUpdateStrategy(t *testing.T) {
+	type in struct {
+		replicas           *int32
+		deploymentStrategy *appsV1.DeploymentStrategyType
+	}
+
+	type want struct {
+		rollingUpdateStrategy *appsV1.RollingUpdateDeployment
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"single replica",
+			in{
+				func(i int32) *int32 { return &i }(1),
+				nil,
+			},
+			want{
+				&appsV1.RollingUpdateDeployment{
+					MaxSurge:       func(i intstr.IntOrString) *intstr.IntOrString { return &i }(intstr.FromInt32(1)),
+					MaxUnavailable: func(i intstr.IntOrString) *intstr.IntOrString { return &i }(intstr.FromInt32(0)),
+				},
+			},
+		},
+		{
+			"multiple replicas",
+			in{
+				func(i int32) *int32 { return &i }(3),
+				nil,
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"unset replicas",
+			in{
+				nil,
+				nil,
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"recreate",
+			in{
+				func(i int32) *int32 { return &i }(1),
+				func(t appsV1.DeploymentStrategyType) *appsV1.DeploymentStrategyType { return &t }(appsV1.RecreateDeploymentStrategyType),
+			},
+			want{
+				nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Replicas:           tt.in.replicas,
+					DeploymentStrategy: tt.in.deploymentStrategy,
+				},
+			}
+			runner.defaultRollingUpdateStrategy()
+
+			if !reflect.DeepEqual(runner.Spec.RollingUpdateStrategy, tt.want.rollingUpdateStrategy) {
+				t.Errorf("rollingUpdateStrategy = %v, want %v", runner.Spec.RollingUpdateStrategy, tt.want.rollingUpdateStrategy)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateRunnerUID(t *testing.T) {
+	type in struct {
+		runnerUID *int64
+		runnerGID *int64
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				nil,
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"custom",
+			in{
+				func(i int64) *int64 { return &i }(1000),
+				func(i int64) *int64 { return &i }(1000),
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"system UID",
+			in{
+				func(i int64) *int64 { return &i }(999),
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"system GID",
+			in{
+				nil,
+				func(i int64) *int64 { return &i }(0),
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					RunnerUID: tt.in.runnerUID,
+					RunnerGID: tt.in.runnerGID,
+				},
+			}
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateDNS(t *testing.T) {
+	type in struct {
+		dnsPolicy *v1.DNSPolicy
+		dnsConfig *v1.PodDNSConfig
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				nil,
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"host network",
+			in{
+				func(p v1.DNSPolicy) *v1.DNSPolicy { return &p }(v1.DNSClusterFirstWithHostNet),
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"none with nameserver",
+			in{
+				func(p v1.DNSPolicy) *v1.DNSPolicy { return &p }(v1.DNSNone),
+				&v1.PodDNSConfig{
+					Nameservers: []string{"1.1.1.1"},
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"none without nameserver",
+			in{
+				func(p v1.DNSPolicy) *v1.DNSPolicy { return &p }(v1.DNSNone),
+				&v1.PodDNSConfig{
+					Searches: []string{"example.com"},
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"none without config",
+			in{
+				func(p v1.DNSPolicy) *v1.DNSPolicy { return &p }(v1.DNSNone),
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			runner.Spec.Template.Spec.DNSPolicy = tt.in.dnsPolicy
+			runner.Spec.Template.Spec.DNSConfig = tt.in.dnsConfig
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateSecretVolumeMounts(t *testing.T) {
+	type in struct {
+		secretVolumeMount SecretVolumeMount
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"valid",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  "/home/runner/.kube",
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"similar to workspace",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  "/workspaces",
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"workspace",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  "/workspace/",
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"under workspace",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  "/workspace/.kube",
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"root",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  "/",
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"relative",
+			in{
+				SecretVolumeMount{
+					SecretName: "kubeconfig",
+					MountPath:  ".kube",
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"without secret name",
+			in{
+				SecretVolumeMount{
+					MountPath: "/home/runner/.kube",
+				},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					SecretVolumeMounts: []SecretVolumeMount{tt.in.secretVolumeMount},
+				},
+			}
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateBuildNamespace(t *testing.T) {
+	type in struct {
+		buildNamespace            string
+		caCertSecretRef           *v1.SecretKeySelector
+		builderServiceAccountName string
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"valid",
+			in{
+				"build",
+				nil,
+				"",
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"invalid name",
+			in{
+				"Build_Namespace",
+				nil,
+				"",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"with CA certificate",
+			in{
+				"build",
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "ca",
+					},
+					Key: "ca.crt",
+				},
+				"",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"with builder service account",
+			in{
+				"build",
+				nil,
+				"kaniko",
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"builder service account without build namespace",
+			in{
+				"",
+				nil,
+				"kaniko",
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					BuildNamespace:            tt.in.buildNamespace,
+					CACertSecretRef:           tt.in.caCertSecretRef,
+					BuilderServiceAccountName: tt.in.builderServiceAccountName,
+				},
+			}
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateRequiredPermissions(t *testing.T) {
+	type in struct {
+		requiredPermissions map[string]string
+	}
+
+	type want struct {
+		err      bool
+		warnings int
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				nil,
+			},
+			want{
+				false,
+				0,
+			},
+		},
+		{
+			"reduced",
+			in{
+				map[string]string{
+					"actions":                          "read",
+					"metadata":                         "read",
+					"organization_self_hosted_runners": "write",
+				},
+			},
+			want{
+				false,
+				0,
+			},
+		},
+		{
+			"administration write",
+			in{
+				map[string]string{
+					"administration": "write",
+				},
+			},
+			want{
+				false,
+				1,
+			},
+		},
+		{
+			"unknown permission",
+			in{
+				map[string]string{
+					"everything": "write",
+				},
+			},
+			want{
+				true,
+				0,
+			},
+		},
+		{
+			"unknown level",
+			in{
+				map[string]string{
+					"actions": "admin",
+				},
+			},
+			want{
+				true,
+				0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					RequiredPermissions: tt.in.requiredPermissions,
+				},
+			}
+
+			warnings, err := (&RunnerValidator{}).ValidateCreate(context.Background(), runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("ValidateCreate() error = %v, want error %v", err, tt.want.err)
+			}
+			if len(warnings) != tt.want.warnings {
+				t.Errorf("ValidateCreate() warnings = %v, want %d warnings", warnings, tt.want.warnings)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidatePersonalAccessTokenScopes(t *testing.T) {
+	type in struct {
+		scopes []string
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"known scopes",
+			in{
+				[]string{"repo", "workflow", "manage_runners:org"},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"no scopes",
+			in{
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"unknown scope",
+			in{
+				[]string{"repo", "actions:write"},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					PersonalAccessTokenRef: &PersonalAccessTokenRef{
+						SecretRef: v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{
+								Name: "pat",
+							},
+							Key: "TOKEN",
+						},
+						Scopes: tt.in.scopes,
+					},
+				},
+			}
+
+			_, err := (&RunnerValidator{}).ValidateCreate(context.Background(), runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("ValidateCreate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidatePinImageDigest(t *testing.T) {
+	type in struct {
+		skipBuild     bool
+		preBuiltImage string
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"built by runner pods",
+			in{
+				false,
+				"",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"skip build",
+			in{
+				true,
+				"",
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"pre-built image",
+			in{
+				false,
+				"ghcr.io/kaidotdev/runner:v1",
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					SkipBuild:      tt.in.skipBuild,
+					PreBuiltImage:  tt.in.preBuiltImage,
+					PinImageDigest: true,
+				},
+			}
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerValidatorValidateBuildTimeout(t *testing.T) {
+	type in struct {
+		buildTimeout *metaV1.Duration
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"without timeout",
+			in{
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"timeout",
+			in{
+				&metaV1.Duration{Duration: 30 * time.Minute},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"sub-second timeout",
+			in{
+				&metaV1.Duration{Duration: 500 * time.Millisecond},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &Runner{
+				Spec: RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+					BuilderContainerSpec: BuilderContainerSpec{
+						BuildTimeout: tt.in.buildTimeout,
+					},
+				},
+			}
+
+			err := (&RunnerValidator{}).validate(runner)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestValidateRegistry(t *testing.T) {
+	type in struct {
+		registry  string
+		allowPath bool
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"host",
+			in{
+				"mirror.gcr.io",
+				true,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"host with port and path",
+			in{
+				"registry.example.com:5000/dockerhub",
+				true,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"path not allowed",
+			in{
+				"registry.example.com:5000/dockerhub",
+				false,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"scheme",
+			in{
+				"https://mirror.gcr.io",
+				true,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"invalid port",
+			in{
+				"registry.example.com:port",
+				false,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"empty",
+			in{
+				"",
+				false,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRegistry(tt.in.registry, tt.in.allowPath)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validateRegistry() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestValidateRegistrationURLPattern(t *testing.T) {
+	type in struct {
+		pattern string
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"GitHub Enterprise Server",
+			in{
+				"https://github.example.com/%s",
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"without repository",
+			in{
+				"https://github.example.com/owner/repo",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"multiple verbs",
+			in{
+				"https://github.example.com/%s/%d",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"relative",
+			in{
+				"github.example.com/%s",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"unsupported scheme",
+			in{
+				"ssh://github.example.com/%s",
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRegistrationURLPattern(tt.in.pattern)
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("validateRegistrationURLPattern() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"context"
+	"sort"
+
+	"github.com/imdario/mergo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RunnerClassSpec defines the desired state of RunnerClass
+type RunnerClassSpec struct {
+	// Default spec inherited by runners selected by selector.
+	// Fields specified at runner take precedence.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	DefaultSpec RunnerSpec `json:"defaultSpec,omitempty"`
+	// Label selector for runners inheriting default spec, which must not be empty
+	Selector metaV1.LabelSelector `json:"selector,omitempty"`
+}
+
+// RunnerClassStatus defines the observed state of RunnerClass
+type RunnerClassStatus struct{}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// RunnerClass is the schema for the runnerclasses API
+type RunnerClass struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RunnerClassSpec   `json:"spec,omitempty"`
+	Status RunnerClassStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RunnerClassList contains a list of RunnerClass
+type RunnerClassList struct {
+	metaV1.TypeMeta `json:",inline"`
+	metaV1.ListMeta `json:"metadata,omitempty"`
+	Items           []RunnerClass `json:"items"`
+}
+
+// Validate returns an error if the runner class is invalid
+func (c *RunnerClass) Validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if selector, err := metaV1.LabelSelectorAsSelector(&c.Spec.Selector); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("selector"), c.Spec.Selector, err.Error()))
+	} else if selector.Empty() {
+		allErrs = append(allErrs, field.Required(specPath.Child("selector"), "must not be empty, otherwise it selects all runners in the cluster"))
+	}
+
+	if c.Spec.DefaultSpec.Replicas != nil && *c.Spec.DefaultSpec.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("defaultSpec", "replicas"), *c.Spec.DefaultSpec.Replicas, "must be positive"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("RunnerClass").GroupKind(), c.Name, allErrs)
+}
+
+// MatchRunnerClass returns the runner class whose selector matches labels of the runner.
+// When multiple runner classes match, the first one in name order is returned.
+// Runner classes with an empty selector match no runner, since they would select all runners in the cluster.
+// It returns nil if no runner class matches.
+func (r *Runner) MatchRunnerClass(ctx context.Context, c client.Reader) (*RunnerClass, error) {
+	var runnerClasses RunnerClassList
+	if err := c.List(ctx, &runnerClasses); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(runnerClasses.Items, func(i, j int) bool {
+		return runnerClasses.Items[i].Name < runnerClasses.Items[j].Name
+	})
+	for i := range runnerClasses.Items {
+		selector, err := metaV1.LabelSelectorAsSelector(&runnerClasses.Items[i].Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(labels.Set(r.Labels)) {
+			return &runnerClasses.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ApplyRunnerClass fills fields not specified at the runner with default spec of the runner class
+func (r *Runner) ApplyRunnerClass(c *RunnerClass) error {
+	return mergo.Merge(&r.Spec, *c.Spec.DefaultSpec.DeepCopy())
+}
@@ -0,0 +1,401 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestRunnerClassValidate(t *testing.T) {
+	type in struct {
+		runnerClass *RunnerClass
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"valid",
+			in{
+				&RunnerClass{
+					Spec: RunnerClassSpec{
+						Selector: metaV1.LabelSelector{
+							MatchLabels: map[string]string{
+								"team": "a",
+							},
+						},
+						DefaultSpec: RunnerSpec{
+							Replicas: func(i int32) *int32 { return &i }(2),
+						},
+					},
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"invalid selector",
+			in{
+				&RunnerClass{
+					Spec: RunnerClassSpec{
+						Selector: metaV1.LabelSelector{
+							MatchExpressions: []metaV1.LabelSelectorRequirement{
+								{
+									Key:      "team",
+									Operator: "Unknown",
+								},
+							},
+						},
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"empty selector",
+			in{
+				&RunnerClass{
+					Spec: RunnerClassSpec{
+						DefaultSpec: RunnerSpec{
+							Replicas: func(i int32) *int32 { return &i }(2),
+						},
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"non-positive replicas",
+			in{
+				&RunnerClass{
+					Spec: RunnerClassSpec{
+						Selector: metaV1.LabelSelector{
+							MatchLabels: map[string]string{
+								"team": "a",
+							},
+						},
+						DefaultSpec: RunnerSpec{
+							Replicas: func(i int32) *int32 { return &i }(0),
+						},
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.in.runnerClass.Validate()
+			if got := err != nil; got != tt.want.err {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerMatchRunnerClass(t *testing.T) {
+	type in struct {
+		runner        *Runner
+		runnerClasses []client.Object
+	}
+
+	type want struct {
+		name string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"no runner class",
+			in{
+				&Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Labels: map[string]string{
+							"team": "a",
+						},
+					},
+				},
+				nil,
+			},
+			want{
+				"",
+			},
+		},
+		{
+			"matched",
+			in{
+				&Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Labels: map[string]string{
+							"team": "a",
+						},
+					},
+				},
+				[]client.Object{
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "team-a",
+						},
+						Spec: RunnerClassSpec{
+							Selector: metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"team": "a",
+								},
+							},
+						},
+					},
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "team-b",
+						},
+						Spec: RunnerClassSpec{
+							Selector: metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"team": "b",
+								},
+							},
+						},
+					},
+				},
+			},
+			want{
+				"team-a",
+			},
+		},
+		{
+			"first one in name order",
+			in{
+				&Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Labels: map[string]string{
+							"team": "a",
+						},
+					},
+				},
+				[]client.Object{
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "b",
+						},
+						Spec: RunnerClassSpec{
+							Selector: metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"team": "a",
+								},
+							},
+						},
+					},
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "a",
+						},
+						Spec: RunnerClassSpec{
+							Selector: metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"team": "a",
+								},
+							},
+						},
+					},
+				},
+			},
+			want{
+				"a",
+			},
+		},
+		{
+			"empty selector",
+			in{
+				&Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Labels: map[string]string{
+							"team": "a",
+						},
+					},
+				},
+				[]client.Object{
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "all",
+						},
+					},
+				},
+			},
+			want{
+				"",
+			},
+		},
+		{
+			"not matched",
+			in{
+				&Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Labels: map[string]string{
+							"team": "c",
+						},
+					},
+				},
+				[]client.Object{
+					&RunnerClass{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name: "team-a",
+						},
+						Spec: RunnerClassSpec{
+							Selector: metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"team": "a",
+								},
+							},
+						},
+					},
+				},
+			},
+			want{
+				"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.in.runner.MatchRunnerClass(context.Background(), newFakeClient(t, tt.in.runnerClasses...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var name string
+			if got != nil {
+				name = got.Name
+			}
+			if name != tt.want.name {
+				t.Errorf("MatchRunnerClass() = %q, want %q", name, tt.want.name)
+			}
+		})
+	}
+}
+
+func TestRunnerApplyRunnerClass(t *testing.T) {
+	runner := &Runner{
+		Spec: RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			Replicas:   func(i int32) *int32 { return &i }(3),
+		},
+	}
+	runnerClass := &RunnerClass{
+		Spec: RunnerClassSpec{
+			DefaultSpec: RunnerSpec{
+				Image:    "ubuntu:20.04",
+				Replicas: func(i int32) *int32 { return &i }(2),
+				ProxySettings: &ProxySettings{
+					HTTPProxy: "http://proxy.example.com:3128",
+				},
+				BuilderContainerSpec: BuilderContainerSpec{
+					Resources: v1.ResourceRequirements{
+						Limits: v1.ResourceList{
+							v1.ResourceMemory: resource.MustParse("8Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := runner.ApplyRunnerClass(runnerClass); err != nil {
+		t.Fatal(err)
+	}
+
+	if runner.Spec.Image != "ubuntu:22.04" {
+		t.Errorf("image = %q, want %q", runner.Spec.Image, "ubuntu:22.04")
+	}
+	if *runner.Spec.Replicas != 3 {
+		t.Errorf("replicas = %d, want %d", *runner.Spec.Replicas, 3)
+	}
+	if runner.Spec.ProxySettings == nil || runner.Spec.ProxySettings.HTTPProxy != "http://proxy.example.com:3128" {
+		t.Errorf("proxySettings = %v, want inherited from runner class", runner.Spec.ProxySettings)
+	}
+	if got := runner.Spec.BuilderContainerSpec.Resources.Limits.Memory(); got.Cmp(resource.MustParse("8Gi")) != 0 {
+		t.Errorf("builder memory limit = %s, want %s", got, "8Gi")
+	}
+
+	runner.Spec.ProxySettings.HTTPProxy = "http://changed.example.com:3128"
+	if runnerClass.Spec.DefaultSpec.ProxySettings.HTTPProxy != "http://proxy.example.com:3128" {
+		t.Errorf("runner class must not be modified through runner")
+	}
+}
+
+func TestRunnerDefaulterDefault(t *testing.T) {
+	runner := &Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels: map[string]string{
+				"team": "a",
+			},
+		},
+	}
+	d := &RunnerDefaulter{
+		Client: newFakeClient(t, &RunnerClass{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name: "team-a",
+			},
+			Spec: RunnerClassSpec{
+				Selector: metaV1.LabelSelector{
+					MatchLabels: map[string]string{
+						"team": "a",
+					},
+				},
+				DefaultSpec: RunnerSpec{
+					Replicas: func(i int32) *int32 { return &i }(2),
+				},
+			},
+		}),
+	}
+
+	if err := d.Default(context.Background(), runner); err != nil {
+		t.Fatal(err)
+	}
+
+	if *runner.Spec.Replicas != 2 {
+		t.Errorf("replicas = %d, want %d inherited from runner class", *runner.Spec.Replicas, 2)
+	}
+	if *runner.Spec.TerminationGracePeriodSeconds != 30 {
+		t.Errorf("terminationGracePeriodSeconds = %d, want %d", *runner.Spec.TerminationGracePeriodSeconds, 30)
+	}
+}
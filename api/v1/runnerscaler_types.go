@@ -0,0 +1,42 @@
+package v1
+
+import (
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RunnerScalerSpec defines the desired state of RunnerScaler
+type RunnerScalerSpec struct {
+	// Name of the runner in the same namespace scaled by KEDA.
+	// Leave replicas of the runner unspecified, otherwise the controller restores them.
+	RunnerName string `json:"runnerName"`
+	// Spec of KEDA ScaledObject.
+	// scaleTargetRef is always set to the deployment of the runner by the controller.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ScaledObjectTemplate runtime.RawExtension `json:"scaledObjectTemplate,omitempty"`
+}
+
+// RunnerScalerStatus defines the observed state of RunnerScaler
+type RunnerScalerStatus struct{}
+
+// +kubebuilder:object:root=true
+
+// RunnerScaler is the schema for the runnerscalers API
+type RunnerScaler struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RunnerScalerSpec   `json:"spec,omitempty"`
+	Status RunnerScalerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RunnerScalerList contains a list of RunnerScaler
+type RunnerScalerList struct {
+	metaV1.TypeMeta `json:",inline"`
+	metaV1.ListMeta `json:"metadata,omitempty"`
+	Items           []RunnerScaler `json:"items"`
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -91,8 +92,24 @@ func install(runnerVersion string) {
 	}
 }
 
-func getRegistrationToken(repository string, token string) string {
-	request, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners/registration-token", repository), nil)
+// githubAPIURL returns the base URL of GitHub API for the registration URL,
+// which is served under /api/v3 of the host on GitHub Enterprise Server
+func githubAPIURL(registrationURL string) (string, error) {
+	u, err := url.Parse(registrationURL)
+	if err != nil {
+		return "", xerrors.Errorf("failed to parse registration url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", xerrors.Errorf("registration url must be absolute: %s", registrationURL)
+	}
+	if u.Host == "github.com" || u.Host == "www.github.com" {
+		return "https://api.github.com", nil
+	}
+	return fmt.Sprintf("%s://%s/api/v3", u.Scheme, u.Host), nil
+}
+
+func getRegistrationToken(apiURL string, repository string, token string) string {
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s/repos/%s/actions/runners/registration-token", apiURL, repository), nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -120,8 +137,8 @@ func getRegistrationToken(repository string, token string) string {
 	return registrationTokenResponse.Token
 }
 
-func getRemoveToken(repository string, token string) string {
-	request, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners/remove-token", repository), nil)
+func getRemoveToken(apiURL string, repository string, token string) string {
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s/repos/%s/actions/runners/remove-token", apiURL, repository), nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -149,12 +166,12 @@ func getRemoveToken(repository string, token string) string {
 	return removeTokenResponse.Token
 }
 
-func run(registrationToken string, repository string, hostname string, disableupdate bool) {
+func run(registrationToken string, registrationURL string, hostname string, disableupdate bool) {
 	var args []string
 	if disableupdate {
 		args = append(args, "--disableupdate")
 	}
-	e, _, err := expect.Spawn(fmt.Sprintf("bash config.sh --labels kaidotdev/github-actions-runner-controller --token %s --url https://github.com/%s %s", registrationToken, repository, strings.Join(args, " ")), -1, expect.Verbose(true), expect.Tee(os.Stdout))
+	e, _, err := expect.Spawn(fmt.Sprintf("bash config.sh --labels kaidotdev/github-actions-runner-controller --token %s --url %s %s", registrationToken, registrationURL, strings.Join(args, " ")), -1, expect.Verbose(true), expect.Tee(os.Stdout))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -206,6 +223,7 @@ func remove(registrationToken string) {
 func main() {
 	var runnerVersion string
 	var repository string
+	var registrationURL string
 	var hostname string
 	var token string
 	var githubAppId string
@@ -216,6 +234,7 @@ func main() {
 	var disableupdate bool
 	flag.StringVar(&runnerVersion, "runner-version", "2.291.1", "Version of GitHub Actions runner")
 	flag.StringVar(&repository, "repository", "kaidotdev/github-actions-runner-controller", "GitHub Repository Name")
+	flag.StringVar(&registrationURL, "registration-url", "", "URL to register Runner with. Defaults to the repository on github.com")
 	flag.StringVar(&token, "token", "********", "GitHub Token")
 	flag.StringVar(&hostname, "hostname", "runner", "Hostname used as Runner name")
 	flag.StringVar(&githubAppId, "github-app-id", "", "GitHub App ID")
@@ -237,13 +256,21 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGKILL)
 
+	if registrationURL == "" {
+		registrationURL = "https://github.com/" + repository
+	}
+	apiURL, err := githubAPIURL(registrationURL)
+	if err != nil {
+		log.Fatalf("failed to get api url: %+v", err)
+	}
+
 	if githubAppId != "" && githubAppInstallationId != "" && githubAppPrivateKey != "" {
-		err, jwtToken := signJwt(githubAppPrivateKey, githubAppId)
+		jwtToken, err := signJwt(githubAppPrivateKey, githubAppId)
 		if err != nil {
 			log.Fatalf("failed to sign jwt: %+v", err)
 		}
 
-		accessTokenRequest, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/app/installations/%s/access_tokens", githubAppInstallationId), nil)
+		accessTokenRequest, err := http.NewRequest("POST", fmt.Sprintf("%s/app/installations/%s/access_tokens", apiURL, githubAppInstallationId), nil)
 		if err != nil {
 			log.Fatalf("failed to create request: %+v", err)
 		}
@@ -272,24 +299,24 @@ func main() {
 	}
 
 	log.Printf("Run: %s", hostname)
-	registrationToken := getRegistrationToken(repository, token)
-	go run(registrationToken, repository, hostname, disableupdate)
+	registrationToken := getRegistrationToken(apiURL, repository, token)
+	go run(registrationToken, registrationURL, hostname, disableupdate)
 
 	<-quit
 	log.Printf("Remove: %s", hostname)
-	removeToken := getRemoveToken(repository, token)
+	removeToken := getRemoveToken(apiURL, repository, token)
 	remove(removeToken)
 }
 
-func signJwt(privateKey string, clientId string) (error, *string) {
+func signJwt(privateKey string, clientId string) (*string, error) {
 	block, _ := pem.Decode([]byte(privateKey))
 	if block == nil {
-		return xerrors.New("failed to decode private key"), nil
+		return nil, xerrors.New("failed to decode private key")
 	}
 
 	rsaPrivateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
-		return xerrors.Errorf("failed to parse private key: %w", err), nil
+		return nil, xerrors.Errorf("failed to parse private key: %w", err)
 	}
 
 	now := time.Now()
@@ -302,7 +329,7 @@ func signJwt(privateKey string, clientId string) (error, *string) {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	jwtToken, err := token.SignedString(rsaPrivateKey)
 	if err != nil {
-		return xerrors.Errorf("failed to sign token: %w", err), nil
+		return nil, xerrors.Errorf("failed to sign token: %w", err)
 	}
-	return nil, &jwtToken
+	return &jwtToken, nil
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHubAPIURL(t *testing.T) {
+	type in struct {
+		registrationURL string
+	}
+
+	type want struct {
+		apiURL string
+		err    bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"github.com",
+			in{
+				"https://github.com/kaidotdev/github-actions-runner-controller",
+			},
+			want{
+				"https://api.github.com",
+				false,
+			},
+		},
+		{
+			"GitHub Enterprise Server",
+			in{
+				"https://github.example.com/kaidotdev/github-actions-runner-controller",
+			},
+			want{
+				"https://github.example.com/api/v3",
+				false,
+			},
+		},
+		{
+			"GitHub Enterprise Server with port",
+			in{
+				"http://github.example.com:8080/kaidotdev",
+			},
+			want{
+				"http://github.example.com:8080/api/v3",
+				false,
+			},
+		},
+		{
+			"relative",
+			in{
+				"kaidotdev/github-actions-runner-controller",
+			},
+			want{
+				"",
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := githubAPIURL(tt.in.registrationURL)
+			if (err != nil) != tt.want.err {
+				t.Fatalf("githubAPIURL() error = %v, want error %v", err, tt.want.err)
+			}
+			if got != tt.want.apiURL {
+				t.Errorf("githubAPIURL() = %q, want %q", got, tt.want.apiURL)
+			}
+		})
+	}
+}
+
+func TestGetTokens(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghs_example" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/v3/repos/kaidotdev/github-actions-runner-controller/actions/runners/registration-token":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprint(w, `{"token":"registration"}`)
+		case "POST /api/v3/repos/kaidotdev/github-actions-runner-controller/actions/runners/remove-token":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprint(w, `{"token":"remove"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Tokens are requested to GitHub API of the host of the registration URL, as on GitHub Enterprise Server
+	apiURL, err := githubAPIURL(server.URL + "/kaidotdev/github-actions-runner-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getRegistrationToken(apiURL, "kaidotdev/github-actions-runner-controller", "ghs_example"); got != "registration" {
+		t.Errorf("getRegistrationToken() = %q, want %q", got, "registration")
+	}
+	if got := getRemoveToken(apiURL, "kaidotdev/github-actions-runner-controller", "ghs_example"); got != "remove" {
+		t.Errorf("getRemoveToken() = %q, want %q", got, "remove")
+	}
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	buildImageLabel = "github-actions-runner.kaidotio.github.io/build-image"
+
+	// Finished build jobs are kept for a while to inspect their logs, and failed ones are retried after deleted
+	buildJobTTLSecondsAfterFinished = 3600
+	buildJobBackoffLimit            = 1
+)
+
+// reconcileBuildJob runs the build job of the runner image in the build namespace,
+// so that the privileged builder container is isolated from the namespace of the runner.
+// It returns whether the image is built and the deployment can be rolled out with it.
+// Build jobs are named after the image and shared by runners building the same one,
+// and they are not owned by runners since owner references cannot cross namespaces.
+func (r *RunnerReconciler) reconcileBuildJob(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (bool, error) {
+	if runner.Spec.BuildNamespace == "" || runner.Spec.SkipBuild || runner.Spec.PreBuiltImage != "" {
+		return true, nil
+	}
+
+	var job batchV1.Job
+	if err := r.Get(ctx, client.ObjectKey{Name: buildJobName(r.buildRepositoryName(runner)), Namespace: runner.Spec.BuildNamespace}, &job); apierrors.IsNotFound(err) {
+		job = *r.buildBuildJob(runner)
+		if err := r.Create(ctx, &job); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create build job %q in namespace %q: %v", job.Name, job.Namespace, err)
+			return false, err
+		}
+		r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created build job: %q in namespace %q", job.Name, job.Namespace), "")
+		logger.V(1).Info("create", "job", job)
+		// The job is never created in dry-run mode, so the deployment is proposed without waiting for it
+		return r.DryRun, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchV1.JobFailed && condition.Status == coreV1.ConditionTrue {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonBuildFailed, "Build job %q in namespace %q failed, and the deployment is not rolled out until it is retried after removed: %s", job.Name, job.Namespace, condition.Message)
+			return false, nil
+		}
+	}
+	logger.Info("wait for build job to complete", "job", job.Name, "namespace", job.Namespace)
+	return false, nil
+}
+
+func buildJobName(repositoryName string) string {
+	return "build-" + repositoryName
+}
+
+// buildBuildJob builds the job running the builder container in the build namespace.
+// The Dockerfile is written into an empty dir by the workspace container since the workspace of the runner is in another namespace,
+// and volumes mounted by the builder container as well as the builder service account must exist in the build namespace.
+func (r *RunnerReconciler) buildBuildJob(runner *garV1.Runner) *batchV1.Job {
+	builder := r.buildBuilderContainer(runner)
+	volumes := []coreV1.Volume{
+		{
+			Name: "workspace",
+			VolumeSource: coreV1.VolumeSource{
+				EmptyDir: &coreV1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	for _, volume := range runner.Spec.Template.Spec.Volumes {
+		for _, volumeMount := range builder.VolumeMounts {
+			if volume.Name == volumeMount.Name {
+				volumes = append(volumes, volume)
+				break
+			}
+		}
+	}
+
+	repositoryName := r.buildRepositoryName(runner)
+	labels := map[string]string{
+		buildImageLabel: repositoryName,
+	}
+	return &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        buildJobName(repositoryName),
+			Namespace:   runner.Spec.BuildNamespace,
+			Labels:      mergeMetadata(runner.Spec.ManagedResourceLabels, labels),
+			Annotations: mergeMetadata(runner.Spec.ManagedResourceAnnotations, nil),
+		},
+		Spec: batchV1.JobSpec{
+			BackoffLimit:            func(i int32) *int32 { return &i }(buildJobBackoffLimit),
+			TTLSecondsAfterFinished: func(i int32) *int32 { return &i }(buildJobTTLSecondsAfterFinished),
+			ActiveDeadlineSeconds:   buildActiveDeadlineSeconds(runner.Spec.BuilderContainerSpec.BuildTimeout, buildJobBackoffLimit),
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: metaV1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: coreV1.PodSpec{
+					InitContainers:     []coreV1.Container{r.buildWorkspaceContainer(runner)},
+					Containers:         []coreV1.Container{builder},
+					Volumes:            volumes,
+					RestartPolicy:      coreV1.RestartPolicyNever,
+					ServiceAccountName: runner.Spec.BuilderServiceAccountName,
+				},
+			},
+		},
+	}
+}
+
+// buildJobPredicate passes deletion of build jobs in addition to their completion or failure,
+// so that runners waiting for failed build jobs recreate them once they are removed after their TTL
+func buildJobPredicate() predicate.Predicate {
+	return predicate.Or(jobFinishedPredicate(), predicate.Funcs{
+		UpdateFunc: func(event.UpdateEvent) bool {
+			return false
+		},
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	})
+}
+
+// mapBuildJobToRunners fans out completion and deletion of build jobs to the runners building in their namespace
+func (r *RunnerReconciler) mapBuildJobToRunners(ctx context.Context, obj client.Object) []reconcile.Request {
+	if _, ok := obj.GetLabels()[buildImageLabel]; !ok {
+		return nil
+	}
+
+	var runners garV1.RunnerList
+	if err := r.List(ctx, &runners); err != nil {
+		r.Log.Error(err, "failed to list runners on completion of build job")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, runner := range runners.Items {
+		if runner.Spec.BuildNamespace != obj.GetNamespace() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&runner),
+		})
+	}
+	return requests
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestRunnerReconcilerReconcileBuildJob(t *testing.T) {
+	type in struct {
+		status batchV1.JobStatus
+	}
+
+	type want struct {
+		deployed bool
+		event    string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"succeeded",
+			in{
+				batchV1.JobStatus{
+					Succeeded: 1,
+				},
+			},
+			want{
+				true,
+				"",
+			},
+		},
+		{
+			"running",
+			in{
+				batchV1.JobStatus{
+					Active: 1,
+				},
+			},
+			want{
+				false,
+				"",
+			},
+		},
+		{
+			"failed",
+			in{
+				batchV1.JobStatus{
+					Failed: 2,
+					Conditions: []batchV1.JobCondition{
+						{
+							Type:    batchV1.JobFailed,
+							Status:  v1.ConditionTrue,
+							Message: "Job has reached the specified backoff limit",
+						},
+					},
+				},
+			},
+			want{
+				false,
+				EventReasonBuildFailed,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:                     "ubuntu:22.04",
+					Repository:                "kaidotdev/github-actions-runner-controller",
+					BuildNamespace:            "build",
+					BuilderServiceAccountName: "kaniko",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			r := newTestRunnerReconciler(t, runner)
+			recorder := record.NewFakeRecorder(100)
+			r.Recorder = recorder
+			ctx := context.Background()
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      runner.Name,
+					Namespace: runner.Namespace,
+				},
+			}
+			deploymentKey := types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			var job batchV1.Job
+			if err := r.Get(ctx, types.NamespacedName{Name: buildJobName(r.buildRepositoryName(runner)), Namespace: "build"}, &job); err != nil {
+				t.Fatal(err)
+			}
+			podSpec := job.Spec.Template.Spec
+			if len(podSpec.Containers) != 1 || podSpec.Containers[0].Name != "kaniko" {
+				t.Errorf("containers = %v, want kaniko", podSpec.Containers)
+			}
+			if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != "workspace" {
+				t.Errorf("initContainers = %v, want workspace writing Dockerfile", podSpec.InitContainers)
+			}
+			if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].EmptyDir == nil {
+				t.Errorf("volumes = %v, want workspace of empty dir", podSpec.Volumes)
+			}
+			if podSpec.ServiceAccountName != "kaniko" {
+				t.Errorf("serviceAccountName = %q, want builder service account", podSpec.ServiceAccountName)
+			}
+			if len(job.OwnerReferences) != 0 {
+				t.Errorf("ownerReferences = %v, want none across namespaces", job.OwnerReferences)
+			}
+			if err := r.Get(ctx, deploymentKey, &appsV1.Deployment{}); !apierrors.IsNotFound(err) {
+				t.Errorf("deployment must not be created before the build job finishes: %v", err)
+			}
+			var warmup batchV1.Job
+			if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-warmup", Namespace: runner.Namespace}, &warmup); !apierrors.IsNotFound(err) {
+				t.Errorf("warm-up job must not be created when building in another namespace: %v", err)
+			}
+
+			job.Status = tt.in.status
+			if err := r.Status().Update(ctx, &job); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			var deployment appsV1.Deployment
+			err := r.Get(ctx, deploymentKey, &deployment)
+			if tt.want.deployed {
+				if err != nil {
+					t.Fatalf("deployment must be created after the build job succeeds: %v", err)
+				}
+				for _, container := range deployment.Spec.Template.Spec.InitContainers {
+					if container.Name == "kaniko" {
+						t.Error("kaniko must not run in runner pods when building in another namespace")
+					}
+				}
+			} else if !apierrors.IsNotFound(err) {
+				t.Errorf("deployment must not be created before the build job succeeds: %v", err)
+			}
+
+			var recorded bool
+			for len(recorder.Events) > 0 {
+				if event := <-recorder.Events; strings.Contains(event, EventReasonBuildFailed) {
+					recorded = true
+				}
+			}
+			if recorded != (tt.want.event != "") {
+				t.Errorf("%s event recorded = %v, want %v", EventReasonBuildFailed, recorded, tt.want.event != "")
+			}
+		})
+	}
+}
+
+func TestBuildJobPredicate(t *testing.T) {
+	type in struct {
+		event string
+		old   batchV1.JobStatus
+		new   batchV1.JobStatus
+	}
+
+	type want struct {
+		passed bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"created",
+			in{
+				"create",
+				batchV1.JobStatus{},
+				batchV1.JobStatus{},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"running",
+			in{
+				"update",
+				batchV1.JobStatus{},
+				batchV1.JobStatus{
+					Active: 1,
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"failed",
+			in{
+				"update",
+				batchV1.JobStatus{
+					Active: 1,
+				},
+				batchV1.JobStatus{
+					Failed: 2,
+					Conditions: []batchV1.JobCondition{
+						{
+							Type:   batchV1.JobFailed,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"removed after TTL",
+			in{
+				"delete",
+				batchV1.JobStatus{},
+				batchV1.JobStatus{},
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			oldJob := &batchV1.Job{Status: tt.in.old}
+			newJob := &batchV1.Job{Status: tt.in.new}
+			var got bool
+			switch tt.in.event {
+			case "create":
+				got = buildJobPredicate().Create(event.CreateEvent{Object: newJob})
+			case "update":
+				got = buildJobPredicate().Update(event.UpdateEvent{ObjectOld: oldJob, ObjectNew: newJob})
+			case "delete":
+				got = buildJobPredicate().Delete(event.DeleteEvent{Object: oldJob})
+			}
+			if got != tt.want.passed {
+				t.Errorf("passed = %v, want %v", got, tt.want.passed)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/xerrors"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// CertificateReloader serves the webhook server with the certificate of a TLS secret, e.g. issued by cert-manager,
+// and swaps in the renewed one on changes of the secret without restart.
+// It runs on every replica regardless of leader election since all of them serve webhooks.
+type CertificateReloader struct {
+	client.Client
+	Log       logr.Logger
+	SecretRef types.NamespacedName
+
+	certificate atomic.Pointer[tls.Certificate]
+}
+
+func (r *CertificateReloader) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("secret", req.NamespacedName)
+
+	var secret coreV1.Secret
+	if err := r.Get(ctx, req.NamespacedName, &secret); err != nil {
+		// The certificate loaded last is kept serving until the secret is recreated
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if err := r.load(&secret); err != nil {
+		logger.Error(err, "failed to reload certificate")
+		return ctrl.Result{}, nil
+	}
+	logger.Info("reload certificate", "resourceVersion", secret.ResourceVersion)
+
+	return ctrl.Result{}, nil
+}
+
+func (r *CertificateReloader) load(secret *coreV1.Secret) error {
+	certificate, err := tls.X509KeyPair(secret.Data[coreV1.TLSCertKey], secret.Data[coreV1.TLSPrivateKeyKey])
+	if err != nil {
+		return xerrors.Errorf("failed to parse certificate of secret %q: %w", secret.Name, err)
+	}
+	r.certificate.Store(&certificate)
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate with the certificate loaded last
+func (r *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate := r.certificate.Load()
+	if certificate == nil {
+		return nil, xerrors.Errorf("certificate of secret %q is not loaded yet", r.SecretRef)
+	}
+	return certificate, nil
+}
+
+// ConfigureTLS is passed to webhook.Options.TLSOpts to serve the certificate of the secret
+func (r *CertificateReloader) ConfigureTLS(config *tls.Config) {
+	config.GetCertificate = r.GetCertificate
+}
+
+// SetupWithManager loads the certificate by the API reader before the cache starts,
+// since the webhook server accepts requests before controllers are started.
+func (r *CertificateReloader) SetupWithManager(mgr ctrl.Manager) error {
+	var secret coreV1.Secret
+	if err := mgr.GetAPIReader().Get(context.Background(), r.SecretRef, &secret); err != nil {
+		return xerrors.Errorf("failed to get secret %q: %w", r.SecretRef, err)
+	}
+	if err := r.load(&secret); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("certificate-reloader").
+		For(&coreV1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return client.ObjectKeyFromObject(obj) == r.SecretRef
+		}))).
+		WithOptions(controller.Options{
+			NeedLeaderElection: func(b bool) *bool { return &b }(false),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func generateTestCertificate(t *testing.T, commonName string) (certPEM []byte, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestCertificateReloaderReconcile(t *testing.T) {
+	oldCert, oldKey := generateTestCertificate(t, "old")
+	secret := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "webhook-tls",
+			Namespace: "github-actions-runner-controller",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       oldCert,
+			v1.TLSPrivateKeyKey: oldKey,
+		},
+	}
+	r := &CertificateReloader{
+		Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(secret).Build(),
+		Log:    logr.Discard(),
+		SecretRef: types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+		},
+	}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: r.SecretRef}
+
+	if _, err := r.GetCertificate(nil); err == nil {
+		t.Error("GetCertificate() must fail before the certificate is loaded")
+	}
+
+	served := func() []byte {
+		t.Helper()
+
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		certificate, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return certificate.Certificate[0]
+	}
+	decode := func(certPEM []byte) []byte {
+		block, _ := pem.Decode(certPEM)
+		return block.Bytes
+	}
+
+	if got := served(); !bytes.Equal(got, decode(oldCert)) {
+		t.Error("the certificate of the secret is not served")
+	}
+
+	newCert, newKey := generateTestCertificate(t, "new")
+	secret.Data[v1.TLSCertKey] = newCert
+	secret.Data[v1.TLSPrivateKeyKey] = newKey
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := served(); !bytes.Equal(got, decode(newCert)) {
+		t.Error("the renewed certificate is not served")
+	}
+
+	secret.Data[v1.TLSPrivateKeyKey] = oldKey
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := served(); !bytes.Equal(got, decode(newCert)) {
+		t.Error("the certificate loaded last must be kept when the secret is invalid")
+	}
+}
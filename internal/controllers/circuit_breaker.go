@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrCircuitOpen is returned instead of calling GitHub API while it is considered unreachable
+var ErrCircuitOpen = xerrors.New("circuit breaker for GitHub API is open")
+
+// circuitBreaker stops calls to GitHub API for a while after consecutive failures to reach it,
+// which prevents every reconciliation from failing and being requeued rapidly during outages
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allow returns ErrCircuitOpen while the circuit is open
+func (c *circuitBreaker) allow(now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// success closes the circuit
+func (c *circuitBreaker) success() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures = 0
+	c.openUntil = time.Time{}
+}
+
+// failure opens the circuit for timeout when failures reach threshold
+func (c *circuitBreaker) failure(now time.Time, threshold int, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures++
+	if c.failures >= threshold {
+		c.openUntil = now.Add(timeout)
+	}
+}
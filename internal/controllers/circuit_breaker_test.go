@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+
+	type in struct {
+		results []bool
+		elapsed time.Duration
+	}
+
+	type want struct {
+		err error
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"closed below threshold",
+			in{
+				[]bool{false, false},
+				0,
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"open at threshold",
+			in{
+				[]bool{false, false, false},
+				0,
+			},
+			want{
+				ErrCircuitOpen,
+			},
+		},
+		{
+			"closed after timeout",
+			in{
+				[]bool{false, false, false},
+				time.Minute,
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"closed by success",
+			in{
+				[]bool{false, false, true, false},
+				0,
+			},
+			want{
+				nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var c circuitBreaker
+			for _, success := range tt.in.results {
+				if success {
+					c.success()
+				} else {
+					c.failure(now, 3, time.Minute)
+				}
+			}
+
+			if err := c.allow(now.Add(tt.in.elapsed)); !xerrors.Is(err, tt.want.err) {
+				t.Errorf("allow() = %v, want %v", err, tt.want.err)
+			}
+		})
+	}
+}
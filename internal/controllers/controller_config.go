@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"golang.org/x/xerrors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Keys of the controller config map, which override the flags of the same names
+const (
+	configKeyPushRegistryHost    = "push-registry-host"
+	configKeyPullRegistryHost    = "pull-registry-host"
+	configKeyEnableRunnerMetrics = "enable-runner-metrics"
+	configKeyExporterImage       = "exporter-image"
+	configKeyKanikoImage         = "kaniko-image"
+	configKeyWorkspaceImage      = "workspace-image"
+	configKeyBinaryVersion       = "binary-version"
+	configKeyDisableupdate       = "disableupdate"
+)
+
+// controllerConfig is the part of RunnerReconciler reconfigurable by the controller config map
+type controllerConfig struct {
+	PushRegistryHost    string
+	PullRegistryHost    string
+	EnableRunnerMetrics bool
+	ExporterImage       string
+	KanikoImage         string
+	WorkspaceImage      string
+	BinaryVersion       string
+	Disableupdate       bool
+}
+
+// parseControllerConfig overrides defaults with data of the controller config map
+func parseControllerConfig(data map[string]string, defaults controllerConfig) (controllerConfig, error) {
+	config := defaults
+	for key, value := range data {
+		switch key {
+		case configKeyPushRegistryHost:
+			config.PushRegistryHost = value
+		case configKeyPullRegistryHost:
+			config.PullRegistryHost = value
+		case configKeyEnableRunnerMetrics:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return controllerConfig{}, xerrors.Errorf("failed to parse %q: %w", key, err)
+			}
+			config.EnableRunnerMetrics = b
+		case configKeyExporterImage:
+			config.ExporterImage = value
+		case configKeyKanikoImage:
+			config.KanikoImage = value
+		case configKeyWorkspaceImage:
+			config.WorkspaceImage = value
+		case configKeyBinaryVersion:
+			config.BinaryVersion = value
+		case configKeyDisableupdate:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return controllerConfig{}, xerrors.Errorf("failed to parse %q: %w", key, err)
+			}
+			config.Disableupdate = b
+		default:
+			return controllerConfig{}, xerrors.Errorf("unknown key %q", key)
+		}
+	}
+	return config, nil
+}
+
+func (r *RunnerReconciler) currentConfig() controllerConfig {
+	return controllerConfig{
+		PushRegistryHost:    r.PushRegistryHost,
+		PullRegistryHost:    r.PullRegistryHost,
+		EnableRunnerMetrics: r.EnableRunnerMetrics,
+		ExporterImage:       r.ExporterImage,
+		KanikoImage:         r.KanikoImage,
+		WorkspaceImage:      r.WorkspaceImage,
+		BinaryVersion:       r.BinaryVersion,
+		Disableupdate:       r.Disableupdate,
+	}
+}
+
+func (r *RunnerReconciler) applyConfig(config controllerConfig) {
+	r.PushRegistryHost = config.PushRegistryHost
+	r.PullRegistryHost = config.PullRegistryHost
+	r.EnableRunnerMetrics = config.EnableRunnerMetrics
+	r.ExporterImage = config.ExporterImage
+	r.KanikoImage = config.KanikoImage
+	r.WorkspaceImage = config.WorkspaceImage
+	r.BinaryVersion = config.BinaryVersion
+	r.Disableupdate = config.Disableupdate
+}
+
+// loadConfig applies the controller config map when it has changed since the last load.
+// Keys removed from the config map fall back to the values configured at startup.
+func (r *RunnerReconciler) loadConfig(ctx context.Context) error {
+	if r.ConfigMapRef.Name == "" {
+		return nil
+	}
+	r.defaultConfigOnce.Do(func() {
+		r.defaultConfig = r.currentConfig()
+	})
+
+	var configMap v1.ConfigMap
+	if err := r.Get(ctx, r.ConfigMapRef, &configMap); err != nil && !apierrors.IsNotFound(err) {
+		return xerrors.Errorf("failed to get config map %q: %w", r.ConfigMapRef, err)
+	}
+
+	r.configMu.RLock()
+	changed := configMap.ResourceVersion != r.configResourceVersion
+	r.configMu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	config, err := parseControllerConfig(configMap.Data, r.defaultConfig)
+	if err != nil {
+		return xerrors.Errorf("invalid config map %q: %w", r.ConfigMapRef, err)
+	}
+
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	r.applyConfig(config)
+	r.configResourceVersion = configMap.ResourceVersion
+	return nil
+}
+
+// mapConfigMapToRunners fans out changes of the controller config map to all runners
+func (r *RunnerReconciler) mapConfigMapToRunners(ctx context.Context, obj client.Object) []reconcile.Request {
+	if client.ObjectKeyFromObject(obj) != r.ConfigMapRef {
+		return nil
+	}
+
+	var runners garV1.RunnerList
+	if err := r.List(ctx, &runners); err != nil {
+		r.Log.Error(err, "failed to list runners on changes of config map")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(runners.Items))
+	for _, runner := range runners.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&runner),
+		})
+	}
+	return requests
+}
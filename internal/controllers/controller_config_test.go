@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseControllerConfig(t *testing.T) {
+	defaults := controllerConfig{
+		PushRegistryHost: "registry.example.com",
+		PullRegistryHost: "127.0.0.1:5000",
+		ExporterImage:    "exporter",
+	}
+
+	type in struct {
+		data map[string]string
+	}
+
+	type want struct {
+		config controllerConfig
+		err    bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"empty",
+			in{
+				nil,
+			},
+			want{
+				defaults,
+				false,
+			},
+		},
+		{
+			"override",
+			in{
+				map[string]string{
+					"exporter-image":        "exporter:v2",
+					"enable-runner-metrics": "true",
+				},
+			},
+			want{
+				controllerConfig{
+					PushRegistryHost:    "registry.example.com",
+					PullRegistryHost:    "127.0.0.1:5000",
+					EnableRunnerMetrics: true,
+					ExporterImage:       "exporter:v2",
+				},
+				false,
+			},
+		},
+		{
+			"invalid bool",
+			in{
+				map[string]string{
+					"disableupdate": "yes please",
+				},
+			},
+			want{
+				controllerConfig{},
+				true,
+			},
+		},
+		{
+			"unknown key",
+			in{
+				map[string]string{
+					"push-registry": "registry.example.com",
+				},
+			},
+			want{
+				controllerConfig{},
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseControllerConfig(tt.in.data, defaults)
+			if (err != nil) != tt.want.err {
+				t.Fatalf("parseControllerConfig() error = %v, want error %v", err, tt.want.err)
+			}
+			if !reflect.DeepEqual(got, tt.want.config) {
+				t.Errorf("parseControllerConfig() = %+v, want %+v", got, tt.want.config)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerLoadConfig(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "config",
+			Namespace: "controller",
+		},
+		Data: map[string]string{
+			"exporter-image": "exporter:v2",
+		},
+	}
+	r := newTestRunnerReconciler(t, configMap)
+	r.ConfigMapRef = types.NamespacedName{
+		Name:      "config",
+		Namespace: "controller",
+	}
+
+	ctx := context.Background()
+	if err := r.loadConfig(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.ExporterImage != "exporter:v2" {
+		t.Errorf("ExporterImage = %q, want %q", r.ExporterImage, "exporter:v2")
+	}
+
+	if err := r.Delete(ctx, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.loadConfig(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.ExporterImage != "exporter" {
+		t.Errorf("ExporterImage = %q, want %q after deletion of config map", r.ExporterImage, "exporter")
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/xerrors"
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	drainOnDeleteAnnotation = "github-actions-runner.kaidotio.github.io/drain-on-delete"
+	drainFinalizer          = "github-actions-runner.kaidotio.github.io/drain"
+	drainPollInterval       = 30 * time.Second
+	githubRunnersPerPage    = 100
+)
+
+// syncDrainFinalizer adds the drain finalizer to runners annotated with drain-on-delete and removes it from the others
+func (r *RunnerReconciler) syncDrainFinalizer(ctx context.Context, runner *garV1.Runner) error {
+	drainOnDelete := runner.Annotations[drainOnDeleteAnnotation] == "true"
+	if drainOnDelete == controllerutil.ContainsFinalizer(runner, drainFinalizer) {
+		return nil
+	}
+
+	patch := client.MergeFrom(runner.DeepCopy())
+	if drainOnDelete {
+		controllerutil.AddFinalizer(runner, drainFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(runner, drainFinalizer)
+	}
+	return r.Patch(ctx, runner, patch)
+}
+
+// drain scales the deployment of the deleted runner to zero so that it picks up no new job,
+// and removes the drain finalizer to let the deletion proceed once none of its runners is busy on GitHub.
+// Removing the drain-on-delete annotation lets the deletion proceed immediately.
+func (r *RunnerReconciler) drain(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (ctrl.Result, error) {
+	if runner.Annotations[drainOnDeleteAnnotation] != "true" {
+		return ctrl.Result{}, r.removeDrainFinalizer(ctx, runner, logger)
+	}
+
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); apierrors.IsNotFound(err) {
+		return ctrl.Result{}, r.removeDrainFinalizer(ctx, runner, logger)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 {
+		patch := client.MergeFrom(deployment.DeepCopy())
+		deployment.Spec.Replicas = func(i int32) *int32 { return &i }(0)
+		if err := r.Patch(ctx, &deployment, patch); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to scale deployment %q to zero: %v", deployment.Name, err)
+			return ctrl.Result{}, err
+		}
+		r.recordChange(runner, logger, EventReasonUpdated, fmt.Sprintf("Scaled deployment to zero to drain: %q", deployment.Name), "")
+	}
+
+	// Runners without credentials usable by the controller, e.g. ones with appSecretRef, are regarded as busy until the deployment has no replicas
+	token, err := r.getAPIToken(ctx, runner)
+	if err != nil {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDrainFailed, "Failed to get token to check jobs in progress: %v", err)
+		logger.Error(err, "failed to get token to check jobs in progress")
+		return ctrl.Result{RequeueAfter: drainPollInterval}, nil
+	}
+	busy := int(deployment.Status.Replicas)
+	if token != "" {
+		busy, err = r.countBusyRunners(ctx, token, runner.Spec.Repository, deployment.Name+"-")
+		if err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDrainFailed, "Failed to check jobs in progress: %v", err)
+			logger.Error(err, "failed to check jobs in progress")
+			return ctrl.Result{RequeueAfter: drainPollInterval}, nil
+		}
+	}
+	if busy > 0 {
+		r.Recorder.Eventf(runner, coreV1.EventTypeNormal, EventReasonDraining, "Waiting for %d runners to finish jobs in progress", busy)
+		return ctrl.Result{RequeueAfter: drainPollInterval}, nil
+	}
+
+	return ctrl.Result{}, r.removeDrainFinalizer(ctx, runner, logger)
+}
+
+func (r *RunnerReconciler) removeDrainFinalizer(ctx context.Context, runner *garV1.Runner, logger logr.Logger) error {
+	if !controllerutil.ContainsFinalizer(runner, drainFinalizer) {
+		return nil
+	}
+	patch := client.MergeFrom(runner.DeepCopy())
+	controllerutil.RemoveFinalizer(runner, drainFinalizer)
+	if err := r.Patch(ctx, runner, patch); err != nil {
+		return err
+	}
+	r.recordChange(runner, logger, EventReasonDrained, "Drained runner to be deleted", "")
+	return nil
+}
+
+// countBusyRunners counts runners of the repository registered by pods with the prefix, which are running jobs
+func (r *RunnerReconciler) countBusyRunners(ctx context.Context, token string, repository string, prefix string) (int, error) {
+	var busy int
+	for page := 1; ; page++ {
+		runners, err := r.listGitHubRunners(ctx, token, repository, page)
+		if err != nil {
+			return 0, err
+		}
+		busy += runners.countBusy(prefix)
+		if len(runners.Runners) < githubRunnersPerPage {
+			return busy, nil
+		}
+	}
+}
+
+func (r *RunnerReconciler) listGitHubRunners(ctx context.Context, token string, repository string, page int) (*githubRunners, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/actions/runners?per_page=%d&page=%d", repository, githubRunnersPerPage, page), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if err := r.githubCircuit.allow(time.Now()); err != nil {
+		return nil, err
+	}
+	_, requestSpan := r.Tracer.Start(ctx, "GET /repos/{owner}/{repo}/actions/runners", trace.WithSpanKind(trace.SpanKindClient))
+	response, err := r.httpClient().Do(request)
+	if err != nil {
+		endSpan(requestSpan, err)
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+		r.recordGitHubAPIResult(false)
+		return nil, xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	requestSpan.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+	endSpan(requestSpan, nil)
+	if response.StatusCode >= http.StatusInternalServerError {
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+	} else {
+		r.githubCircuit.success()
+	}
+
+	r.recordGitHubAPIResult(response.StatusCode == http.StatusOK)
+	if response.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("failed to list runners: %d", response.StatusCode)
+	}
+
+	var runners githubRunners
+	if err := json.NewDecoder(response.Body).Decode(&runners); err != nil {
+		return nil, xerrors.Errorf("failed to decode runners: %w", err)
+	}
+	return &runners, nil
+}
+
+// getAPIToken returns the token used by the runner, or empty when the controller cannot get it
+func (r *RunnerReconciler) getAPIToken(ctx context.Context, runner *garV1.Runner) (string, error) {
+	selector := runner.Spec.TokenSecretKeyRef
+	if runner.Spec.PersonalAccessTokenRef != nil {
+		selector = &runner.Spec.PersonalAccessTokenRef.SecretRef
+	}
+	if selector == nil {
+		if runner.Spec.AppSecretRef != nil || !r.gitHubAppConfigured() {
+			return "", nil
+		}
+		tokenSecret, err := r.createTokenSecret(ctx, runner)
+		if err != nil {
+			return "", err
+		}
+		return tokenSecret.StringData["GITHUB_TOKEN"], nil
+	}
+
+	var secret coreV1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Name: selector.Name, Namespace: runner.Namespace}, &secret); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret.Data[selector.Key])), nil
+}
+
+type githubRunners struct {
+	Runners []struct {
+		Name string `json:"name"`
+		Busy bool   `json:"busy"`
+	} `json:"runners"`
+}
+
+func (g githubRunners) countBusy(prefix string) int {
+	var busy int
+	for _, runner := range g.Runners {
+		if runner.Busy && strings.HasPrefix(runner.Name, prefix) {
+			busy++
+		}
+	}
+	return busy
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	appsV1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestGitHubRunnersCountBusy(t *testing.T) {
+	runners := githubRunners{}
+	for _, runner := range []struct {
+		name string
+		busy bool
+	}{
+		{"example-runner-5d4f8b7c6-abcde", true},
+		{"example-runner-5d4f8b7c6-fghij", false},
+		{"example-runner-5d4f8b7c6-klmno-1", true},
+		{"other-runner-6f5e4d3c2-abcde", true},
+	} {
+		runners.Runners = append(runners.Runners, struct {
+			Name string `json:"name"`
+			Busy bool   `json:"busy"`
+		}{runner.name, runner.busy})
+	}
+
+	if got := runners.countBusy("example-runner-"); got != 2 {
+		t.Errorf("countBusy() = %d, want %d", got, 2)
+	}
+}
+
+func TestRunnerReconcilerReconcileDrainOnDelete(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+			Annotations: map[string]string{
+				drainOnDeleteAnnotation: "true",
+			},
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	deploymentKey := client.ObjectKey{
+		Name:      runner.Name + "-runner",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(runner, drainFinalizer) {
+		t.Fatalf("finalizers = %v, want %s", runner.Finalizers, drainFinalizer)
+	}
+
+	// Pods are still running until the deployment reports no replicas, since there are no credentials to ask GitHub
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	deployment.Status.Replicas = 1
+	if err := r.Status().Update(ctx, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != drainPollInterval {
+		t.Errorf("requeueAfter = %s, want %s while draining", result.RequeueAfter, drainPollInterval)
+	}
+	if err := r.Get(ctx, deploymentKey, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 {
+		t.Errorf("replicas = %v, want 0 while draining", deployment.Spec.Replicas)
+	}
+	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
+		t.Fatalf("runner must not be deleted while draining: %v", err)
+	}
+
+	deployment.Status.Replicas = 0
+	if err := r.Status().Update(ctx, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, req.NamespacedName, runner); !apierrors.IsNotFound(err) {
+		t.Errorf("runner must be deleted after draining: %v", err)
+	}
+}
+
+func TestRunnerReconcilerReconcileDrainCancelled(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:       "example",
+			Namespace:  "default",
+			Finalizers: []string{drainFinalizer},
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	if err := r.Delete(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, req.NamespacedName, runner); !apierrors.IsNotFound(err) {
+		t.Errorf("runner without drain-on-delete annotation must be deleted immediately: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+// Reasons of events recorded on Runner, RunnerClass, and RunnerScaler
+const (
+	// EventReasonCreated is recorded when a resource owned by a runner is created
+	EventReasonCreated = "SuccessfulCreated"
+	// EventReasonUpdated is recorded when a resource owned by a runner is updated
+	EventReasonUpdated = "SuccessfulUpdated"
+	// EventReasonDeleted is recorded when a stale resource owned by a runner is deleted
+	EventReasonDeleted = "SuccessfulDeleted"
+	// EventReasonDryRunProposedChange is recorded instead of the above in dry-run mode
+	EventReasonDryRunProposedChange = "DryRunProposedChange"
+	// EventReasonCreateFailed is recorded when a resource owned by a runner fails to be created
+	EventReasonCreateFailed = "CreateFailed"
+	// EventReasonUpdateFailed is recorded when a resource owned by a runner fails to be updated
+	EventReasonUpdateFailed = "UpdateFailed"
+	// EventReasonDeleteFailed is recorded when a stale resource owned by a runner fails to be deleted
+	EventReasonDeleteFailed = "DeleteFailed"
+	// EventReasonInvalidDeployment is recorded when an update of a deployment owned by a runner is rejected by validation of the API server
+	EventReasonInvalidDeployment = "InvalidDeployment"
+	// EventReasonResourceQuotaExceeded is recorded when creation of a deployment is postponed since it would exceed resource quotas
+	EventReasonResourceQuotaExceeded = "ResourceQuotaExceeded"
+	// EventReasonImageDigestResolved is recorded when the image of a runner pinning its digest resolves to another digest
+	EventReasonImageDigestResolved = "ImageDigestResolved"
+	// EventReasonImageDigestResolutionFailed is recorded when the image of a runner pinning its digest fails to be resolved
+	EventReasonImageDigestResolutionFailed = "ImageDigestResolutionFailed"
+	// EventReasonWarmupFailed is recorded when the warm-up job of a new runner fails
+	EventReasonWarmupFailed = "WarmupFailed"
+	// EventReasonBuildFailed is recorded when the build job of a runner building in another namespace fails
+	EventReasonBuildFailed = "BuildFailed"
+	// EventReasonTokenRenewalFailed is recorded when the token secret issued by GitHub App fails to be renewed
+	EventReasonTokenRenewalFailed = "TokenRenewalFailed"
+	// EventReasonTokenSecretNotRenewed is recorded on startup for token secrets left after GitHub App is removed from the controller
+	EventReasonTokenSecretNotRenewed = "TokenSecretNotRenewed"
+	// EventReasonInvalidPersonalAccessToken is recorded when the personal access token secret is missing or empty
+	EventReasonInvalidPersonalAccessToken = "InvalidPersonalAccessToken"
+	// EventReasonInvalidRunnerClass is recorded when a runner class is invalid
+	EventReasonInvalidRunnerClass = "InvalidRunnerClass"
+	// EventReasonImageNotAllowed is recorded when the image of a runner matches none of the base images allowed by the controller
+	EventReasonImageNotAllowed = "ImageNotAllowed"
+	// EventReasonPrivileged is recorded when the runner container runs privileged by the security profile
+	EventReasonPrivileged = "Privileged"
+	// EventReasonPaused is recorded when reconciliation of a runner is skipped by the paused annotation
+	EventReasonPaused = "Paused"
+	// EventReasonDraining is recorded while a deleted runner annotated with drain-on-delete waits for jobs in progress
+	EventReasonDraining = "Draining"
+	// EventReasonDrained is recorded when a deleted runner annotated with drain-on-delete has no job in progress
+	EventReasonDrained = "Drained"
+	// EventReasonDrainFailed is recorded when jobs in progress of a deleted runner fail to be checked
+	EventReasonDrainFailed = "DrainFailed"
+	// EventReasonJobStarted is recorded when a runner pod notifies the job audit log that a job has started
+	EventReasonJobStarted = "JobStarted"
+	// EventReasonJobCompleted is recorded when a runner pod notifies the job audit log that a job has completed
+	EventReasonJobCompleted = "JobCompleted"
+	// EventReasonInvalidScaledObjectTemplate is recorded when the scaled object template of a runner scaler is invalid
+	EventReasonInvalidScaledObjectTemplate = "InvalidScaledObjectTemplate"
+)
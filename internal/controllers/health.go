@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordGitHubAPIResult records the time of the call to GitHub API checked by ReadyzCheck
+func (r *RunnerReconciler) recordGitHubAPIResult(success bool) {
+	if success {
+		r.lastGitHubAPISuccess.Store(time.Now().UnixNano())
+	} else {
+		r.lastGitHubAPIFailure.Store(time.Now().UnixNano())
+	}
+}
+
+// ReadyzCheck fails when calls to GitHub API with the GitHub App have kept failing for GitHubAPIReadinessThreshold,
+// which indicates invalid credentials or unreachable GitHub API.
+// It does not fail without calls, since tokens are cached and GitHub API is called only on renewal.
+func (r *RunnerReconciler) ReadyzCheck(_ *http.Request) error {
+	if r.GitHubAPIReadinessThreshold <= 0 {
+		return nil
+	}
+
+	lastSuccess := r.lastGitHubAPISuccess.Load()
+	lastFailure := r.lastGitHubAPIFailure.Load()
+	if lastFailure <= lastSuccess {
+		return nil
+	}
+	since := lastSuccess
+	if since == 0 {
+		since = r.startedAt.UnixNano()
+	}
+	if elapsed := time.Duration(lastFailure - since); elapsed > r.GitHubAPIReadinessThreshold {
+		return xerrors.Errorf("calls to GitHub API have kept failing for %s", elapsed.Round(time.Second))
+	}
+	return nil
+}
+
+// HealthzCheck fails when a reconciliation has been running longer than MaxReconcileAge,
+// which indicates the controller is stuck
+func (r *RunnerReconciler) HealthzCheck(_ *http.Request) error {
+	if r.MaxReconcileAge <= 0 {
+		return nil
+	}
+
+	var err error
+	r.inflightReconciles.Range(func(key, value any) bool {
+		if age := time.Since(value.(time.Time)); age > r.MaxReconcileAge {
+			err = xerrors.Errorf("reconciliation of runner %q has been running for %s", key.(types.NamespacedName), age.Round(time.Second))
+			return false
+		}
+		return true
+	})
+	return err
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRunnerReconcilerReadyzCheck(t *testing.T) {
+	now := time.Now()
+
+	type in struct {
+		lastSuccess time.Time
+		lastFailure time.Time
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"no calls",
+			in{
+				time.Time{},
+				time.Time{},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"succeeded last",
+			in{
+				now,
+				now.Add(-time.Hour),
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"failing within threshold",
+			in{
+				now.Add(-time.Minute),
+				now,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"failing beyond threshold",
+			in{
+				now.Add(-time.Hour),
+				now,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"failing since start",
+			in{
+				time.Time{},
+				now,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.GitHubAPIReadinessThreshold = 15 * time.Minute
+			r.startedAt = now.Add(-time.Hour)
+			if !tt.in.lastSuccess.IsZero() {
+				r.lastGitHubAPISuccess.Store(tt.in.lastSuccess.UnixNano())
+			}
+			if !tt.in.lastFailure.IsZero() {
+				r.lastGitHubAPIFailure.Store(tt.in.lastFailure.UnixNano())
+			}
+
+			if err := r.ReadyzCheck(nil); (err != nil) != tt.want.err {
+				t.Errorf("ReadyzCheck() = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerHealthzCheck(t *testing.T) {
+	type in struct {
+		age time.Duration
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"in progress",
+			in{
+				time.Minute,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"stuck",
+			in{
+				time.Hour,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.MaxReconcileAge = 10 * time.Minute
+			r.inflightReconciles.Store(types.NamespacedName{
+				Name:      "example",
+				Namespace: "default",
+			}, time.Now().Add(-tt.in.age))
+
+			if err := r.HealthzCheck(nil); (err != nil) != tt.want.err {
+				t.Errorf("HealthzCheck() = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
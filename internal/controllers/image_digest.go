@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	dockerref "github.com/docker/distribution/reference"
+	"github.com/go-logr/logr"
+	"golang.org/x/xerrors"
+	coreV1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Media types of manifests accepted on resolution, which determine the digest returned by registries
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var authenticateParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// reconcileImageDigest records the digest the runner image resolves to in the status of runners pinning it,
+// and clears it from the others.
+// The status is patched on a copy not to lose the values applied to the runner in memory.
+func (r *RunnerReconciler) reconcileImageDigest(ctx context.Context, runner *garV1.Runner, logger logr.Logger) error {
+	image := r.buildRunnerImage(runner)
+	var digest string
+	if runner.Spec.PinImageDigest {
+		var err error
+		digest, err = r.resolveImageDigest(ctx, image)
+		if err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonImageDigestResolutionFailed, "Failed to resolve digest of image %q: %v", image, err)
+			return err
+		}
+	}
+	if runner.Status.ResolvedImageDigest == digest {
+		return nil
+	}
+
+	latest := runner.DeepCopy()
+	patch := client.MergeFrom(latest.DeepCopy())
+	latest.Status.ResolvedImageDigest = digest
+	if err := r.Status().Patch(ctx, latest, patch); err != nil {
+		return err
+	}
+	runner.Status.ResolvedImageDigest = digest
+	if digest != "" {
+		r.recordChange(runner, logger, EventReasonImageDigestResolved, fmt.Sprintf("Resolved image %q to digest: %q", image, digest), "")
+	}
+	return nil
+}
+
+// pinImageDigest qualifies the image by the digest resolved last when the runner pins it
+func pinImageDigest(runner *garV1.Runner, image string) string {
+	if !runner.Spec.PinImageDigest || runner.Status.ResolvedImageDigest == "" {
+		return image
+	}
+	named, err := dockerref.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	if _, ok := named.(dockerref.Digested); ok {
+		return image
+	}
+	return image + "@" + runner.Status.ResolvedImageDigest
+}
+
+// resolveImageDigest returns the digest of the manifest the image refers to by Docker Registry HTTP API V2.
+// Registries requiring authentication are accessed with anonymous tokens, so images must be pullable without credentials.
+func (r *RunnerReconciler) resolveImageDigest(ctx context.Context, image string) (string, error) {
+	named, err := dockerref.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", xerrors.Errorf("failed to parse image: %w", err)
+	}
+	if digested, ok := named.(dockerref.Digested); ok {
+		return digested.Digest().String(), nil
+	}
+
+	host := dockerref.Domain(named)
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, dockerref.Path(named), dockerref.TagNameOnly(named).(dockerref.Tagged).Tag())
+
+	response, err := r.headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		token, err := r.fetchRegistryToken(ctx, response.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", err
+		}
+		response, err = r.headManifest(ctx, manifestURL, token)
+		if err != nil {
+			return "", err
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get manifest: %d", response.StatusCode)
+	}
+
+	digest := response.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", xerrors.New("registry returned no digest")
+	}
+	return digest, nil
+}
+
+func (r *RunnerReconciler) headManifest(ctx context.Context, manifestURL string, token string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "HEAD", manifestURL, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	response, err := r.httpClient().Do(request)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to do request: %w", err)
+	}
+	_ = response.Body.Close()
+	return response, nil
+}
+
+// fetchRegistryToken gets an anonymous token from the authorization server in the Bearer challenge
+func (r *RunnerReconciler) fetchRegistryToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", xerrors.Errorf("unsupported authentication challenge: %q", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range authenticateParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", xerrors.Errorf("invalid realm of authentication challenge: %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value, ok := params[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", realm.String(), nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	response, err := r.httpClient().Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get token: %d", response.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", xerrors.Errorf("failed to decode token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
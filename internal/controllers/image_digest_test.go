@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testImageDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// newTestRegistry serves the manifest of runner:v1 with anonymous tokens like Docker Hub
+func newTestRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:kaidotdev/runner:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+		case "/v2/kaidotdev/runner/manifests/v1":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:kaidotdev/runner:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", testImageDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunnerReconcilerResolveImageDigest(t *testing.T) {
+	server := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	type in struct {
+		image string
+	}
+
+	type want struct {
+		digest string
+		err    bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"tagged",
+			in{
+				host + "/kaidotdev/runner:v1",
+			},
+			want{
+				testImageDigest,
+				false,
+			},
+		},
+		{
+			"digested",
+			in{
+				"ghcr.io/kaidotdev/runner@" + testImageDigest,
+			},
+			want{
+				testImageDigest,
+				false,
+			},
+		},
+		{
+			"not found",
+			in{
+				host + "/kaidotdev/runner:v2",
+			},
+			want{
+				"",
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &RunnerReconciler{
+				HTTPClient: server.Client(),
+			}
+			got, err := r.resolveImageDigest(context.Background(), tt.in.image)
+			if (err != nil) != tt.want.err {
+				t.Fatalf("resolveImageDigest() error = %v, want error %v", err, tt.want.err)
+			}
+			if got != tt.want.digest {
+				t.Errorf("resolveImageDigest() = %q, want %q", got, tt.want.digest)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcilePinImageDigest(t *testing.T) {
+	server := newTestRegistry(t)
+	image := strings.TrimPrefix(server.URL, "https://") + "/kaidotdev/runner:v1"
+
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:          "ubuntu:22.04",
+			Repository:     "kaidotdev/github-actions-runner-controller",
+			PreBuiltImage:  image,
+			PinImageDigest: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	r.HTTPClient = server.Client()
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var got garV1.Runner
+	if err := r.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.ResolvedImageDigest != testImageDigest {
+		t.Errorf("resolvedImageDigest = %q, want %q", got.Status.ResolvedImageDigest, testImageDigest)
+	}
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	want := image + "@" + testImageDigest
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "runner" && container.Image != want {
+			t.Errorf("image = %q, want %q", container.Image, want)
+		}
+	}
+
+	got.Spec.PinImageDigest = false
+	if err := r.Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.ResolvedImageDigest != "" {
+		t.Errorf("resolvedImageDigest = %q, want empty when unpinned", got.Status.ResolvedImageDigest)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "runner" && container.Image != image {
+			t.Errorf("image = %q, want %q when unpinned", container.Image, image)
+		}
+	}
+}
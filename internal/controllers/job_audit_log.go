@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// JobAuditLogServer receives notifications of jobs sent by runner pods
+// and records them as events on Runner to correlate jobs with pods
+type JobAuditLogServer struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// Address the server binds to
+	BindAddress string
+}
+
+// jobNotification is the body sent to RUNNER_JOB_LOG_URL by the hook of the runner binary
+type jobNotification struct {
+	// started or completed
+	Event    string `json:"event"`
+	Job      string `json:"job"`
+	Workflow string `json:"workflow"`
+	Pod      string `json:"pod"`
+}
+
+// buildJobAuditLogURL returns the URL injected into runner pods as RUNNER_JOB_LOG_URL
+func buildJobAuditLogURL(baseURL string, runner *garV1.Runner) string {
+	return fmt.Sprintf("%s/namespaces/%s/runners/%s/jobs", baseURL, runner.Namespace, runner.Name)
+}
+
+func (s *JobAuditLogServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /namespaces/{namespace}/runners/{name}/jobs", s.handleJob)
+
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.Log.Error(err, "failed to shutdown job audit log server")
+		}
+	}()
+
+	s.Log.Info("starting job audit log server", "address", s.BindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection makes all replicas serve notifications since they only record events
+func (s *JobAuditLogServer) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *JobAuditLogServer) handleJob(w http.ResponseWriter, req *http.Request) {
+	var notification jobNotification
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<20)).Decode(&notification); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode notification: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var reason string
+	switch notification.Event {
+	case "started":
+		reason = EventReasonJobStarted
+	case "completed":
+		reason = EventReasonJobCompleted
+	default:
+		http.Error(w, fmt.Sprintf("unknown event %q", notification.Event), http.StatusBadRequest)
+		return
+	}
+
+	runner := &garV1.Runner{}
+	if err := s.Get(req.Context(), client.ObjectKey{
+		Namespace: req.PathValue("namespace"),
+		Name:      req.PathValue("name"),
+	}, runner); apierrors.IsNotFound(err) {
+		http.Error(w, "runner not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "failed to get runner")
+		http.Error(w, "failed to get runner", http.StatusInternalServerError)
+		return
+	}
+
+	s.Recorder.Eventf(runner, coreV1.EventTypeNormal, reason, "Job %q of workflow %q %s on pod %q", notification.Job, notification.Workflow, notification.Event, notification.Pod)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *JobAuditLogServer) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(s)
+}
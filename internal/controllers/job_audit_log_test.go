@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestJobAuditLogServerHandleJob(t *testing.T) {
+	type in struct {
+		path string
+		body string
+	}
+
+	type want struct {
+		statusCode int
+		event      string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"started",
+			in{
+				"/namespaces/default/runners/example/jobs",
+				`{"event":"started","job":"build","workflow":"CI","pod":"example-runner-abcde"}`,
+			},
+			want{
+				http.StatusNoContent,
+				`Normal JobStarted Job "build" of workflow "CI" started on pod "example-runner-abcde"`,
+			},
+		},
+		{
+			"completed",
+			in{
+				"/namespaces/default/runners/example/jobs",
+				`{"event":"completed","job":"build","workflow":"CI","pod":"example-runner-abcde"}`,
+			},
+			want{
+				http.StatusNoContent,
+				`Normal JobCompleted Job "build" of workflow "CI" completed on pod "example-runner-abcde"`,
+			},
+		},
+		{
+			"unknown event",
+			in{
+				"/namespaces/default/runners/example/jobs",
+				`{"event":"queued","job":"build","workflow":"CI","pod":"example-runner-abcde"}`,
+			},
+			want{
+				http.StatusBadRequest,
+				"",
+			},
+		},
+		{
+			"runner not found",
+			in{
+				"/namespaces/default/runners/missing/jobs",
+				`{"event":"started","job":"build","workflow":"CI","pod":"missing-runner-abcde"}`,
+			},
+			want{
+				http.StatusNotFound,
+				"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := record.NewFakeRecorder(1)
+			s := &JobAuditLogServer{
+				Client: newTestRunnerReconciler(t, &garV1.Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "example",
+						Namespace: "default",
+					},
+				}).Client,
+				Log:      logr.Discard(),
+				Recorder: recorder,
+			}
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /namespaces/{namespace}/runners/{name}/jobs", s.handleJob)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("POST", tt.in.path, strings.NewReader(tt.in.body)))
+
+			if w.Code != tt.want.statusCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.want.statusCode)
+			}
+			var event string
+			select {
+			case event = <-recorder.Events:
+			default:
+			}
+			if event != tt.want.event {
+				t.Errorf("event = %q, want %q", event, tt.want.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// latencyLoggingClient logs every call to the API server with its latency,
+// e.g. to find which call is the bottleneck of slow reconciliations.
+// Other methods such as Scheme and RESTMapper are delegated to the embedded client.
+type latencyLoggingClient struct {
+	client.Client
+	logger logr.Logger
+}
+
+func newLatencyLoggingClient(c client.Client, logger logr.Logger) client.Client {
+	return &latencyLoggingClient{
+		Client: c,
+		logger: logger,
+	}
+}
+
+func (c *latencyLoggingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	defer c.log("get", obj, key, time.Now())
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *latencyLoggingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	defer c.log("list", list, client.ObjectKey{Namespace: (&client.ListOptions{}).ApplyOptions(opts).Namespace}, time.Now())
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *latencyLoggingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	defer c.log("create", obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *latencyLoggingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer c.log("update", obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *latencyLoggingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer c.log("patch", obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *latencyLoggingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	defer c.log("delete", obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *latencyLoggingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	defer c.log("deletecollection", obj, client.ObjectKey{Namespace: (&client.DeleteAllOfOptions{}).ApplyOptions(opts).Namespace}, time.Now())
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *latencyLoggingClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *latencyLoggingClient) SubResource(subResource string) client.SubResourceClient {
+	return &latencyLoggingSubResourceClient{
+		SubResourceClient: c.Client.SubResource(subResource),
+		parent:            c,
+		subResource:       subResource,
+	}
+}
+
+// log is deferred with the start time evaluated at the call, so that the latency covers the whole call
+func (c *latencyLoggingClient) log(verb string, obj runtime.Object, key client.ObjectKey, start time.Time) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	c.logger.Info("api call", "verb", verb, "kind", kind, "namespace", key.Namespace, "name", key.Name, "latency", time.Since(start).String())
+}
+
+type latencyLoggingSubResourceClient struct {
+	client.SubResourceClient
+	parent      *latencyLoggingClient
+	subResource string
+}
+
+func (c *latencyLoggingSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	defer c.parent.log("get/"+c.subResource, obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.SubResourceClient.Get(ctx, obj, subResource, opts...)
+}
+
+func (c *latencyLoggingSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	defer c.parent.log("create/"+c.subResource, obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (c *latencyLoggingSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	defer c.parent.log("update/"+c.subResource, obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (c *latencyLoggingSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	defer c.parent.log("patch/"+c.subResource, obj, client.ObjectKeyFromObject(obj), time.Now())
+	return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr/funcr"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestLatencyLoggingClient(t *testing.T) {
+	type in struct {
+		call func(ctx context.Context, c client.Client, runner *garV1.Runner) error
+	}
+
+	type want struct {
+		log string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"get",
+			in{
+				func(ctx context.Context, c client.Client, runner *garV1.Runner) error {
+					return c.Get(ctx, client.ObjectKeyFromObject(runner), &garV1.Runner{})
+				},
+			},
+			want{
+				`"verb"="get" "kind"="Runner" "namespace"="default" "name"="example"`,
+			},
+		},
+		{
+			"list",
+			in{
+				func(ctx context.Context, c client.Client, runner *garV1.Runner) error {
+					return c.List(ctx, &v1.SecretList{}, client.InNamespace("default"))
+				},
+			},
+			want{
+				`"verb"="list" "kind"="SecretList" "namespace"="default" "name"=""`,
+			},
+		},
+		{
+			"status",
+			in{
+				func(ctx context.Context, c client.Client, runner *garV1.Runner) error {
+					return c.Status().Update(ctx, runner)
+				},
+			},
+			want{
+				`"verb"="update/status" "kind"="Runner" "namespace"="default" "name"="example"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			var logs []string
+			c := newLatencyLoggingClient(newTestRunnerReconciler(t, runner).Client, funcr.New(func(prefix, args string) {
+				logs = append(logs, args)
+			}, funcr.Options{}))
+
+			if err := tt.in.call(context.Background(), c, runner); err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != 1 {
+				t.Fatalf("logs = %v, want 1 line per call", logs)
+			}
+			if !strings.Contains(logs[0], tt.want.log) || !strings.Contains(logs[0], `"latency"=`) {
+				t.Errorf("log = %s, want %s with latency", logs[0], tt.want.log)
+			}
+		})
+	}
+}
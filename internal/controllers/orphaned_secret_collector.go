@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// OrphanedSecretCollector deletes token secrets whose runner no longer exists,
+// which are left when the runner is deleted before the garbage collector processes owner references
+type OrphanedSecretCollector struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func (r *OrphanedSecretCollector) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	secret := &coreV1.Secret{}
+	logger := r.Log.WithValues("secret", req.NamespacedName)
+	if err := r.Get(ctx, req.NamespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Shared token secrets are garbage collected by owner references of all runners sharing them
+	if !isTokenSecret(secret) || secret.Labels[sharedTokenSecretLabel] == "true" {
+		return ctrl.Result{}, nil
+	}
+
+	// Token secrets are named after the runner, which is used when the owner reference is missing
+	runnerName := secret.Name
+	if owner := metaV1.GetControllerOf(secret); owner != nil {
+		if owner.APIVersion != garV1.GroupVersion.String() || owner.Kind != "Runner" {
+			return ctrl.Result{}, nil
+		}
+		runnerName = owner.Name
+	}
+
+	var runner garV1.Runner
+	if err := r.Get(ctx, client.ObjectKey{
+		Name:      runnerName,
+		Namespace: secret.Namespace,
+	}, &runner); err == nil {
+		return ctrl.Result{}, nil
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("delete orphaned token secret", "runner", runnerName)
+
+	return ctrl.Result{}, nil
+}
+
+func isTokenSecret(obj client.Object) bool {
+	_, ok := obj.GetAnnotations()[expiresAtAnnotation]
+	return ok
+}
+
+func (r *OrphanedSecretCollector) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("orphaned-secret-collector").
+		For(&coreV1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(isTokenSecret))).
+		Complete(r)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOrphanedSecretCollectorReconcile(t *testing.T) {
+	type in struct {
+		secret  *v1.Secret
+		objects []client.Object
+	}
+
+	type want struct {
+		deleted bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"orphaned",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "example",
+						Namespace: "default",
+						Annotations: map[string]string{
+							expiresAtAnnotation: "2006-01-02T15:04:05Z",
+						},
+					},
+				},
+				nil,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"runner exists",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "example",
+						Namespace: "default",
+						Annotations: map[string]string{
+							expiresAtAnnotation: "2006-01-02T15:04:05Z",
+						},
+					},
+				},
+				[]client.Object{
+					&garV1.Runner{
+						ObjectMeta: metaV1.ObjectMeta{
+							Name:      "example",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"not token secret",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "example",
+						Namespace: "default",
+					},
+				},
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"owned by other than runner",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "example",
+						Namespace: "default",
+						Annotations: map[string]string{
+							expiresAtAnnotation: "2006-01-02T15:04:05Z",
+						},
+						OwnerReferences: []metaV1.OwnerReference{
+							{
+								APIVersion: "apps/v1",
+								Kind:       "Deployment",
+								Name:       "example",
+								UID:        "uid",
+								Controller: func(b bool) *bool { return &b }(true),
+							},
+						},
+					},
+				},
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := garV1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			r := &OrphanedSecretCollector{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(tt.in.objects, tt.in.secret)...).Build(),
+				Log:    logr.Discard(),
+				Scheme: scheme,
+			}
+			ctx := context.Background()
+
+			if _, err := r.Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      tt.in.secret.Name,
+					Namespace: tt.in.secret.Namespace,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := r.Get(ctx, client.ObjectKeyFromObject(tt.in.secret), &v1.Secret{})
+			if got := apierrors.IsNotFound(err); got != tt.want.deleted {
+				t.Errorf("deleted = %v, want %v (err = %v)", got, tt.want.deleted, err)
+			}
+		})
+	}
+}
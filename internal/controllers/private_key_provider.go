@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+// Types of PrivateKeyProvider selectable by flag
+const (
+	PrivateKeyProviderTypeStatic            = "static"
+	PrivateKeyProviderTypeAWSSecretsManager = "aws-secrets-manager"
+	PrivateKeyProviderTypeGCPSecretManager  = "gcp-secret-manager"
+	PrivateKeyProviderTypeAzureKeyVault     = "azure-key-vault"
+)
+
+// PrivateKeyProvider returns the private key of GitHub App.
+// It is called whenever the key is used instead of caching it permanently, so that rotated keys are picked up.
+type PrivateKeyProvider interface {
+	GetPrivateKey(ctx context.Context) (string, error)
+}
+
+// StaticPrivateKeyProvider returns the private key given at startup
+type StaticPrivateKeyProvider string
+
+func (p StaticPrivateKeyProvider) GetPrivateKey(_ context.Context) (string, error) {
+	return string(p), nil
+}
+
+// NewPrivateKeyProvider returns the provider of providerType.
+// ref identifies the secret in the cloud secret store, and privateKey is used by the static provider.
+func NewPrivateKeyProvider(providerType string, ref string, privateKey string) (PrivateKeyProvider, error) {
+	switch providerType {
+	case "", PrivateKeyProviderTypeStatic:
+		return StaticPrivateKeyProvider(privateKey), nil
+	case PrivateKeyProviderTypeAWSSecretsManager:
+		if ref == "" {
+			return nil, xerrors.New("secret id of AWS Secrets Manager is required")
+		}
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if region == "" {
+			return nil, xerrors.New("AWS_REGION is required for AWS Secrets Manager")
+		}
+		return &AWSSecretsManagerPrivateKeyProvider{
+			SecretId: ref,
+			Region:   region,
+		}, nil
+	case PrivateKeyProviderTypeGCPSecretManager:
+		if ref == "" {
+			return nil, xerrors.New("secret version name of GCP Secret Manager is required")
+		}
+		return &GCPSecretManagerPrivateKeyProvider{
+			Name: ref,
+		}, nil
+	case PrivateKeyProviderTypeAzureKeyVault:
+		if ref == "" {
+			return nil, xerrors.New("secret URL of Azure Key Vault is required")
+		}
+		return &AzureKeyVaultPrivateKeyProvider{
+			SecretURL: ref,
+		}, nil
+	default:
+		return nil, xerrors.Errorf("unknown private key provider type %q", providerType)
+	}
+}
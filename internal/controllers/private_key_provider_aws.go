@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// AWSSecretsManagerPrivateKeyProvider fetches the private key from AWS Secrets Manager.
+// Credentials are taken from the environment variables of access keys or IAM Roles for Service Accounts.
+type AWSSecretsManagerPrivateKeyProvider struct {
+	// Name or ARN of the secret
+	SecretId string
+	Region   string
+}
+
+type awsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func (p *AWSSecretsManagerPrivateKeyProvider) GetPrivateKey(ctx context.Context) (string, error) {
+	credentials, err := p.credentials(ctx)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get credentials: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"SecretId": p.SecretId,
+	})
+	if err != nil {
+		return "", xerrors.Errorf("failed to marshal body: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", p.Region), bytes.NewReader(body))
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(request, body, credentials, p.Region, "secretsmanager", time.Now())
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get secret value: %d", response.StatusCode)
+	}
+
+	secret := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return "", xerrors.Errorf("failed to decode secret value: %w", err)
+	}
+	return secret.SecretString, nil
+}
+
+func (p *AWSSecretsManagerPrivateKeyProvider) credentials(ctx context.Context) (awsCredentials, error) {
+	if accessKeyId := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyId != "" {
+		return awsCredentials{
+			AccessKeyId:     accessKeyId,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	roleArn := os.Getenv("AWS_ROLE_ARN")
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleArn == "" || tokenFile == "" {
+		return awsCredentials{}, xerrors.New("neither AWS_ACCESS_KEY_ID nor AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE are set")
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, xerrors.Errorf("failed to read web identity token: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", roleArn)
+	query.Set("RoleSessionName", "github-actions-runner-controller")
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", p.Region, query.Encode()), nil)
+	if err != nil {
+		return awsCredentials{}, xerrors.Errorf("failed to create request: %w", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return awsCredentials{}, xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return awsCredentials{}, xerrors.Errorf("failed to assume role: %d", response.StatusCode)
+	}
+
+	result := struct {
+		Credentials struct {
+			AccessKeyId     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}{}
+	if err := xml.NewDecoder(response.Body).Decode(&result); err != nil {
+		return awsCredentials{}, xerrors.Errorf("failed to decode credentials: %w", err)
+	}
+	return awsCredentials(result.Credentials), nil
+}
+
+// signV4 signs the request by AWS Signature Version 4 with all of its headers
+func signV4(request *http.Request, body []byte, credentials awsCredentials, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host": request.URL.Host,
+	}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		strings.ReplaceAll(request.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", credentials.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// AzureKeyVaultPrivateKeyProvider fetches the private key from Azure Key Vault.
+// Credentials are taken from the environment variables injected by Azure Workload Identity.
+type AzureKeyVaultPrivateKeyProvider struct {
+	// URL of the secret, e.g. https://my-vault.vault.azure.net/secrets/my-secret
+	SecretURL string
+}
+
+func (p *AzureKeyVaultPrivateKeyProvider) GetPrivateKey(ctx context.Context) (string, error) {
+	accessToken, err := p.accessToken(ctx)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get access token: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", p.SecretURL+"?api-version=7.4", nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get secret: %d", response.StatusCode)
+	}
+
+	secret := struct {
+		Value string `json:"value"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return "", xerrors.Errorf("failed to decode secret: %w", err)
+	}
+	return secret.Value, nil
+}
+
+func (p *AzureKeyVaultPrivateKeyProvider) accessToken(ctx context.Context) (string, error) {
+	clientId := os.Getenv("AZURE_CLIENT_ID")
+	tenantId := os.Getenv("AZURE_TENANT_ID")
+	tokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	if clientId == "" || tenantId == "" || tokenFile == "" {
+		return "", xerrors.New("AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE are required")
+	}
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = "https://login.microsoftonline.com/"
+	}
+	assertion, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", xerrors.Errorf("failed to read federated token: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("scope", "https://vault.azure.net/.default")
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	request, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/"), tenantId), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to exchange federated token: %d", response.StatusCode)
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", xerrors.Errorf("failed to decode token: %w", err)
+	}
+	return token.AccessToken, nil
+}
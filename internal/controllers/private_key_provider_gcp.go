@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/xerrors"
+)
+
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPSecretManagerPrivateKeyProvider fetches the private key from GCP Secret Manager.
+// Credentials are taken from the metadata server, which serves the ones of GKE Workload Identity.
+type GCPSecretManagerPrivateKeyProvider struct {
+	// Resource name of the secret version, e.g. projects/my-project/secrets/my-secret/versions/latest
+	Name string
+}
+
+func (p *GCPSecretManagerPrivateKeyProvider) GetPrivateKey(ctx context.Context) (string, error) {
+	accessToken, err := p.accessToken(ctx)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get access token: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://secretmanager.googleapis.com/v1/%s:access", p.Name), nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to access secret version: %d", response.StatusCode)
+	}
+
+	secretVersion := struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&secretVersion); err != nil {
+		return "", xerrors.Errorf("failed to decode secret version: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(secretVersion.Payload.Data)
+	if err != nil {
+		return "", xerrors.Errorf("failed to decode payload: %w", err)
+	}
+	return string(data), nil
+}
+
+func (p *GCPSecretManagerPrivateKeyProvider) accessToken(ctx context.Context) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get token from metadata server: %d", response.StatusCode)
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", xerrors.Errorf("failed to decode token: %w", err)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewPrivateKeyProvider(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	type in struct {
+		providerType string
+		ref          string
+	}
+
+	type want struct {
+		provider PrivateKeyProvider
+		err      bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"static",
+			in{
+				"",
+				"",
+			},
+			want{
+				StaticPrivateKeyProvider("private key"),
+				false,
+			},
+		},
+		{
+			"AWS Secrets Manager",
+			in{
+				"aws-secrets-manager",
+				"github-app",
+			},
+			want{
+				&AWSSecretsManagerPrivateKeyProvider{
+					SecretId: "github-app",
+					Region:   "us-east-1",
+				},
+				false,
+			},
+		},
+		{
+			"GCP Secret Manager",
+			in{
+				"gcp-secret-manager",
+				"projects/example/secrets/github-app/versions/latest",
+			},
+			want{
+				&GCPSecretManagerPrivateKeyProvider{
+					Name: "projects/example/secrets/github-app/versions/latest",
+				},
+				false,
+			},
+		},
+		{
+			"Azure Key Vault",
+			in{
+				"azure-key-vault",
+				"https://example.vault.azure.net/secrets/github-app",
+			},
+			want{
+				&AzureKeyVaultPrivateKeyProvider{
+					SecretURL: "https://example.vault.azure.net/secrets/github-app",
+				},
+				false,
+			},
+		},
+		{
+			"missing reference",
+			in{
+				"gcp-secret-manager",
+				"",
+			},
+			want{
+				nil,
+				true,
+			},
+		},
+		{
+			"unknown",
+			in{
+				"vault",
+				"secret/github-app",
+			},
+			want{
+				nil,
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPrivateKeyProvider(tt.in.providerType, tt.in.ref, "private key")
+			if (err != nil) != tt.want.err {
+				t.Fatalf("NewPrivateKeyProvider() error = %v, want error %v", err, tt.want.err)
+			}
+			if !reflect.DeepEqual(got, tt.want.provider) {
+				t.Errorf("NewPrivateKeyProvider() = %#v, want %#v", got, tt.want.provider)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerGetPrivateKey(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+	r.GitHubAppPrivateKey = "flag"
+
+	if got, err := r.getPrivateKey(context.Background()); err != nil || got != "flag" {
+		t.Errorf("getPrivateKey() = %q, %v, want %q", got, err, "flag")
+	}
+
+	r.PrivateKeyProvider = StaticPrivateKeyProvider("provider")
+	if got, err := r.getPrivateKey(context.Background()); err != nil || got != "provider" {
+		t.Errorf("getPrivateKey() = %q, %v, want %q", got, err, "provider")
+	}
+}
+
+// The example of https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func TestSignV4(t *testing.T) {
+	request, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signV4(request, nil, awsCredentials{
+		AccessKeyId:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := request.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const defaultPrivateKeySecretKey = "private-key"
+
+func (r *RunnerReconciler) privateKeySecretKey() string {
+	if r.PrivateKeySecretKey == "" {
+		return defaultPrivateKeySecretKey
+	}
+	return r.PrivateKeySecretKey
+}
+
+// mapPrivateKeySecretToRunners discards installation access tokens issued with the previous private key on its changes,
+// and reconciles runners using GitHub App of the controller to renew their tokens with the new one.
+func (r *RunnerReconciler) mapPrivateKeySecretToRunners(ctx context.Context, obj client.Object) []reconcile.Request {
+	if r.PrivateKeySecretRef.Name == "" || client.ObjectKeyFromObject(obj) != r.PrivateKeySecretRef {
+		return nil
+	}
+	if previous, _ := r.privateKeySecretResourceVersion.Swap(obj.GetResourceVersion()).(string); previous == obj.GetResourceVersion() {
+		return nil
+	}
+	r.tokenCache.Range(func(key, _ interface{}) bool {
+		r.tokenCache.Delete(key)
+		return true
+	})
+
+	var runners garV1.RunnerList
+	if err := r.List(ctx, &runners); err != nil {
+		r.Log.Error(err, "failed to list runners on changes of private key secret")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(runners.Items))
+	for _, runner := range runners.Items {
+		// Runners with their own credentials do not use the private key
+		if runner.Spec.TokenSecretKeyRef != nil || runner.Spec.PersonalAccessTokenRef != nil || runner.Spec.AppSecretRef != nil {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&runner),
+		})
+	}
+	return requests
+}
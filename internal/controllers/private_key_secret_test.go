@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRunnerReconcilerRotatePrivateKeySecret(t *testing.T) {
+	privateKeySecret := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "github-app",
+			Namespace: "github-actions-runner-controller",
+		},
+		Data: map[string][]byte{
+			"private-key": []byte("old"),
+		},
+	}
+	appRunner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	tokenRunner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "token",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, privateKeySecret, appRunner, tokenRunner)
+	r.GitHubAppClientId = "Iv1.0123456789abcdef"
+	r.PrivateKeySecretRef = types.NamespacedName{
+		Name:      privateKeySecret.Name,
+		Namespace: privateKeySecret.Namespace,
+	}
+	ctx := context.Background()
+
+	if !r.gitHubAppConfigured() {
+		t.Fatal("GitHub App must be configured by the private key secret")
+	}
+	if got, err := r.getPrivateKey(ctx); err != nil || got != "old" {
+		t.Fatalf("getPrivateKey() = %q, %v, want %q", got, err, "old")
+	}
+
+	r.mapPrivateKeySecretToRunners(ctx, privateKeySecret)
+	r.tokenCache.Store("1:kaidotdev/github-actions-runner-controller", cachedToken{
+		token:     "issued with old key",
+		expiresAt: time.Now().Add(time.Hour),
+	})
+
+	privateKeySecret.Data["private-key"] = []byte("new")
+	if err := r.Update(ctx, privateKeySecret); err != nil {
+		t.Fatal(err)
+	}
+	want := []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      appRunner.Name,
+				Namespace: appRunner.Namespace,
+			},
+		},
+	}
+	if got := r.mapPrivateKeySecretToRunners(ctx, privateKeySecret); !reflect.DeepEqual(got, want) {
+		t.Errorf("mapPrivateKeySecretToRunners() = %v, want %v", got, want)
+	}
+	if _, ok := r.tokenCache.Load("1:kaidotdev/github-actions-runner-controller"); ok {
+		t.Error("tokens issued with the old key must be discarded")
+	}
+	if got, err := r.getPrivateKey(ctx); err != nil || got != "new" {
+		t.Errorf("getPrivateKey() = %q, %v, want %q", got, err, "new")
+	}
+
+	if got := r.mapPrivateKeySecretToRunners(ctx, privateKeySecret); got != nil {
+		t.Errorf("mapPrivateKeySecretToRunners() = %v, want nil without changes", got)
+	}
+	if got := r.mapPrivateKeySecretToRunners(ctx, &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "other",
+			Namespace: privateKeySecret.Namespace,
+		},
+	}); got != nil {
+		t.Errorf("mapPrivateKeySecretToRunners() = %v, want nil for other secrets", got)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkResourceQuotas returns the message describing resource quotas of the namespace exceeded by creating the deployment,
+// or empty when it fits in all of them.
+// Quotas with scopes are skipped since whether they apply to the pods depends on the scopes.
+func (r *RunnerReconciler) checkResourceQuotas(ctx context.Context, deployment *appsV1.Deployment) (string, error) {
+	var resourceQuotas v1.ResourceQuotaList
+	if err := r.List(ctx, &resourceQuotas, client.InNamespace(deployment.Namespace)); err != nil {
+		return "", err
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	usage := buildQuotaUsage(&deployment.Spec.Template.Spec, replicas)
+
+	var messages []string
+	for _, resourceQuota := range resourceQuotas.Items {
+		if len(resourceQuota.Spec.Scopes) > 0 || resourceQuota.Spec.ScopeSelector != nil {
+			continue
+		}
+		for _, exceeded := range exceededResources(&resourceQuota, usage) {
+			messages = append(messages, fmt.Sprintf("%s of %q", exceeded, resourceQuota.Name))
+		}
+	}
+	return strings.Join(messages, ", "), nil
+}
+
+// exceededResources returns descriptions of the resources whose used amount in the quota plus usage exceeds the hard limit
+func exceededResources(resourceQuota *v1.ResourceQuota, usage v1.ResourceList) []string {
+	var exceeded []string
+	for name, hard := range resourceQuota.Status.Hard {
+		requested, ok := usage[name]
+		if !ok {
+			continue
+		}
+		total := resourceQuota.Status.Used[name].DeepCopy()
+		total.Add(requested)
+		if total.Cmp(hard) > 0 {
+			used := resourceQuota.Status.Used[name]
+			exceeded = append(exceeded, fmt.Sprintf("%s (requested: %s, used: %s, hard: %s)", name, requested.String(), used.String(), hard.String()))
+		}
+	}
+	sort.Strings(exceeded)
+	return exceeded
+}
+
+// buildQuotaUsage computes the amount of resources counted by resource quotas for the replicas of the pod.
+// Like the scheduler, init containers run one by one, so the pod uses the larger of the sum of containers and the maximum of init containers.
+func buildQuotaUsage(spec *v1.PodSpec, replicas int32) v1.ResourceList {
+	requests := v1.ResourceList{}
+	limits := v1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResourceList(requests, containerRequests(&container))
+		addResourceList(limits, container.Resources.Limits)
+	}
+	for _, container := range spec.InitContainers {
+		maxResourceList(requests, containerRequests(&container))
+		maxResourceList(limits, container.Resources.Limits)
+	}
+
+	usage := v1.ResourceList{
+		v1.ResourcePods: *resource.NewQuantity(int64(replicas), resource.DecimalSI),
+	}
+	for name, quantity := range requests {
+		quantity := multiplyQuantity(quantity, replicas)
+		usage[name] = quantity
+		usage[v1.ResourceName("requests."+string(name))] = quantity
+	}
+	for name, quantity := range limits {
+		usage[v1.ResourceName("limits."+string(name))] = multiplyQuantity(quantity, replicas)
+	}
+	return usage
+}
+
+// containerRequests returns requests of the container, which default to limits like Kubernetes does
+func containerRequests(container *v1.Container) v1.ResourceList {
+	requests := container.Resources.Requests.DeepCopy()
+	if requests == nil {
+		requests = v1.ResourceList{}
+	}
+	for name, quantity := range container.Resources.Limits {
+		if _, ok := requests[name]; !ok {
+			requests[name] = quantity.DeepCopy()
+		}
+	}
+	return requests
+}
+
+func addResourceList(list v1.ResourceList, other v1.ResourceList) {
+	for name, quantity := range other {
+		sum := list[name]
+		sum.Add(quantity)
+		list[name] = sum
+	}
+}
+
+func maxResourceList(list v1.ResourceList, other v1.ResourceList) {
+	for name, quantity := range other {
+		if current, ok := list[name]; !ok || quantity.Cmp(current) > 0 {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+func multiplyQuantity(quantity resource.Quantity, n int32) resource.Quantity {
+	return *resource.NewMilliQuantity(quantity.MilliValue()*int64(n), quantity.Format)
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestBuildQuotaUsage(t *testing.T) {
+	type in struct {
+		spec     *v1.PodSpec
+		replicas int32
+	}
+
+	type want struct {
+		usage map[v1.ResourceName]string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"containers are summed",
+			in{
+				&v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{
+									v1.ResourceCPU: resource.MustParse("500m"),
+								},
+								Limits: v1.ResourceList{
+									v1.ResourceMemory: resource.MustParse("1Gi"),
+								},
+							},
+						},
+						{
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{
+									v1.ResourceCPU:    resource.MustParse("1"),
+									v1.ResourceMemory: resource.MustParse("1Gi"),
+								},
+							},
+						},
+					},
+				},
+				2,
+			},
+			want{
+				map[v1.ResourceName]string{
+					v1.ResourcePods:           "2",
+					v1.ResourceCPU:            "3",
+					v1.ResourceRequestsCPU:    "3",
+					v1.ResourceMemory:         "4Gi",
+					v1.ResourceRequestsMemory: "4Gi",
+					v1.ResourceLimitsMemory:   "2Gi",
+				},
+			},
+		},
+		{
+			"init containers take the maximum",
+			in{
+				&v1.PodSpec{
+					InitContainers: []v1.Container{
+						{
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{
+									v1.ResourceCPU: resource.MustParse("2"),
+								},
+							},
+						},
+					},
+					Containers: []v1.Container{
+						{
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{
+									v1.ResourceCPU: resource.MustParse("1"),
+								},
+							},
+						},
+					},
+				},
+				1,
+			},
+			want{
+				map[v1.ResourceName]string{
+					v1.ResourcePods:        "1",
+					v1.ResourceCPU:         "2",
+					v1.ResourceRequestsCPU: "2",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := map[v1.ResourceName]string{}
+			for name, quantity := range buildQuotaUsage(tt.in.spec, tt.in.replicas) {
+				got[name] = quantity.String()
+			}
+			if !reflect.DeepEqual(got, tt.want.usage) {
+				t.Errorf("buildQuotaUsage() = %v, want %v", got, tt.want.usage)
+			}
+		})
+	}
+}
+
+func TestExceededResources(t *testing.T) {
+	resourceQuota := &v1.ResourceQuota{
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{
+				v1.ResourceRequestsCPU: resource.MustParse("4"),
+				v1.ResourcePods:        resource.MustParse("10"),
+				v1.ResourceServices:    resource.MustParse("1"),
+			},
+			Used: v1.ResourceList{
+				v1.ResourceRequestsCPU: resource.MustParse("3"),
+				v1.ResourcePods:        resource.MustParse("3"),
+				v1.ResourceServices:    resource.MustParse("1"),
+			},
+		},
+	}
+
+	type in struct {
+		usage v1.ResourceList
+	}
+
+	type want struct {
+		exceeded []string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"fits",
+			in{
+				v1.ResourceList{
+					v1.ResourceRequestsCPU: resource.MustParse("1"),
+					v1.ResourcePods:        resource.MustParse("1"),
+				},
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"exceeds",
+			in{
+				v1.ResourceList{
+					v1.ResourceRequestsCPU: resource.MustParse("1500m"),
+					v1.ResourcePods:        resource.MustParse("1"),
+				},
+			},
+			want{
+				[]string{
+					"requests.cpu (requested: 1500m, used: 3, hard: 4)",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := exceededResources(resourceQuota, tt.in.usage); !reflect.DeepEqual(got, tt.want.exceeded) {
+				t.Errorf("exceededResources() = %v, want %v", got, tt.want.exceeded)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileResourceQuotaExceeded(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	resourceQuota := &v1.ResourceQuota{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "pods",
+			Namespace: "default",
+		},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{
+				v1.ResourcePods: resource.MustParse("1"),
+			},
+			Used: v1.ResourceList{
+				v1.ResourcePods: resource.MustParse("1"),
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner, resourceQuota)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+	ctx := context.Background()
+
+	result, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != resourceQuotaRequeueInterval {
+		t.Errorf("requeueAfter = %s, want %s", result.RequeueAfter, resourceQuotaRequeueInterval)
+	}
+
+	var deployments appsV1.DeploymentList
+	if err := r.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 0 {
+		t.Errorf("deployments = %d, want 0 when exceeding resource quotas", len(deployments.Items))
+	}
+	var recorded bool
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, EventReasonResourceQuotaExceeded) {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Errorf("no %s event is recorded", EventReasonResourceQuotaExceeded)
+	}
+
+	r.DisableResourceQuotaCheck = true
+	if _, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 1 {
+		t.Errorf("deployments = %d, want 1 when the check is disabled", len(deployments.Items))
+	}
+}
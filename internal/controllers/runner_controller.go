@@ -8,9 +8,15 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"path"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	garV1 "github-actions-runner-controller/api/v1"
@@ -18,29 +24,76 @@ import (
 	dockerref "github.com/docker/distribution/reference"
 	"github.com/go-logr/logr"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
-	ownerKey               = ".metadata.controller"
-	optimisticLockErrorMsg = "the object has been modified; please apply your changes to the latest version and try again"
-	expiresAtAnnotation    = "github-actions-runner.kaidotio.github.io/expiresAt"
+	ownerKey                = ".metadata.controller"
+	fieldOwner              = "github-actions-runner-controller"
+	expiresAtAnnotation     = "github-actions-runner.kaidotio.github.io/expiresAt"
+	runnerClassAnnotation   = "github-actions-runner.kaidotio.github.io/runnerClass"
+	specHashAnnotation      = "github-actions-runner.kaidotio.github.io/runner-spec-hash"
+	metadataHashAnnotation  = "github-actions-runner.kaidotio.github.io/managed-metadata-hash"
+	runnerVersionAnnotation = "github-actions-runner.kaidotio.github.io/last-runner-version"
+	pausedAnnotation        = "github-actions-runner.kaidotio.github.io/paused"
+	queuedJobAnnotation     = "github-actions-runner.kaidotio.github.io/last-queued-job"
+	sharedTokenSecretLabel  = "github-actions-runner.kaidotio.github.io/shared-token"
+	defaultNoProxy          = "localhost,127.0.0.1,.svc,.cluster.local"
+	customCACertFileName    = "custom-ca.crt"
+
+	defaultRunnerUID             = 60000
+	defaultRunnerGID             = 60000
+	tokenRenewalRetryInterval    = 30 * time.Second
+	tokenSecretUpdateAttempts    = 3
+	resourceQuotaRequeueInterval = time.Minute
+	// Invalid deployments are not fixed until runners are modified, which requeue them by themselves
+	invalidDeploymentRequeueInterval = 10 * time.Minute
+	conflictBackoffBase              = time.Second
+	// Margin of the deadline of jobs running the builder container per attempt for scheduling and image pulls
+	builderStartupTimeout = 5 * time.Minute
 )
 
+// Permissions of tokens issued by GitHub App, which registering repository runners requires
+var defaultTokenPermissions = map[string]string{
+	"actions":        "read",
+	"administration": "write",
+	"metadata":       "read",
+}
+
+// defaultHTTPClient times out so that a hanging GitHub API does not occupy reconcile workers forever
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
 type RunnerReconciler struct {
 	client.Client
 	Log                     logr.Logger
@@ -53,219 +106,1056 @@ type RunnerReconciler struct {
 	GitHubAppClientId       string
 	GitHubAppInstallationId string
 	GitHubAppPrivateKey     string
+	// Whether to discover the installation of GitHub App per repository of runners instead of GitHubAppInstallationId,
+	// e.g. to manage runners of repositories across multiple organizations installing the app.
+	DiscoverInstallationId bool
+	// Provider of the private key of GitHub App used instead of GitHubAppPrivateKey when set
+	PrivateKeyProvider PrivateKeyProvider
+	// Secret holding the private key of GitHub App in PrivateKeySecretKey, which takes precedence over the above when its name is set.
+	// Installation access tokens are renewed on its changes, so that the key can be rotated without downtime.
+	PrivateKeySecretRef     types.NamespacedName
+	PrivateKeySecretKey     string
 	KanikoImage             string
+	WorkspaceImage          string
 	BinaryVersion           string
+	BinaryArch              string
 	RunnerVersion           string
 	Disableupdate           bool
+	Tracer                  trace.Tracer
+	ReconcileTotal          *prometheus.CounterVec
+	ReconcileDuration       prometheus.Histogram
+	TokenSecondsUntilExpiry *prometheus.GaugeVec
+	// Maximum number of runners reconciled concurrently. Defaults to 1.
+	// Each runner reconciles independent resources, so increasing it is safe,
+	// but GitHub API rate limits may become a bottleneck.
+	MaxConcurrentReconciles int
+	// Whether to log diffs between expected and actual owned resources on every reconciliation at the default log level.
+	// Secret values are logged as their hashes.
+	Debug bool
+	// Whether to only propose changes by events and logs without applying them.
+	// All requests to the API server are made with dry-run when set up with manager.
+	DryRun bool
+	// Whether to log every call to the API server with its latency, e.g. to find the bottleneck of slow reconciliations.
+	// Calls made by the cache such as watches are not logged.
+	TraceAPILatency bool
+	// Rate limiter of the work queue. Defaults to the one of controller-runtime.
+	RateLimiter workqueue.RateLimiter
+	// Duration before expiry at which installation access tokens are renewed. Defaults to 1 minute.
+	TokenRefreshBuffer time.Duration
+	// Maximum delay of requeue on conflicts at update. Defaults to 30 seconds.
+	ConflictBackoffMax time.Duration
+	// Number of consecutive failures to reach GitHub API at which the circuit breaker opens. Defaults to 5.
+	CircuitBreakerThreshold int
+	// Duration for which the circuit breaker stays open. Defaults to 5 minutes.
+	CircuitBreakerTimeout time.Duration
+	// Duration for which calls to GitHub API can keep failing before the controller becomes unready.
+	// The readiness check is disabled when zero.
+	GitHubAPIReadinessThreshold time.Duration
+	// Maximum duration of a reconciliation before the controller is regarded as stuck by the liveness check.
+	// The liveness check is disabled when zero.
+	MaxReconcileAge time.Duration
+	// Base URL of JobAuditLogServer reachable from runner pods, which is injected into them as RUNNER_JOB_LOG_URL.
+	// Job audit log is disabled when empty.
+	JobAuditLogURL string
+	// Config map overriding the configuration above without restart, which is disabled when its name is empty.
+	// All runners are reconciled on its changes.
+	ConfigMapRef types.NamespacedName
+	// Resources of runner container used when limits or requests are not specified by Runner
+	DefaultRunnerResources v1.ResourceRequirements
+	// Resources of builder container used when limits or requests are not specified by Runner
+	DefaultBuilderResources v1.ResourceRequirements
+	// Scheduler of runner pods used when schedulerName is not specified by Runner.
+	// Defaults to the default scheduler of Kubernetes.
+	DefaultSchedulerName string
+	// Environment variables injected into all runner containers, e.g. endpoints of observability agents.
+	// They are prepended to the ones of Runner, which take precedence on conflicts by name.
+	GlobalRunnerEnv []v1.EnvVar
+	// Whether to create deployments without checking that they fit in resource quotas of the namespace
+	DisableResourceQuotaCheck bool
+	// Whether to omit the anti-affinity spreading runner pods across nodes for all runners, e.g. in single-node development clusters
+	DisablePodAntiAffinity bool
+	// Maximum duration of a reconciliation, after which requests to the API server and GitHub API are cancelled.
+	// Disabled when zero.
+	ReconcileTimeout time.Duration
+	// Client of GitHub API and Docker registries. Defaults to one timing out in 30 seconds.
+	HTTPClient *http.Client
+	// Selector of runners managed by the controller, e.g. to shard runners among multiple controllers.
+	// All runners are managed when nil.
+	RunnerSelector *metaV1.LabelSelector
+	// Annotations added to all secrets managed by the controller, e.g. to select the key of a KMS provider encrypting them at rest.
+	// They take precedence over managed resource annotations of Runner.
+	EncryptionAnnotations map[string]string
+	// Patterns of path.Match allowed as image of runners, e.g. internal-registry.example.com/*,
+	// to prevent users creating runners from launching arbitrary images. All images are allowed when empty.
+	AllowedBaseImages []string
+
+	// Installation access tokens shared by runners of the same installation and repository
+	tokenCache sync.Map
+	// Installation ids of GitHub App discovered per repository
+	installationIds sync.Map
+	// Cache keys and generations of runners used to invalidate tokenCache on spec changes
+	tokenCacheOwners sync.Map
+	// Number of consecutive conflicts per runner
+	conflictFailures sync.Map
+	// Circuit breaker shared by all calls to GitHub API
+	githubCircuit circuitBreaker
+	// Runner version upgraded by RunnerVersionPoller, which takes precedence over RunnerVersion
+	upgradedRunnerVersion atomic.Value
+	// Whether RunnerVersionPoller is running, in which case the versions annotated to runners take precedence
+	autoUpgrade bool
+	// Unix times in nanoseconds of the last successful and failed calls to GitHub API
+	lastGitHubAPISuccess atomic.Int64
+	lastGitHubAPIFailure atomic.Int64
+	// Start times of reconciliations in progress per runner
+	inflightReconciles sync.Map
+	// Time the reconciler is set up, used by the readiness check before any successful call
+	startedAt time.Time
+	// Guards the configuration overridden by the config map, which is read-locked during reconciliation
+	configMu sync.RWMutex
+	// Resource version of the config map applied last
+	configResourceVersion string
+	// Configuration at startup used for keys absent from the config map
+	defaultConfig     controllerConfig
+	defaultConfigOnce sync.Once
+	// Reader of the private key secret, which is read before the cache starts
+	apiReader client.Reader
+	// Resource version of the private key secret seen last, used to renew tokens on rotation
+	privateKeySecretResourceVersion atomic.Value
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+type tokenCacheOwner struct {
+	key        string
+	generation int64
 }
 
-func (r *RunnerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	var requeueAfter time.Duration
+func (r *RunnerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+
+	r.inflightReconciles.Store(req.NamespacedName, time.Now())
+	defer r.inflightReconciles.Delete(req.NamespacedName)
+
+	ctx, span := r.Tracer.Start(ctx, "Reconcile", trace.WithAttributes(attribute.String("runner", req.NamespacedName.String())))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	defer func() {
+		reconcileResult := "success"
+		if err != nil {
+			reconcileResult = "error"
+		}
+		r.ReconcileTotal.WithLabelValues(req.Name, req.Namespace, reconcileResult).Inc()
+		r.ReconcileDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	// Conflicts are retried with backoff instead of the rate limiter of the work queue.
+	// This is deferred after the tracing and the metrics, so that they observe retried conflicts as successes.
+	defer func() {
+		if apierrors.IsConflict(err) {
+			result, err = ctrl.Result{RequeueAfter: r.conflictBackoff(req)}, nil
+		} else if err == nil {
+			r.conflictFailures.Delete(req.NamespacedName)
+		}
+	}()
+
+	var tokenExpiresAt *metaV1.Time
 
 	runner := &garV1.Runner{}
 	logger := r.Log.WithValues("runner", req.NamespacedName)
+
+	// Invalid configuration is ignored to keep runners reconciled with the previous one
+	if err := r.loadConfig(ctx); err != nil {
+		logger.Error(err, "failed to load config")
+	}
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+
 	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
 		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
+	// Runners enqueued by owned resources or fan-outs are left to the controller selecting them
+	if !r.selectsRunner(runner) {
+		logger.V(1).Info("skip reconciliation since runner is not selected")
+		return ctrl.Result{}, nil
+	}
+
+	if !runner.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(runner, drainFinalizer) {
+			return r.drain(ctx, runner, logger)
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.syncDrainFinalizer(ctx, runner); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Paused runners are left as they are, e.g. to keep manual hotfixes during incidents
+	if runner.Annotations[pausedAnnotation] == "true" {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonPaused, "Reconciliation is paused by annotation %q", pausedAnnotation)
+		logger.Info("skip reconciliation since runner is paused")
+		return ctrl.Result{}, nil
+	}
+
+	runnerClass, err := runner.MatchRunnerClass(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.recordRunnerClass(ctx, runner, runnerClass); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Runners admitted without the mutating webhook have no values of RunnerClass yet
+	if runnerClass != nil {
+		if err := runner.ApplyRunnerClass(runnerClass); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// Runners are requeued for periodic resync regardless of the stage the reconciliation ends at
+	defer func() {
+		if err == nil {
+			result = withPeriodicResync(result, runner)
+		}
+	}()
 
-	if err := r.cleanupOwnedResources(ctx, runner); err != nil {
+	if err := r.cleanupOwnedResources(ctx, runner, logger); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if runner.Spec.TokenSecretKeyRef == nil && r.GitHubAppClientId != "" && r.GitHubAppInstallationId != "" && r.GitHubAppPrivateKey != "" {
+	// Runners with disallowed images are not requeued until their spec changes
+	if !r.allowsImage(runner.Spec.Image) {
+		return ctrl.Result{}, r.rejectImage(ctx, runner, logger)
+	}
+	if runner.Spec.SecurityProfile == garV1.SecurityProfilePrivileged {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonPrivileged, "Runner container runs privileged by security profile %q", runner.Spec.SecurityProfile)
+	}
+
+	if runner.Spec.PersonalAccessTokenRef != nil && runner.Spec.AppSecretRef == nil {
+		if err := r.validatePersonalAccessToken(ctx, runner); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if runner.Spec.TokenSecretKeyRef == nil {
+			runner.Spec.TokenSecretKeyRef = runner.Spec.PersonalAccessTokenRef.SecretRef.DeepCopy()
+		}
+	}
+
+	if r.usesInstallationToken(runner) {
 		var tokenSecret v1.Secret
 		if err := r.Client.Get(
 			ctx,
 			client.ObjectKey{
-				Name:      req.Name,
+				Name:      tokenSecretName(runner),
 				Namespace: req.Namespace,
 			},
 			&tokenSecret,
 		); apierrors.IsNotFound(err) {
-			tokenSecret, err := r.createTokenSecret(runner)
+			tokenSecret, err := r.createTokenSecret(ctx, runner)
 			if err != nil {
-				return ctrl.Result{}, err
+				return r.tokenRenewalFailed(runner, logger, err), nil
 			}
-			if err := controllerutil.SetControllerReference(runner, tokenSecret, r.Scheme); err != nil {
+			if err := r.setTokenSecretOwner(runner, tokenSecret); err != nil {
 				return ctrl.Result{}, err
 			}
 			if err := r.Create(ctx, tokenSecret); err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create token secret %q: %v", tokenSecret.Name, err)
 				return ctrl.Result{}, err
 			}
-			r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulCreated", "Created token secret: %q", tokenSecret.Name)
+			r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created token secret: %q", tokenSecret.Name), "")
 			logger.V(1).Info("create", "secret", tokenSecret)
 
 			expire, err := time.Parse(time.RFC3339, tokenSecret.Annotations[expiresAtAnnotation])
 			if err != nil {
 				return ctrl.Result{}, err
 			}
-			requeueAfter = expire.Sub(time.Now()) - time.Minute
+			tokenExpiresAt = &metaV1.Time{Time: expire}
+			r.TokenSecondsUntilExpiry.WithLabelValues(req.Name, req.Namespace).Set(time.Until(expire).Seconds())
 		} else if err != nil {
 			return ctrl.Result{}, err
 		} else {
-			expectedTokenSecret, err := r.createTokenSecret(runner)
+			if err := r.reconcileSharedTokenSecretOwner(ctx, runner, &tokenSecret); err != nil {
+				return ctrl.Result{}, err
+			}
+			expectedTokenSecret, err := r.createTokenSecret(ctx, runner)
 			if err != nil {
+				return r.tokenRenewalFailed(runner, logger, err), nil
+			}
+			if err := r.applyTokenSecret(ctx, runner, &tokenSecret, expectedTokenSecret, logger); err != nil {
 				return ctrl.Result{}, err
 			}
-			if !reflect.DeepEqual(tokenSecret.Data, expectedTokenSecret.Data) ||
-				!reflect.DeepEqual(tokenSecret.StringData, expectedTokenSecret.StringData) {
-				tokenSecret.Annotations = expectedTokenSecret.Annotations
-				tokenSecret.Data = expectedTokenSecret.Data
-				tokenSecret.StringData = expectedTokenSecret.StringData
-
-				if err := r.Update(ctx, &tokenSecret); err != nil {
-					return ctrl.Result{}, err
-				}
-				r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulUpdated", "Updated token secret: %q", tokenSecret.Name)
-				logger.V(1).Info("update", "secret", tokenSecret)
 
-				expire, err := time.Parse(time.RFC3339, tokenSecret.Annotations[expiresAtAnnotation])
-				if err != nil {
-					return ctrl.Result{}, err
-				}
-				requeueAfter = expire.Sub(time.Now()) - time.Minute
+			expire, err := time.Parse(time.RFC3339, expectedTokenSecret.Annotations[expiresAtAnnotation])
+			if err != nil {
+				return ctrl.Result{}, err
 			}
+			tokenExpiresAt = &metaV1.Time{Time: expire}
+			r.TokenSecondsUntilExpiry.WithLabelValues(req.Name, req.Namespace).Set(time.Until(expire).Seconds())
 		}
 
 		runner.Spec.TokenSecretKeyRef = &coreV1.SecretKeySelector{
 			LocalObjectReference: coreV1.LocalObjectReference{
-				Name: req.Name,
+				Name: tokenSecretName(runner),
 			},
 			Key: "GITHUB_TOKEN",
 		}
 	}
 
-	var workspaceConfigMap v1.ConfigMap
-	if err := r.Client.Get(
-		ctx,
-		client.ObjectKey{
-			Name:      req.Name + "-workspace",
-			Namespace: req.Namespace,
-		},
-		&workspaceConfigMap,
-	); apierrors.IsNotFound(err) {
-		workspaceConfigMap = *r.buildWorkspaceConfigMap(runner)
-		if err := controllerutil.SetControllerReference(runner, &workspaceConfigMap, r.Scheme); err != nil {
-			return ctrl.Result{}, err
-		}
-		if err := r.Create(ctx, &workspaceConfigMap); err != nil {
-			return ctrl.Result{}, err
-		}
-		r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulCreated", "Created workspace config map: %q", workspaceConfigMap.Name)
-		logger.V(1).Info("create", "config map", workspaceConfigMap)
-	} else if err != nil {
+	if err := r.reconcileWorkspace(ctx, runner, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileImageDigest(ctx, runner, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if result, err := r.reconcileDeployment(ctx, runner, logger); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	if err := r.updateStatus(ctx, runner, tokenExpiresAt); err != nil {
 		return ctrl.Result{}, err
+	}
+
+	// Token secrets are refreshed before their expiry by TokenRefresher, so runners are not requeued for them
+	return ctrl.Result{}, nil
+}
+
+// selectsRunner returns whether the runner is managed by the controller
+func (r *RunnerReconciler) selectsRunner(runner *garV1.Runner) bool {
+	if r.RunnerSelector == nil {
+		return true
+	}
+	selector, err := metaV1.LabelSelectorAsSelector(r.RunnerSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(runner.Labels))
+}
+
+// withPeriodicResync requeues the runner within its periodic resync interval,
+// so that drift not notified by watches, e.g. resources deleted while the controller is down, is repaired
+func withPeriodicResync(result ctrl.Result, runner *garV1.Runner) ctrl.Result {
+	interval := runner.Spec.PeriodicResyncInterval
+	if interval == nil || interval.Duration <= 0 || (result.Requeue && result.RequeueAfter <= 0) {
+		return result
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > interval.Duration {
+		result.RequeueAfter = interval.Duration
+	}
+	return result
+}
+
+func (r *RunnerReconciler) recordRunnerClass(ctx context.Context, runner *garV1.Runner, runnerClass *garV1.RunnerClass) error {
+	var runnerClassName string
+	if runnerClass != nil {
+		runnerClassName = runnerClass.Name
+	}
+	if runner.Annotations[runnerClassAnnotation] == runnerClassName {
+		return nil
+	}
+
+	patch := client.MergeFrom(runner.DeepCopy())
+	if runnerClassName == "" {
+		delete(runner.Annotations, runnerClassAnnotation)
 	} else {
-		expectedWorkspaceConfigMap := r.buildWorkspaceConfigMap(runner)
-		if !reflect.DeepEqual(workspaceConfigMap.Data, expectedWorkspaceConfigMap.Data) ||
-			!reflect.DeepEqual(workspaceConfigMap.BinaryData, expectedWorkspaceConfigMap.BinaryData) {
-			workspaceConfigMap.Data = expectedWorkspaceConfigMap.Data
-			workspaceConfigMap.BinaryData = expectedWorkspaceConfigMap.BinaryData
+		if runner.Annotations == nil {
+			runner.Annotations = map[string]string{}
+		}
+		runner.Annotations[runnerClassAnnotation] = runnerClassName
+	}
+	return r.Patch(ctx, runner, patch)
+}
 
-			if err := r.Update(ctx, &workspaceConfigMap); err != nil {
-				return ctrl.Result{}, err
+// mapRunnerClassToRunners fans out changes of a runner class to the runners it selects and the ones which inherited it,
+// so that runners stop inheriting it when its selector no longer matches or it is deleted
+func (r *RunnerReconciler) mapRunnerClassToRunners(ctx context.Context, obj client.Object) []reconcile.Request {
+	runnerClass, ok := obj.(*garV1.RunnerClass)
+	if !ok {
+		return nil
+	}
+	selector, err := metaV1.LabelSelectorAsSelector(&runnerClass.Spec.Selector)
+	if err != nil {
+		selector = labels.Nothing()
+	}
+
+	var runners garV1.RunnerList
+	if err := r.List(ctx, &runners); err != nil {
+		r.Log.Error(err, "failed to list runners on changes of runner class")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, runner := range runners.Items {
+		if (selector.Empty() || !selector.Matches(labels.Set(runner.Labels))) && runner.Annotations[runnerClassAnnotation] != runnerClass.Name {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&runner),
+		})
+	}
+	return requests
+}
+
+func (r *RunnerReconciler) updateStatus(ctx context.Context, runner *garV1.Runner, tokenExpiresAt *metaV1.Time) error {
+	// The deployment is missing in dry-run mode, which is regarded as not ready
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	status := runner.Status.DeepCopy()
+	status.BuiltImageRepository = r.buildRepositoryName(runner)
+	status.TokenExpiresAt = tokenExpiresAt
+	meta.SetStatusCondition(&status.Conditions, buildDeploymentReadyCondition(&deployment, runner.Generation))
+	meta.RemoveStatusCondition(&status.Conditions, garV1.RunnerConditionImageNotAllowed)
+	if equality.Semantic.DeepEqual(&runner.Status, status) {
+		return nil
+	}
+
+	patch := client.MergeFrom(runner.DeepCopy())
+	runner.Status = *status
+	return r.Status().Patch(ctx, runner, patch)
+}
+
+func (r *RunnerReconciler) allowsImage(image string) bool {
+	if len(r.AllowedBaseImages) == 0 {
+		return true
+	}
+	for _, pattern := range r.AllowedBaseImages {
+		if matched, err := path.Match(pattern, image); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectImage leaves owned resources as they are, so that runners whose image is disallowed later keep running with the previous one
+func (r *RunnerReconciler) rejectImage(ctx context.Context, runner *garV1.Runner, logger logr.Logger) error {
+	r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonImageNotAllowed, "Image %q is not allowed by the controller", runner.Spec.Image)
+	logger.Info("skip reconciliation since image is not allowed", "image", runner.Spec.Image)
+
+	status := runner.Status.DeepCopy()
+	meta.SetStatusCondition(&status.Conditions, metaV1.Condition{
+		Type:               garV1.RunnerConditionImageNotAllowed,
+		Status:             metaV1.ConditionTrue,
+		ObservedGeneration: runner.Generation,
+		Reason:             "NotInAllowList",
+		Message:            fmt.Sprintf("Image %q matches none of the allowed base images", runner.Spec.Image),
+	})
+	if equality.Semantic.DeepEqual(&runner.Status, status) {
+		return nil
+	}
+
+	patch := client.MergeFrom(runner.DeepCopy())
+	runner.Status = *status
+	return r.Status().Patch(ctx, runner, patch)
+}
+
+func buildDeploymentReadyCondition(deployment *appsV1.Deployment, observedGeneration int64) metaV1.Condition {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.UpdatedReplicas < replicas {
+		return metaV1.Condition{
+			Type:               garV1.RunnerConditionDeploymentReady,
+			Status:             metaV1.ConditionFalse,
+			ObservedGeneration: observedGeneration,
+			Reason:             "RolloutInProgress",
+			Message:            fmt.Sprintf("%d of %d replicas are updated", deployment.Status.UpdatedReplicas, replicas),
+		}
+	}
+	if deployment.Status.ReadyReplicas < replicas {
+		return metaV1.Condition{
+			Type:               garV1.RunnerConditionDeploymentReady,
+			Status:             metaV1.ConditionFalse,
+			ObservedGeneration: observedGeneration,
+			Reason:             "ReplicasNotReady",
+			Message:            fmt.Sprintf("%d of %d replicas are ready", deployment.Status.ReadyReplicas, replicas),
+		}
+	}
+	return metaV1.Condition{
+		Type:               garV1.RunnerConditionDeploymentReady,
+		Status:             metaV1.ConditionTrue,
+		ObservedGeneration: observedGeneration,
+		Reason:             "ReplicasReady",
+		Message:            fmt.Sprintf("%d of %d replicas are ready", deployment.Status.ReadyReplicas, replicas),
+	}
+}
+
+func (r *RunnerReconciler) reconcileWorkspace(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (err error) {
+	ctx, span := r.Tracer.Start(ctx, "reconcileWorkspace")
+	defer func() { endSpan(span, err) }()
+
+	if runner.Spec.WorkspacePVC != nil {
+		var workspacePVC v1.PersistentVolumeClaim
+		if err := r.Client.Get(
+			ctx,
+			client.ObjectKey{
+				Name:      runner.Name + "-workspace",
+				Namespace: runner.Namespace,
+			},
+			&workspacePVC,
+		); apierrors.IsNotFound(err) {
+			workspacePVC = *r.buildWorkspacePVC(runner)
+			if err := controllerutil.SetControllerReference(runner, &workspacePVC, r.Scheme); err != nil {
+				return err
+			}
+			if err := r.Create(ctx, &workspacePVC); err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create workspace persistent volume claim %q: %v", workspacePVC.Name, err)
+				return err
+			}
+			r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created workspace persistent volume claim: %q", workspacePVC.Name), "")
+			logger.V(1).Info("create", "persistent volume claim", workspacePVC)
+		} else if err != nil {
+			return err
+		}
+	} else if runner.Spec.UseSecretForDockerfile {
+		var workspaceSecret v1.Secret
+		if err := r.Client.Get(
+			ctx,
+			client.ObjectKey{
+				Name:      runner.Name + "-workspace",
+				Namespace: runner.Namespace,
+			},
+			&workspaceSecret,
+		); apierrors.IsNotFound(err) {
+			workspaceSecret = *r.buildWorkspaceSecret(runner)
+			if err := controllerutil.SetControllerReference(runner, &workspaceSecret, r.Scheme); err != nil {
+				return err
+			}
+			if err := r.Create(ctx, &workspaceSecret); err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create workspace secret %q: %v", workspaceSecret.Name, err)
+				return err
+			}
+			r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created workspace secret: %q", workspaceSecret.Name), "")
+			logger.V(1).Info("create", "secret", redactSecret(&workspaceSecret))
+		} else if err != nil {
+			return err
+		} else {
+			expectedWorkspaceSecret := r.buildWorkspaceSecret(runner)
+			r.logDiff(logger, "Secret", redactSecret(expectedWorkspaceSecret), redactSecret(&workspaceSecret))
+			if !reflect.DeepEqual(workspaceSecret.Data, expectedWorkspaceSecret.Data) ||
+				!reflect.DeepEqual(workspaceSecret.Labels, expectedWorkspaceSecret.Labels) ||
+				!reflect.DeepEqual(workspaceSecret.Annotations, expectedWorkspaceSecret.Annotations) {
+				workspaceSecret.Labels = expectedWorkspaceSecret.Labels
+				workspaceSecret.Annotations = expectedWorkspaceSecret.Annotations
+				workspaceSecret.Data = expectedWorkspaceSecret.Data
+
+				if err := r.Update(ctx, &workspaceSecret); err != nil {
+					r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to update secret %q: %v", workspaceSecret.Name, err)
+					return err
+				}
+				// The Dockerfile is not recorded as the diff since it is kept in the secret not to be visible
+				r.recordChange(runner, logger, EventReasonUpdated, fmt.Sprintf("Updated secret: %q", workspaceSecret.Name), "")
+				logger.V(1).Info("update", "secret", redactSecret(&workspaceSecret))
+			}
+		}
+	} else {
+		var workspaceConfigMap v1.ConfigMap
+		if err := r.Client.Get(
+			ctx,
+			client.ObjectKey{
+				Name:      runner.Name + "-workspace",
+				Namespace: runner.Namespace,
+			},
+			&workspaceConfigMap,
+		); apierrors.IsNotFound(err) {
+			workspaceConfigMap = *r.buildWorkspaceConfigMap(runner)
+			if err := controllerutil.SetControllerReference(runner, &workspaceConfigMap, r.Scheme); err != nil {
+				return err
+			}
+			if err := r.Create(ctx, &workspaceConfigMap); err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create workspace config map %q: %v", workspaceConfigMap.Name, err)
+				return err
+			}
+			r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created workspace config map: %q", workspaceConfigMap.Name), "")
+			logger.V(1).Info("create", "config map", workspaceConfigMap)
+		} else if err != nil {
+			return err
+		} else {
+			expectedWorkspaceConfigMap := r.buildWorkspaceConfigMap(runner)
+			r.logDiff(logger, "ConfigMap", expectedWorkspaceConfigMap, &workspaceConfigMap)
+			if !reflect.DeepEqual(workspaceConfigMap.Data, expectedWorkspaceConfigMap.Data) ||
+				!reflect.DeepEqual(workspaceConfigMap.BinaryData, expectedWorkspaceConfigMap.BinaryData) ||
+				!reflect.DeepEqual(workspaceConfigMap.Labels, expectedWorkspaceConfigMap.Labels) ||
+				!reflect.DeepEqual(workspaceConfigMap.Annotations, expectedWorkspaceConfigMap.Annotations) {
+				diff := cmp.Diff(workspaceConfigMap.Data, expectedWorkspaceConfigMap.Data)
+				workspaceConfigMap.Labels = expectedWorkspaceConfigMap.Labels
+				workspaceConfigMap.Annotations = expectedWorkspaceConfigMap.Annotations
+				workspaceConfigMap.Data = expectedWorkspaceConfigMap.Data
+				workspaceConfigMap.BinaryData = expectedWorkspaceConfigMap.BinaryData
+
+				if err := r.Update(ctx, &workspaceConfigMap); err != nil {
+					r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to update config map %q: %v", workspaceConfigMap.Name, err)
+					return err
+				}
+				r.recordChange(runner, logger, EventReasonUpdated, fmt.Sprintf("Updated config map: %q", workspaceConfigMap.Name), diff)
+				logger.V(1).Info("update", "config map", workspaceConfigMap)
 			}
-			r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulUpdated", "Updated config map: %q", workspaceConfigMap.Name)
-			logger.V(1).Info("update", "config map", workspaceConfigMap)
 		}
 	}
 
+	return nil
+}
+
+func (r *RunnerReconciler) reconcileDeployment(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (_ ctrl.Result, err error) {
+	ctx, span := r.Tracer.Start(ctx, "reconcileDeployment")
+	defer func() { endSpan(span, err) }()
+
+	expectedDeployment := r.buildDeployment(runner)
+	expectedDeployment.TypeMeta = metaV1.TypeMeta{
+		APIVersion: appsV1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+	}
+	if err := controllerutil.SetControllerReference(runner, expectedDeployment, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	var deployment appsV1.Deployment
 	if err := r.Client.Get(
 		ctx,
 		client.ObjectKey{
-			Name:      req.Name + "-runner",
-			Namespace: req.Namespace,
+			Name:      expectedDeployment.Name,
+			Namespace: expectedDeployment.Namespace,
 		},
 		&deployment,
 	); apierrors.IsNotFound(err) {
-		deployment = *r.buildDeployment(runner)
-		if err := controllerutil.SetControllerReference(runner, &deployment, r.Scheme); err != nil {
+		if ready, err := r.reconcileBuildJob(ctx, runner, logger); err != nil {
 			return ctrl.Result{}, err
+		} else if !ready {
+			return ctrl.Result{}, nil
+		}
+		if ready, err := r.reconcileWarmup(ctx, runner, logger); err != nil {
+			return ctrl.Result{}, err
+		} else if !ready {
+			return ctrl.Result{}, nil
 		}
-		if err := r.Create(ctx, &deployment); err != nil {
+
+		// Creation exceeding quotas is postponed with a clear event rather than repeated failures of the API server
+		if !r.DisableResourceQuotaCheck {
+			exceeded, err := r.checkResourceQuotas(ctx, expectedDeployment)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if exceeded != "" {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonResourceQuotaExceeded, "Deployment %q is not created since it would exceed resource quotas: %s", expectedDeployment.Name, exceeded)
+				logger.Info("postpone creating deployment exceeding resource quotas", "deployment", expectedDeployment.Name, "exceeded", exceeded)
+				return ctrl.Result{RequeueAfter: resourceQuotaRequeueInterval}, nil
+			}
+		}
+
+		if err := r.Patch(ctx, expectedDeployment, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create deployment %q: %v", expectedDeployment.Name, err)
 			return ctrl.Result{}, err
 		}
-		r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulCreated", "Created deployment: %q", deployment.Name)
-		logger.V(1).Info("create", "deployment", deployment)
+		r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created deployment: %q", expectedDeployment.Name), "")
+		logger.V(1).Info("create", "deployment", expectedDeployment)
 	} else if err != nil {
 		return ctrl.Result{}, err
 	} else {
-		expectedDeployment := r.buildDeployment(runner)
-		if !reflect.DeepEqual(deployment.Spec.Template, expectedDeployment.Spec.Template) {
-			deployment.Spec.Template = expectedDeployment.Spec.Template
-
-			if err := r.Update(ctx, &deployment); err != nil {
-				if strings.Contains(err.Error(), optimisticLockErrorMsg) {
-					return ctrl.Result{RequeueAfter: time.Second}, nil
+		r.logDiff(logger, "Deployment", expectedDeployment, &deployment)
+		// Replicas are compared only when specified explicitly so as not to fight with HPA
+		replicasChanged := runner.Spec.Replicas != nil &&
+			(deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != *expectedDeployment.Spec.Replicas)
+		// Pod template is compared by hash of the rendered one so as not to be confused by fields defaulted by Kubernetes.
+		// Selector is immutable, so its modification is rejected by the dry-run below
+		if deployment.Annotations[specHashAnnotation] != expectedDeployment.Annotations[specHashAnnotation] ||
+			deployment.Annotations[metadataHashAnnotation] != expectedDeployment.Annotations[metadataHashAnnotation] ||
+			!reflect.DeepEqual(deployment.Spec.Strategy, expectedDeployment.Spec.Strategy) ||
+			!reflect.DeepEqual(deployment.Spec.Selector, expectedDeployment.Spec.Selector) ||
+			deployment.Spec.Template.Labels["app"] != expectedDeployment.Spec.Template.Labels["app"] ||
+			replicasChanged {
+			if runner.Spec.Replicas == nil {
+				// Leave replicas to the current owner such as HPA
+				expectedDeployment.Spec.Replicas = nil
+			}
+			// Runner pods are kept on the current image until a new one is built in the build namespace
+			if containerImage(&deployment.Spec.Template.Spec, "runner") != containerImage(&expectedDeployment.Spec.Template.Spec, "runner") {
+				if ready, err := r.reconcileBuildJob(ctx, runner, logger); err != nil {
+					return ctrl.Result{}, err
+				} else if !ready {
+					return ctrl.Result{}, nil
 				}
+			}
+
+			// Updates rejected by validation, e.g. of the immutable selector, are never accepted by retries,
+			// so they are found by dry-run in advance and reported without failing reconciliation repeatedly
+			if err := r.Patch(ctx, expectedDeployment.DeepCopy(), client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership, client.DryRunAll); apierrors.IsInvalid(err) {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonInvalidDeployment, "Deployment %q is not updated since it is rejected by the API server: %v", expectedDeployment.Name, err)
+				logger.Info("skip updating invalid deployment", "deployment", expectedDeployment.Name, "error", err.Error())
+				return ctrl.Result{RequeueAfter: invalidDeploymentRequeueInterval}, nil
+			} else if err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to update deployment %q: %v", expectedDeployment.Name, err)
 				return ctrl.Result{}, err
 			}
-			r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulUpdated", "Updated deployment: %q", deployment.Name)
-			logger.V(1).Info("update", "deployment", deployment)
+			if err := r.Patch(ctx, expectedDeployment, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+				r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to update deployment %q: %v", expectedDeployment.Name, err)
+				return ctrl.Result{}, err
+			}
+			r.recordChange(runner, logger, EventReasonUpdated, fmt.Sprintf("Updated deployment: %q", expectedDeployment.Name), cmp.Diff(deployment.Spec, expectedDeployment.Spec))
+			logger.V(1).Info("update", "deployment", expectedDeployment)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func containerImage(spec *v1.PodSpec, name string) string {
+	for _, container := range spec.Containers {
+		if container.Name == name {
+			return container.Image
 		}
 	}
+	return ""
+}
+
+// logDiff logs the diff between the expected and actual objects at the default level in debug mode
+func (r *RunnerReconciler) logDiff(logger logr.Logger, kind string, expected client.Object, actual client.Object) {
+	if !r.Debug {
+		return
+	}
+	logger.Info("diff of expected and actual objects", "kind", kind, "name", actual.GetName(), "diff", diffObjects(expected, actual))
+}
+
+// diffObjects returns the line diff of JSON of the objects, where lines prefixed by - are actual and + are expected
+func diffObjects(expected interface{}, actual interface{}) string {
+	expectedJSON, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("failed to marshal expected object: %v", err)
+	}
+	actualJSON, err := json.MarshalIndent(actual, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("failed to marshal actual object: %v", err)
+	}
+	return cmp.Diff(string(actualJSON), string(expectedJSON))
+}
+
+// redactSecret returns a copy of the secret whose values are replaced with their hashes, so that changes are visible without leaking them
+func redactSecret(secret *v1.Secret) *v1.Secret {
+	redacted := secret.DeepCopy()
+	redacted.Data = map[string][]byte{}
+	for key, value := range secret.Data {
+		redacted.Data[key] = redactValue(value)
+	}
+	// The API server converts StringData into Data, so both are compared as Data
+	for key, value := range secret.StringData {
+		redacted.Data[key] = redactValue([]byte(value))
+	}
+	redacted.StringData = nil
+	return redacted
+}
+
+func redactValue(value []byte) []byte {
+	sum := sha256.Sum256(value)
+	return []byte(fmt.Sprintf("sha256:%x", sum[:8]))
+}
+
+// recordChange records an event of the change, or of the proposed change with its diff in dry-run mode
+func (r *RunnerReconciler) recordChange(runner *garV1.Runner, logger logr.Logger, reason string, message string, diff string) {
+	if !r.DryRun {
+		r.Recorder.Event(runner, coreV1.EventTypeNormal, reason, message)
+		return
+	}
+	r.Recorder.Eventf(runner, coreV1.EventTypeNormal, EventReasonDryRunProposedChange, "%s (dry-run)", message)
+	logger.Info("dry-run proposed change", "change", message, "diff", diff)
+}
 
-	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+// validatePersonalAccessToken checks the secret of the personal access token exists and has the token,
+// while its scopes are validated at admission not to record the same events on every reconciliation
+func (r *RunnerReconciler) validatePersonalAccessToken(ctx context.Context, runner *garV1.Runner) error {
+	secretRef := runner.Spec.PersonalAccessTokenRef.SecretRef
+
+	var secret v1.Secret
+	if err := r.Client.Get(
+		ctx,
+		client.ObjectKey{
+			Name:      secretRef.Name,
+			Namespace: runner.Namespace,
+		},
+		&secret,
+	); err != nil {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonInvalidPersonalAccessToken, "Failed to get personal access token secret: %q", secretRef.Name)
+		return xerrors.Errorf("failed to get personal access token secret: %w", err)
+	}
+	if len(secret.Data[secretRef.Key]) == 0 {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonInvalidPersonalAccessToken, "Personal access token secret %q has no value for key %q", secretRef.Name, secretRef.Key)
+		return xerrors.Errorf("personal access token secret %q has no value for key %q", secretRef.Name, secretRef.Key)
+	}
+	return nil
 }
 
 func (r *RunnerReconciler) buildRepositoryName(runner *garV1.Runner) string {
+	// Architecture, UID and GID are included only when they are not the default ones to keep names of the images built so far
+	var variant string
+	if binaryArch := r.buildBinaryArch(runner); binaryArch != "amd64" {
+		variant = binaryArch
+	}
+	if uid, gid := buildRunnerUID(runner), buildRunnerGID(runner); uid != defaultRunnerUID || gid != defaultRunnerGID {
+		variant += fmt.Sprintf(":%d:%d", uid, gid)
+	}
+
 	named, err := dockerref.ParseNormalizedNamed(runner.Spec.Image)
 	if err != nil {
-		return fmt.Sprintf("%x", sha256.Sum256([]byte(runner.Spec.Image+r.BinaryVersion+r.RunnerVersion)))[:7]
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(runner.Spec.Image+r.BinaryVersion+r.runnerVersionOf(runner)+variant)))[:7]
 	}
 	trimmed := dockerref.TrimNamed(named).String()
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(trimmed+r.BinaryVersion+r.RunnerVersion)))[:7]
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(trimmed+r.BinaryVersion+r.runnerVersionOf(runner)+variant)))[:7]
 }
 
-func (r *RunnerReconciler) buildBuilderContainer(runner *garV1.Runner) v1.Container {
-	if runner.Spec.BuilderContainerSpec.Resources.Limits == nil {
-		runner.Spec.BuilderContainerSpec.Resources.Limits = make(v1.ResourceList)
+func (r *RunnerReconciler) buildBinaryArch(runner *garV1.Runner) string {
+	if runner.Spec.BinaryArch != "" {
+		return runner.Spec.BinaryArch
 	}
-	if runner.Spec.BuilderContainerSpec.Resources.Limits.Memory().IsZero() {
-		runner.Spec.BuilderContainerSpec.Resources.Limits[v1.ResourceMemory] = resource.MustParse("4Gi")
+	if r.BinaryArch != "" {
+		return r.BinaryArch
 	}
-	return v1.Container{
-		Name:            "kaniko",
-		Image:           r.KanikoImage,
-		ImagePullPolicy: v1.PullIfNotPresent,
-		Args: []string{
-			"--dockerfile=Dockerfile",
-			"--context=dir:///workspace",
-			"--cache=true",
-			"--compressed-caching=false",
-			fmt.Sprintf("--destination=%s/%s", r.PushRegistryHost, r.buildRepositoryName(runner)),
-		},
-		EnvFrom: runner.Spec.BuilderContainerSpec.EnvFrom,
-		Env:     runner.Spec.BuilderContainerSpec.Env,
-		VolumeMounts: append([]v1.VolumeMount{
-			{
-				Name:      "workspace",
-				MountPath: "/workspace/Dockerfile",
-				SubPath:   "Dockerfile",
-				ReadOnly:  true,
-			},
-		}, runner.Spec.BuilderContainerSpec.VolumeMounts...),
-		Resources:                runner.Spec.BuilderContainerSpec.Resources,
-		TerminationMessagePath:   coreV1.TerminationMessagePathDefault,
-		TerminationMessagePolicy: coreV1.TerminationMessageReadFile,
+	return "amd64"
+}
+
+func buildRunnerUID(runner *garV1.Runner) int64 {
+	if runner.Spec.RunnerUID != nil {
+		return *runner.Spec.RunnerUID
 	}
+	return defaultRunnerUID
 }
 
-func (r *RunnerReconciler) buildRunnerContainer(runner *garV1.Runner) v1.Container {
-	args := []string{
-		"--without-install",
-		"--repository=$(REPOSITORY)",
-		"--hostname=$(HOSTNAME)",
+func buildRunnerGID(runner *garV1.Runner) int64 {
+	if runner.Spec.RunnerGID != nil {
+		return *runner.Spec.RunnerGID
 	}
-	env := runner.Spec.RunnerContainerSpec.Env
-	envFrom := runner.Spec.RunnerContainerSpec.EnvFrom
+	return defaultRunnerGID
+}
 
-	env = append(env, []coreV1.EnvVar{
-		{
+// buildSecretVolumes builds the volumes of secrets mounted into the runner container, named by their index
+func buildSecretVolumes(runner *garV1.Runner) []v1.Volume {
+	var volumes []v1.Volume
+	for i, mount := range runner.Spec.SecretVolumeMounts {
+		volumes = append(volumes, v1.Volume{
+			Name: fmt.Sprintf("secret-volume-%d", i),
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: mount.SecretName,
+					Items:      mount.Items,
+					DefaultMode: func(i int32) *int32 {
+						return &i
+					}(420),
+				},
+			},
+		})
+	}
+	return volumes
+}
+
+func buildSecretVolumeMounts(runner *garV1.Runner) []v1.VolumeMount {
+	var volumeMounts []v1.VolumeMount
+	for i, mount := range runner.Spec.SecretVolumeMounts {
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      fmt.Sprintf("secret-volume-%d", i),
+			MountPath: mount.MountPath,
+			ReadOnly:  true,
+		})
+	}
+	return volumeMounts
+}
+
+func (r *RunnerReconciler) buildRunnerImage(runner *garV1.Runner) string {
+	if runner.Spec.PreBuiltImage != "" {
+		return runner.Spec.PreBuiltImage
+	}
+	return fmt.Sprintf("%s/%s", r.PullRegistryHost, r.buildRepositoryName(runner))
+}
+
+func (r *RunnerReconciler) buildBuilderContainer(runner *garV1.Runner) v1.Container {
+	volumeMounts := []v1.VolumeMount{
+		{
+			Name:      "workspace",
+			MountPath: "/workspace/Dockerfile",
+			SubPath:   "Dockerfile",
+			ReadOnly:  true,
+		},
+	}
+	if runner.Spec.CACertSecretRef != nil {
+		volumeMounts = append(volumeMounts, []v1.VolumeMount{
+			{
+				Name:      "ca-cert",
+				MountPath: "/workspace/" + customCACertFileName,
+				SubPath:   customCACertFileName,
+				ReadOnly:  true,
+			},
+			{
+				Name:      "ca-cert",
+				MountPath: "/kaniko/ssl/certs/" + customCACertFileName,
+				SubPath:   customCACertFileName,
+				ReadOnly:  true,
+			},
+		}...)
+	}
+	args := []string{
+		"--dockerfile=Dockerfile",
+		"--context=dir:///workspace",
+		"--cache=true",
+		"--compressed-caching=false",
+		fmt.Sprintf("--destination=%s/%s", r.PushRegistryHost, r.buildRepositoryName(runner)),
+	}
+	for _, mirror := range runner.Spec.BuilderContainerSpec.RegistryMirrors {
+		args = append(args, fmt.Sprintf("--registry-mirror=%s", mirror))
+	}
+	for _, registry := range runner.Spec.BuilderContainerSpec.InsecureRegistries {
+		args = append(args, fmt.Sprintf("--insecure-registry=%s", registry))
+	}
+	if runner.Spec.BuilderContainerSpec.InsecurePushRegistry {
+		args = append(args, "--insecure")
+	}
+	if runner.Spec.BuilderContainerSpec.SkipTLSVerifyPush {
+		args = append(args, "--skip-tls-verify")
+	}
+	if runner.Spec.BuilderContainerSpec.InsecurePullRegistry {
+		args = append(args, "--insecure-pull")
+	}
+	if runner.Spec.BuilderContainerSpec.SkipTLSVerifyPull {
+		args = append(args, "--skip-tls-verify-pull")
+	}
+	return v1.Container{
+		Name:                     "kaniko",
+		Image:                    r.KanikoImage,
+		ImagePullPolicy:          v1.PullIfNotPresent,
+		Command:                  buildBuilderCommand(runner.Spec.BuilderContainerSpec.BuildTimeout),
+		Args:                     args,
+		EnvFrom:                  runner.Spec.BuilderContainerSpec.EnvFrom,
+		Env:                      append(r.buildProxyEnv(runner), runner.Spec.BuilderContainerSpec.Env...),
+		VolumeMounts:             append(volumeMounts, runner.Spec.BuilderContainerSpec.VolumeMounts...),
+		Resources:                buildBuilderResources(runner.Spec.BuilderContainerSpec.Resources, r.DefaultBuilderResources),
+		TerminationMessagePath:   buildTerminationMessagePath(runner.Spec.BuilderContainerSpec.TerminationMessagePath),
+		TerminationMessagePolicy: buildTerminationMessagePolicy(runner.Spec.BuilderContainerSpec.TerminationMessagePolicy),
+	}
+}
+
+// buildBuilderCommand wraps the executor by timeout of the shell when the build timeout is set,
+// where args of the container are passed to the executor as positional parameters so that they can be appended later.
+// The entrypoint of the image is used otherwise.
+func buildBuilderCommand(timeout *metaV1.Duration) []string {
+	if timeout == nil {
+		return nil
+	}
+	seconds := buildTimeoutSeconds(timeout)
+	return []string{
+		"sh",
+		"-c",
+		fmt.Sprintf(`timeout %d /kaniko/executor "$@"; status=$?; if [ $status -eq 124 ] || [ $status -eq 143 ]; then echo "Build timed out after %d seconds"; fi; exit $status`, seconds, seconds),
+		"--",
+	}
+}
+
+// buildTimeoutSeconds rounds the build timeout up to seconds, which is the unit of timeout and activeDeadlineSeconds
+func buildTimeoutSeconds(timeout *metaV1.Duration) int64 {
+	return int64((timeout.Duration + time.Second - 1) / time.Second)
+}
+
+// buildActiveDeadlineSeconds bounds jobs running the builder container in case the shell itself hangs.
+// The deadline covers all attempts of the job including scheduling and image pulls, so it is longer than the build timeout.
+func buildActiveDeadlineSeconds(timeout *metaV1.Duration, backoffLimit int32) *int64 {
+	if timeout == nil {
+		return nil
+	}
+	seconds := (buildTimeoutSeconds(timeout) + int64(builderStartupTimeout/time.Second)) * int64(backoffLimit+1)
+	return &seconds
+}
+
+func buildTerminationMessagePath(path string) string {
+	if path == "" {
+		return coreV1.TerminationMessagePathDefault
+	}
+	return path
+}
+
+func buildTerminationMessagePolicy(policy *v1.TerminationMessagePolicy) v1.TerminationMessagePolicy {
+	if policy == nil {
+		return coreV1.TerminationMessageReadFile
+	}
+	return *policy
+}
+
+// buildResources fills empty limits and requests of resources with the defaults.
+func buildResources(resources v1.ResourceRequirements, defaults v1.ResourceRequirements) v1.ResourceRequirements {
+	resources = *resources.DeepCopy()
+	if len(resources.Limits) == 0 && len(defaults.Limits) != 0 {
+		resources.Limits = defaults.Limits.DeepCopy()
+	}
+	if len(resources.Requests) == 0 && len(defaults.Requests) != 0 {
+		resources.Requests = defaults.Requests.DeepCopy()
+	}
+	return resources
+}
+
+// buildBuilderResources falls back to the memory limit defaulted at admission for runners admitted without the mutating webhook,
+// without mutating their spec
+func buildBuilderResources(resources v1.ResourceRequirements, defaults v1.ResourceRequirements) v1.ResourceRequirements {
+	resources = buildResources(resources, defaults)
+	if resources.Limits.Memory().IsZero() {
+		if resources.Limits == nil {
+			resources.Limits = v1.ResourceList{}
+		}
+		resources.Limits[v1.ResourceMemory] = resource.MustParse(garV1.DefaultBuilderMemoryLimit)
+	}
+	return resources
+}
+
+func (r *RunnerReconciler) buildProxyEnv(runner *garV1.Runner) []v1.EnvVar {
+	if runner.Spec.ProxySettings == nil {
+		return nil
+	}
+
+	noProxy := defaultNoProxy
+	if runner.Spec.ProxySettings.NoProxy != "" {
+		noProxy = runner.Spec.ProxySettings.NoProxy + "," + defaultNoProxy
+	}
+
+	var env []v1.EnvVar
+	for _, e := range []struct {
+		name  string
+		value string
+	}{
+		{"HTTP_PROXY", runner.Spec.ProxySettings.HTTPProxy},
+		{"HTTPS_PROXY", runner.Spec.ProxySettings.HTTPSProxy},
+		{"NO_PROXY", noProxy},
+	} {
+		if e.value == "" {
+			continue
+		}
+		env = append(env, v1.EnvVar{
+			Name:  e.name,
+			Value: e.value,
+		}, v1.EnvVar{
+			Name:  strings.ToLower(e.name),
+			Value: e.value,
+		})
+	}
+	return env
+}
+
+func (r *RunnerReconciler) buildRunnerContainer(runner *garV1.Runner) v1.Container {
+	args := []string{
+		"--without-install",
+		"--repository=$(REPOSITORY)",
+		"--hostname=$(HOSTNAME)",
+	}
+	env := append(r.buildProxyEnv(runner), runner.Spec.RunnerContainerSpec.Env...)
+	if len(r.GlobalRunnerEnv) > 0 {
+		env = mergeEnv(r.GlobalRunnerEnv, env)
+	}
+	envFrom := runner.Spec.RunnerContainerSpec.EnvFrom
+
+	env = append(env, []coreV1.EnvVar{
+		{
 			Name:  "REPOSITORY",
 			Value: runner.Spec.Repository,
 		},
@@ -279,6 +1169,53 @@ func (r *RunnerReconciler) buildRunnerContainer(runner *garV1.Runner) v1.Contain
 			},
 		},
 	}...)
+	// The repository is still passed since registration tokens are issued for it by GitHub API
+	if runner.Spec.RegistrationURLPattern != "" {
+		args = append(args, "--registration-url=$(REGISTRATION_URL)")
+		env = append(env, coreV1.EnvVar{
+			Name:  "REGISTRATION_URL",
+			Value: fmt.Sprintf(runner.Spec.RegistrationURLPattern, runner.Spec.Repository),
+		})
+	}
+
+	if runner.Spec.InjectPodMetadata {
+		env = append(env, []coreV1.EnvVar{
+			{
+				Name: "NODE_NAME",
+				ValueFrom: &coreV1.EnvVarSource{
+					FieldRef: &coreV1.ObjectFieldSelector{
+						APIVersion: "v1",
+						FieldPath:  "spec.nodeName",
+					},
+				},
+			},
+			{
+				Name: "NAMESPACE",
+				ValueFrom: &coreV1.EnvVarSource{
+					FieldRef: &coreV1.ObjectFieldSelector{
+						APIVersion: "v1",
+						FieldPath:  "metadata.namespace",
+					},
+				},
+			},
+			{
+				Name: "POD_IP",
+				ValueFrom: &coreV1.EnvVarSource{
+					FieldRef: &coreV1.ObjectFieldSelector{
+						APIVersion: "v1",
+						FieldPath:  "status.podIP",
+					},
+				},
+			},
+		}...)
+	}
+
+	if r.JobAuditLogURL != "" {
+		env = append(env, coreV1.EnvVar{
+			Name:  "RUNNER_JOB_LOG_URL",
+			Value: buildJobAuditLogURL(r.JobAuditLogURL, runner),
+		})
+	}
 
 	if runner.Spec.TokenSecretKeyRef != nil {
 		args = append(args, "--token=$(TOKEN)")
@@ -301,35 +1238,99 @@ func (r *RunnerReconciler) buildRunnerContainer(runner *garV1.Runner) v1.Contain
 		})
 	}
 
+	runAsUser := func(i int64) *int64 { return &i }(buildRunnerUID(runner))
+	seccompProfile := &coreV1.SeccompProfile{
+		Type: coreV1.SeccompProfileTypeRuntimeDefault,
+	}
+	if securityContext := runner.Spec.RunnerContainerSpec.SecurityContext; securityContext != nil {
+		if securityContext.RunAsUser != nil {
+			runAsUser = securityContext.RunAsUser
+		}
+		if securityContext.SeccompProfile != nil {
+			seccompProfile = securityContext.SeccompProfile
+		}
+	}
+
+	securityContext := &v1.SecurityContext{
+		Privileged:             func(b bool) *bool { return &b }(false),
+		ReadOnlyRootFilesystem: func(b bool) *bool { return &b }(false),
+		RunAsUser:              runAsUser,
+		RunAsGroup:             runner.Spec.RunnerGID,
+		RunAsNonRoot:           func(b bool) *bool { return &b }(true),
+		SeccompProfile:         seccompProfile,
+	}
+	switch runner.Spec.SecurityProfile {
+	case garV1.SecurityProfileRestricted:
+		securityContext.AllowPrivilegeEscalation = func(b bool) *bool { return &b }(false)
+		securityContext.Capabilities = &v1.Capabilities{
+			Drop: []v1.Capability{"ALL"},
+		}
+	case garV1.SecurityProfilePrivileged:
+		securityContext.Privileged = func(b bool) *bool { return &b }(true)
+	}
+
 	c := v1.Container{
-		Name: "runner",
-		SecurityContext: &v1.SecurityContext{
-			Privileged:             func(b bool) *bool { return &b }(false),
-			ReadOnlyRootFilesystem: func(b bool) *bool { return &b }(false),
-			RunAsUser:              func(i int64) *int64 { return &i }(60000),
-			RunAsNonRoot:           func(b bool) *bool { return &b }(true),
-			SeccompProfile: &coreV1.SeccompProfile{
-				Type: coreV1.SeccompProfileTypeRuntimeDefault,
-			},
-		},
-		Image:                    fmt.Sprintf("%s/%s", r.PullRegistryHost, r.buildRepositoryName(runner)),
+		Name:                     "runner",
+		SecurityContext:          securityContext,
+		Image:                    pinImageDigest(runner, r.buildRunnerImage(runner)),
 		ImagePullPolicy:          v1.PullAlways,
 		Args:                     args,
 		EnvFrom:                  envFrom,
 		Env:                      env,
-		Resources:                runner.Spec.RunnerContainerSpec.Resources,
-		VolumeMounts:             runner.Spec.RunnerContainerSpec.VolumeMounts,
-		TerminationMessagePath:   coreV1.TerminationMessagePathDefault,
-		TerminationMessagePolicy: coreV1.TerminationMessageReadFile,
+		Resources:                buildResources(runner.Spec.RunnerContainerSpec.Resources, r.DefaultRunnerResources),
+		VolumeMounts:             append(buildSecretVolumeMounts(runner), runner.Spec.RunnerContainerSpec.VolumeMounts...),
+		WorkingDir:               runner.Spec.RunnerContainerSpec.WorkingDir,
+		TerminationMessagePath:   buildTerminationMessagePath(runner.Spec.RunnerContainerSpec.TerminationMessagePath),
+		TerminationMessagePolicy: buildTerminationMessagePolicy(runner.Spec.RunnerContainerSpec.TerminationMessagePolicy),
 	}
 	if r.Disableupdate {
 		c.Args = append(c.Args, "--disableupdate")
 	}
+	if runner.Spec.RunnerProcesses > 1 {
+		// Hostnames must be unique per process, so the supervisor appends the index to the one of the pod
+		args := make([]string, 0, len(c.Args))
+		for _, arg := range c.Args {
+			if arg != "--hostname=$(HOSTNAME)" {
+				args = append(args, arg)
+			}
+		}
+		c.Command = []string{
+			"sh",
+			"-c",
+			buildSupervisorScript(runner.Spec.RunnerProcesses),
+			"runner",
+		}
+		c.Args = args
+	}
+	if runner.Spec.RunnerContainerSpec.PreStopHook != nil {
+		c.Lifecycle = &v1.Lifecycle{
+			PreStop: runner.Spec.RunnerContainerSpec.PreStopHook,
+		}
+	}
 	return c
 }
 
+// buildSupervisorScript returns the script launching runner processes with the arguments passed to it,
+// which forwards termination signals to them and waits for all of them to exit
+func buildSupervisorScript(processes int) string {
+	return fmt.Sprintf(`pids=""
+trap 'kill -TERM $pids 2>/dev/null' TERM INT
+i=1
+while [ "$i" -le %d ]; do
+  /usr/local/bin/runner "$@" --hostname="${HOSTNAME}-${i}" &
+  pids="$pids $!"
+  i=$((i + 1))
+done
+for pid in $pids; do
+  while kill -0 "$pid" 2>/dev/null; do
+    wait "$pid"
+  done
+done
+`, processes)
+}
+
 func (r *RunnerReconciler) buildExporterContainer(runner *garV1.Runner) v1.Container {
-	return v1.Container{
+	c := v1.Container{
 		Name:            "exporter",
 		Image:           r.ExporterImage,
 		ImagePullPolicy: v1.PullAlways,
@@ -338,19 +1339,12 @@ func (r *RunnerReconciler) buildExporterContainer(runner *garV1.Runner) v1.Conta
 			"--api-address=0.0.0.0:8000",
 			"--monitor-address=0.0.0.0:9090",
 			"--repository=$(REPOSITORY)",
-			"--token=$(TOKEN)",
 		},
 		Env: []coreV1.EnvVar{
 			{
 				Name:  "REPOSITORY",
 				Value: runner.Spec.Repository,
 			},
-			{
-				Name: "TOKEN",
-				ValueFrom: &coreV1.EnvVarSource{
-					SecretKeyRef: runner.Spec.TokenSecretKeyRef,
-				},
-			},
 		},
 		Ports: []coreV1.ContainerPort{
 			{
@@ -361,6 +1355,53 @@ func (r *RunnerReconciler) buildExporterContainer(runner *garV1.Runner) v1.Conta
 		TerminationMessagePath:   coreV1.TerminationMessagePathDefault,
 		TerminationMessagePolicy: coreV1.TerminationMessageReadFile,
 	}
+
+	if runner.Spec.TokenSecretKeyRef != nil {
+		c.Args = append(c.Args, "--token=$(TOKEN)")
+		c.Env = append(c.Env, coreV1.EnvVar{
+			Name: "TOKEN",
+			ValueFrom: &coreV1.EnvVarSource{
+				SecretKeyRef: runner.Spec.TokenSecretKeyRef,
+			},
+		})
+	}
+
+	if runner.Spec.AppSecretRef != nil {
+		c.Args = append(c.Args, []string{
+			"--github-app-id=$(github_app_id)",
+			"--github-app-installation-id=$(github_app_installation_id)",
+			"--github-app-private-key=$(github_app_private_key)",
+		}...)
+		c.EnvFrom = append(c.EnvFrom, coreV1.EnvFromSource{
+			SecretRef: runner.Spec.AppSecretRef,
+		})
+	}
+
+	if spec := runner.Spec.ExporterContainerSpec; spec != nil {
+		if spec.Image != "" {
+			c.Image = spec.Image
+		}
+		c.Args = append(c.Args, spec.Args...)
+		c.Env = mergeEnv(c.Env, spec.Env)
+		c.Resources = spec.Resources
+	}
+	return c
+}
+
+func mergeEnv(base []v1.EnvVar, overrides []v1.EnvVar) []v1.EnvVar {
+	merged := make([]v1.EnvVar, 0, len(base)+len(overrides))
+	indices := map[string]int{}
+	for _, envs := range [][]v1.EnvVar{base, overrides} {
+		for _, e := range envs {
+			if i, ok := indices[e.Name]; ok {
+				merged[i] = e
+				continue
+			}
+			indices[e.Name] = len(merged)
+			merged = append(merged, e)
+		}
+	}
+	return merged
 }
 
 func (r *RunnerReconciler) buildDeployment(runner *garV1.Runner) *appsV1.Deployment {
@@ -368,14 +1409,153 @@ func (r *RunnerReconciler) buildDeployment(runner *garV1.Runner) *appsV1.Deploym
 		r.buildRunnerContainer(runner),
 	}
 
+	// Runner image is built unless the one already in the registry or pre-built is used,
+	// or the one built by the build job of the build namespace is pulled
+	build := !runner.Spec.SkipBuild && runner.Spec.PreBuiltImage == "" && runner.Spec.BuildNamespace == ""
+	var initContainers []v1.Container
+	if build {
+		initContainers = append(initContainers, r.buildBuilderContainer(runner))
+	}
+	workspaceVolumeSource := v1.VolumeSource{
+		ConfigMap: &v1.ConfigMapVolumeSource{
+			LocalObjectReference: v1.LocalObjectReference{
+				Name: runner.Name + "-workspace",
+			},
+			DefaultMode: func(i int32) *int32 {
+				return &i
+			}(420),
+		},
+	}
+	if runner.Spec.UseSecretForDockerfile {
+		workspaceVolumeSource = v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: runner.Name + "-workspace",
+				DefaultMode: func(i int32) *int32 {
+					return &i
+				}(420),
+			},
+		}
+	}
+	if runner.Spec.WorkspacePVC != nil {
+		if build {
+			initContainers = append([]v1.Container{r.buildWorkspaceContainer(runner)}, initContainers...)
+		}
+		workspaceVolumeSource = v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: runner.Name + "-workspace",
+			},
+		}
+	}
+
+	// Volumes managed by the controller come first and user-provided ones follow in the given order,
+	// so the result and its hash are stable across reconciliations.
+	volumes := []v1.Volume{
+		{
+			Name:         "workspace",
+			VolumeSource: workspaceVolumeSource,
+		},
+	}
+	if runner.Spec.CACertSecretRef != nil {
+		volumes = append(volumes, v1.Volume{
+			Name: "ca-cert",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: runner.Spec.CACertSecretRef.Name,
+					Items: []v1.KeyToPath{
+						{
+							Key:  runner.Spec.CACertSecretRef.Key,
+							Path: customCACertFileName,
+						},
+					},
+					DefaultMode: func(i int32) *int32 {
+						return &i
+					}(420),
+				},
+			},
+		})
+	}
+	volumes = append(volumes, buildSecretVolumes(runner)...)
+
 	if r.EnableRunnerMetrics {
 		containers = append(containers, r.buildExporterContainer(runner))
 	}
+	// Init containers specified by user run first so that they can prepare the build context
+	initContainers = append(append([]v1.Container{}, runner.Spec.Template.Spec.InitContainers...), initContainers...)
+	// Sidecar containers specified by user follow the ones managed by the controller
+	containers = append(containers, runner.Spec.Template.Spec.Containers...)
+
+	replicas := runner.Spec.Replicas
+	if replicas == nil {
+		replicas = func(i int32) *int32 {
+			return &i
+		}(1)
+	}
+
+	terminationGracePeriodSeconds := garV1.DefaultTerminationGracePeriodSeconds
+	if runner.Spec.TerminationGracePeriodSeconds != nil {
+		terminationGracePeriodSeconds = *runner.Spec.TerminationGracePeriodSeconds
+	}
+
+	strategy := appsV1.DeploymentStrategy{
+		Type: appsV1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsV1.RollingUpdateDeployment{
+			MaxSurge: &intstr.IntOrString{
+				Type:   intstr.String,
+				StrVal: "25%",
+			},
+			MaxUnavailable: &intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: 1,
+			},
+		},
+	}
+	if runner.Spec.RollingUpdateStrategy != nil {
+		strategy.RollingUpdate = runner.Spec.RollingUpdateStrategy
+	}
+	if runner.Spec.DeploymentStrategy != nil && *runner.Spec.DeploymentStrategy == appsV1.RecreateDeploymentStrategyType {
+		// RollingUpdate must be omitted for Recreate, otherwise Kubernetes rejects the deployment
+		strategy = appsV1.DeploymentStrategy{
+			Type: appsV1.RecreateDeploymentStrategyType,
+		}
+	}
+
+	schedulerName := coreV1.DefaultSchedulerName
+	if runner.Spec.SchedulerName != "" {
+		schedulerName = runner.Spec.SchedulerName
+	} else if r.DefaultSchedulerName != "" {
+		schedulerName = r.DefaultSchedulerName
+	}
+
+	dnsPolicy := coreV1.DNSClusterFirst
+	if runner.Spec.Template.Spec.DNSPolicy != nil {
+		dnsPolicy = *runner.Spec.Template.Spec.DNSPolicy
+	}
 
 	appLabel := runner.Name + "-runner"
 	labels := map[string]string{
 		"app": appLabel,
 	}
+	// Runner pods are spread across nodes unless disabled, e.g. in single-node development clusters
+	var affinity *v1.Affinity
+	if !runner.Spec.DisablePodAntiAffinity && !r.DisablePodAntiAffinity {
+		affinity = &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+					{
+						Weight: 100,
+						PodAffinityTerm: v1.PodAffinityTerm{
+							LabelSelector: &metaV1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": appLabel,
+								},
+							},
+							TopologyKey: "kubernetes.io/hostname",
+						},
+					},
+				},
+			},
+		}
+	}
 	for k, v := range runner.Spec.Template.ObjectMeta.Labels {
 		labels[k] = v
 	}
@@ -386,8 +1566,11 @@ func (r *RunnerReconciler) buildDeployment(runner *garV1.Runner) *appsV1.Deploym
 	for k, v := range runner.Spec.Template.ObjectMeta.Annotations {
 		annotations[k] = v
 	}
+	if securityContext := runner.Spec.RunnerContainerSpec.SecurityContext; securityContext != nil && securityContext.AppArmorProfile != nil {
+		annotations[coreV1.AppArmorBetaContainerAnnotationKeyPrefix+"runner"] = buildAppArmorProfileAnnotation(securityContext.AppArmorProfile)
+	}
 	runner.Spec.Template.ObjectMeta.Annotations = annotations
-	return &appsV1.Deployment{
+	deployment := &appsV1.Deployment{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      runner.Name + "-runner",
 			Namespace: runner.Namespace,
@@ -398,193 +1581,789 @@ func (r *RunnerReconciler) buildDeployment(runner *garV1.Runner) *appsV1.Deploym
 					"app": appLabel,
 				},
 			},
-			Replicas: func(i int32) *int32 {
-				return &i
-			}(1),
-			Strategy: appsV1.DeploymentStrategy{
-				Type: appsV1.RollingUpdateDeploymentStrategyType,
-				RollingUpdate: &appsV1.RollingUpdateDeployment{
-					MaxSurge: &intstr.IntOrString{
-						Type:   intstr.String,
-						StrVal: "25%",
-					},
-					MaxUnavailable: &intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: 1,
-					},
-				},
-			},
+			Replicas: replicas,
+			Strategy: strategy,
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: runner.Spec.Template.ObjectMeta,
 				Spec: v1.PodSpec{
-					Affinity: &v1.Affinity{
-						PodAntiAffinity: &v1.PodAntiAffinity{
-							PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
-								{
-									Weight: 100,
-									PodAffinityTerm: v1.PodAffinityTerm{
-										LabelSelector: &metaV1.LabelSelector{
-											MatchLabels: map[string]string{
-												"app": appLabel,
-											},
-										},
-										TopologyKey: "kubernetes.io/hostname",
-									},
-								},
-							},
-						},
-					},
-					InitContainers: []v1.Container{
-						r.buildBuilderContainer(runner),
-					},
-					Containers: containers,
-					Volumes: append([]v1.Volume{
-						{
-							Name: "workspace",
-							VolumeSource: v1.VolumeSource{
-								ConfigMap: &v1.ConfigMapVolumeSource{
-									LocalObjectReference: v1.LocalObjectReference{
-										Name: runner.Name + "-workspace",
-									},
-									DefaultMode: func(i int32) *int32 {
-										return &i
-									}(420),
-								},
-							},
-						},
-					}, runner.Spec.Template.Spec.Volumes...),
-					RestartPolicy: coreV1.RestartPolicyAlways,
-					TerminationGracePeriodSeconds: func(i int64) *int64 {
-						return &i
-					}(30),
-					DNSPolicy: coreV1.DNSClusterFirst,
+					Affinity:                      affinity,
+					InitContainers:                initContainers,
+					Containers:                    containers,
+					Volumes:                       append(volumes, runner.Spec.Template.Spec.Volumes...),
+					RestartPolicy:                 coreV1.RestartPolicyAlways,
+					TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+					DNSPolicy:                     dnsPolicy,
+					DNSConfig:                     runner.Spec.Template.Spec.DNSConfig,
+					HostAliases:                   runner.Spec.Template.Spec.HostAliases,
 					SecurityContext: &coreV1.PodSecurityContext{
+						FSGroup:            runner.Spec.FSGroup,
+						SupplementalGroups: runner.Spec.SupplementalGroups,
 						SeccompProfile: &coreV1.SeccompProfile{
 							Type: coreV1.SeccompProfileTypeRuntimeDefault,
 						},
 					},
-					SchedulerName: coreV1.DefaultSchedulerName,
+					SchedulerName: schedulerName,
 				},
 			},
 		},
 	}
+	deployment.Labels = buildManagedResourceLabels(runner)
+	deploymentAnnotations := map[string]string{
+		specHashAnnotation: hashPodTemplate(&deployment.Spec.Template),
+	}
+	// Removal of managed labels and annotations is detected by the hash, and applied by server-side apply
+	if len(deployment.Labels) > 0 || len(runner.Spec.ManagedResourceAnnotations) > 0 {
+		deploymentAnnotations[metadataHashAnnotation] = hashManagedMetadata(runner)
+	}
+	deployment.Annotations = mergeMetadata(runner.Spec.ManagedResourceAnnotations, deploymentAnnotations)
+	return deployment
+}
+
+// mergeMetadata returns labels or annotations of owned resources where the ones of the controller take precedence over the ones of the runner.
+// It returns nil when both are empty to be compared with the ones returned by the API server.
+func mergeMetadata(managed map[string]string, controlled map[string]string) map[string]string {
+	if len(managed) == 0 && len(controlled) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(managed)+len(controlled))
+	for k, v := range managed {
+		merged[k] = v
+	}
+	for k, v := range controlled {
+		merged[k] = v
+	}
+	return merged
+}
+
+// buildManagedResourceLabels returns labels of owned resources inheriting the ones of the runner,
+// so that owned resources can be discovered by the same selectors as runners, e.g. kubectl get deployment -l team=backend.
+// Managed resource labels take precedence over the ones of the runner, and reserved labels of Kubernetes are not inherited.
+func buildManagedResourceLabels(runner *garV1.Runner) map[string]string {
+	inherited := make(map[string]string, len(runner.Labels))
+	for k, v := range runner.Labels {
+		if !isReservedLabel(k) {
+			inherited[k] = v
+		}
+	}
+	return mergeMetadata(inherited, runner.Spec.ManagedResourceLabels)
+}
+
+// isReservedLabel returns whether the label is prefixed by kubernetes.io or k8s.io including their subdomains
+func isReservedLabel(key string) bool {
+	prefix, _, ok := strings.Cut(key, "/")
+	if !ok {
+		return false
+	}
+	for _, domain := range []string{"kubernetes.io", "k8s.io"} {
+		if prefix == domain || strings.HasSuffix(prefix, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashManagedMetadata(runner *garV1.Runner) string {
+	b, err := json.Marshal([]map[string]string{buildManagedResourceLabels(runner), runner.Spec.ManagedResourceAnnotations})
+	if err != nil {
+		// Maps of strings are always marshalable
+		panic(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+func buildAppArmorProfileAnnotation(profile *garV1.AppArmorProfile) string {
+	switch profile.Type {
+	case garV1.AppArmorProfileTypeLocalhost:
+		if profile.LocalhostProfile == nil {
+			return coreV1.AppArmorBetaProfileNamePrefix
+		}
+		return coreV1.AppArmorBetaProfileNamePrefix + *profile.LocalhostProfile
+	case garV1.AppArmorProfileTypeUnconfined:
+		return coreV1.AppArmorBetaProfileNameUnconfined
+	default:
+		return coreV1.AppArmorBetaProfileRuntimeDefault
+	}
+}
+
+func hashPodTemplate(template *v1.PodTemplateSpec) string {
+	b, err := json.Marshal(template)
+	if err != nil {
+		// PodTemplateSpec is always marshalable
+		panic(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+func (r *RunnerReconciler) buildDockerfile(runner *garV1.Runner) string {
+	uid, gid := buildRunnerUID(runner), buildRunnerGID(runner)
+	var caCertLayer string
+	if runner.Spec.CACertSecretRef != nil {
+		caCertLayer = fmt.Sprintf(`
+COPY %s /usr/local/share/ca-certificates/%s
+RUN (command -v update-ca-certificates && update-ca-certificates) || \
+      (command -v update-ca-trust && cp /usr/local/share/ca-certificates/%s /etc/pki/ca-trust/source/anchors/ && update-ca-trust extract) || \
+      (echo "Unknown CA trust tool" && exit 1)
+`, customCACertFileName, customCACertFileName, customCACertFileName)
+	}
+
+	return fmt.Sprintf(`
+FROM %s
+USER root
+ENV DEBIAN_FRONTEND=noninteractive
+RUN (command -v apt && apt update && apt install -y ca-certificates iputils-ping tar sudo git) || \
+      (command -v apt-get && apt-get update && apt-get install -y --no-install-recommends ca-certificates iputils-ping tar sudo git) || \
+      (command -v dnf && dnf install -y ca-certificates iputils tar sudo git) || \
+      (command -v yum && yum install -y ca-certificates iputils tar sudo git) || \
+      (command -v zypper && zypper install -n ca-certificates iputils tar sudo git-core) || \
+      (echo "Unknown OS version" && exit 1)
+%s
+ADD https://github.com/kaidotdev/github-actions-runner-controller/releases/download/v%s/runner_%s_linux_%s /usr/local/bin/runner
+RUN chmod +x /usr/local/bin/runner
+
+RUN echo 'runner::%d:%d::/home/runner:/bin/sh' >> /etc/passwd
+RUN echo 'runner::%d:' >> /etc/group
+RUN mkdir -p /home/runner && chown -R runner:runner /home/runner
+
+RUN echo "runner:!:0:0:99999:7:::" >> /etc/shadow
+RUN echo "runner ALL=(ALL) NOPASSWD: ALL" | sudo EDITOR='tee -a' visudo
+
+WORKDIR /home/runner
+
+RUN /usr/local/bin/runner --only-install --runner-version %s
+RUN /usr/local/bin/runner --version
+LABEL runner_version="%s"
+
+USER %d
+
+ENTRYPOINT ["/usr/local/bin/runner"]
+`, runner.Spec.Image, caCertLayer, r.BinaryVersion, r.BinaryVersion, r.buildBinaryArch(runner), uid, gid, gid, r.runnerVersionOf(runner), r.runnerVersionOf(runner), uid)
+}
+
+func (r *RunnerReconciler) buildWorkspaceConfigMap(runner *garV1.Runner) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        runner.Name + "-workspace",
+			Namespace:   runner.Namespace,
+			Labels:      buildManagedResourceLabels(runner),
+			Annotations: mergeMetadata(runner.Spec.ManagedResourceAnnotations, nil),
+		},
+		Data: map[string]string{
+			"Dockerfile": r.buildDockerfile(runner),
+		},
+	}
+}
+
+// buildWorkspaceSecret builds the secret storing the Dockerfile instead of the workspace config map,
+// so that the image embedded in it is visible only to those allowed to read secrets
+func (r *RunnerReconciler) buildWorkspaceSecret(runner *garV1.Runner) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        runner.Name + "-workspace",
+			Namespace:   runner.Namespace,
+			Labels:      buildManagedResourceLabels(runner),
+			Annotations: r.buildSecretAnnotations(runner, nil),
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"Dockerfile": []byte(r.buildDockerfile(runner)),
+		},
+	}
+}
+
+func (r *RunnerReconciler) buildWorkspacePVC(runner *garV1.Runner) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        runner.Name + "-workspace",
+			Namespace:   runner.Namespace,
+			Labels:      buildManagedResourceLabels(runner),
+			Annotations: mergeMetadata(runner.Spec.ManagedResourceAnnotations, nil),
+		},
+		Spec: *runner.Spec.WorkspacePVC.DeepCopy(),
+	}
+}
+
+func (r *RunnerReconciler) buildWorkspaceContainer(runner *garV1.Runner) v1.Container {
+	return v1.Container{
+		Name:            "workspace",
+		Image:           r.WorkspaceImage,
+		ImagePullPolicy: v1.PullIfNotPresent,
+		Command: []string{
+			"sh",
+			"-c",
+		},
+		Args: []string{
+			fmt.Sprintf("cat > /workspace/Dockerfile << 'EOF'\n%s\nEOF\n", r.buildDockerfile(runner)),
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      "workspace",
+				MountPath: "/workspace",
+			},
+		},
+		TerminationMessagePath:   coreV1.TerminationMessagePathDefault,
+		TerminationMessagePolicy: coreV1.TerminationMessageReadFile,
+	}
+}
+
+func (r *RunnerReconciler) createTokenSecret(ctx context.Context, runner *garV1.Runner) (_ *v1.Secret, err error) {
+	ctx, span := r.Tracer.Start(ctx, "createTokenSecret")
+	defer func() { endSpan(span, err) }()
+
+	body := struct {
+		Repositories  []string          `json:"repositories"`
+		RepositoryIds []int             `json:"repository_ids"`
+		Permissions   map[string]string `json:"permissions"`
+	}{}
+
+	accessToken := struct {
+		Token     string `json:"token"`
+		ExpiresAt string `json:"expires_at"`
+	}{}
+
+	installationId, err := r.getInstallationId(ctx, runner)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get installation id: %w", err)
+	}
+
+	cacheKey := installationId + ":" + runner.Spec.Repository
+	// Tokens with different permissions are not shared
+	if len(runner.Spec.RequiredPermissions) > 0 {
+		cacheKey += ":" + encodePermissions(runner.Spec.RequiredPermissions)
+	}
+	runnerKey := runner.Namespace + "/" + runner.Name
+	if previous, ok := r.tokenCacheOwners.Load(runnerKey); ok {
+		if owner := previous.(tokenCacheOwner); owner.key != cacheKey || owner.generation != runner.Generation {
+			r.tokenCache.Delete(owner.key)
+		}
+	}
+	r.tokenCacheOwners.Store(runnerKey, tokenCacheOwner{
+		key:        cacheKey,
+		generation: runner.Generation,
+	})
+	if cached, ok := r.tokenCache.Load(cacheKey); ok {
+		if cached := cached.(cachedToken); time.Until(cached.expiresAt) > r.tokenRefreshBuffer() {
+			span.SetAttributes(attribute.Bool("cache_hit", true))
+			return r.buildTokenSecret(runner, cached.token, cached.expiresAt), nil
+		}
+	}
+
+	privateKey, err := r.getPrivateKey(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get private key: %w", err)
+	}
+	jwtToken, err := signJwt(privateKey, r.GitHubAppClientId)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to sign jwt: %w", err)
+	}
+
+	body.Repositories = []string{strings.SplitN(runner.Spec.Repository, "/", 2)[1]}
+	body.Permissions = defaultTokenPermissions
+	if len(runner.Spec.RequiredPermissions) > 0 {
+		body.Permissions = runner.Spec.RequiredPermissions
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to marshal body: %w", err)
+	}
+
+	accessTokenRequest, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://api.github.com/app/installations/%s/access_tokens", installationId), bytes.NewReader(b))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create request: %w", err)
+	}
+
+	accessTokenRequest.Header.Set("Accept", "application/vnd.github+json")
+	accessTokenRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *jwtToken))
+	accessTokenRequest.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if err := r.githubCircuit.allow(time.Now()); err != nil {
+		return nil, err
+	}
+	_, requestSpan := r.Tracer.Start(ctx, "POST /app/installations/{installation_id}/access_tokens", trace.WithSpanKind(trace.SpanKindClient))
+	accessTokenResponse, err := r.httpClient().Do(accessTokenRequest)
+	if err != nil {
+		endSpan(requestSpan, err)
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+		r.recordGitHubAPIResult(false)
+		return nil, xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = accessTokenResponse.Body.Close()
+	}()
+	requestSpan.SetAttributes(attribute.Int("http.status_code", accessTokenResponse.StatusCode))
+	endSpan(requestSpan, nil)
+	// Server errors are regarded as unreachable as well as network errors, while client errors are not
+	if accessTokenResponse.StatusCode >= http.StatusInternalServerError {
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+	} else {
+		r.githubCircuit.success()
+	}
+
+	r.recordGitHubAPIResult(accessTokenResponse.StatusCode == http.StatusCreated)
+	if accessTokenResponse.StatusCode != http.StatusCreated {
+		// The discovered installation is gone when the app is reinstalled, so it is discovered again on the next attempt
+		if accessTokenResponse.StatusCode == http.StatusNotFound {
+			r.installationIds.Delete(runner.Spec.Repository)
+		}
+		return nil, xerrors.Errorf("failed to get access token: %d", accessTokenResponse.StatusCode)
+	}
+
+	if err := json.NewDecoder(accessTokenResponse.Body).Decode(&accessToken); err != nil {
+		return nil, xerrors.Errorf("failed to decode access token: %w", err)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, accessToken.ExpiresAt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse expiry of access token: %w", err)
+	}
+	r.tokenCache.Store(cacheKey, cachedToken{
+		token:     accessToken.Token,
+		expiresAt: expiresAt,
+	})
+
+	return r.buildTokenSecret(runner, accessToken.Token, expiresAt), nil
+}
+
+// encodePermissions encodes permissions in the order of their names
+func encodePermissions(permissions map[string]string) string {
+	names := make([]string, 0, len(permissions))
+	for name := range permissions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	encoded := make([]string, 0, len(names))
+	for _, name := range names {
+		encoded = append(encoded, name+"="+permissions[name])
+	}
+	return strings.Join(encoded, ",")
+}
+
+// tokenRenewalFailed records the failure and returns the result to retry renewal,
+// which is delayed until the circuit breaker closes when GitHub API is unreachable
+func (r *RunnerReconciler) tokenRenewalFailed(runner *garV1.Runner, logger logr.Logger, err error) ctrl.Result {
+	r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonTokenRenewalFailed, "Failed to renew token secret: %v", err)
+	if xerrors.Is(err, ErrCircuitOpen) {
+		logger.Info("skip renewing token secret since GitHub API is unreachable")
+		return ctrl.Result{RequeueAfter: r.circuitBreakerTimeout()}
+	}
+	logger.Error(err, "failed to renew token secret")
+	return ctrl.Result{RequeueAfter: tokenRenewalRetryInterval}
+}
+
+func (r *RunnerReconciler) buildTokenSecret(runner *garV1.Runner, token string, expiresAt time.Time) *v1.Secret {
+	secret := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      tokenSecretName(runner),
+			Namespace: runner.Namespace,
+			Labels:    buildManagedResourceLabels(runner),
+			Annotations: r.buildSecretAnnotations(runner, map[string]string{
+				expiresAtAnnotation: expiresAt.Format(time.RFC3339),
+			}),
+		},
+		// Type is explicit so that security scanners do not regard tokens as untyped secrets
+		Type: v1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"GITHUB_TOKEN": token,
+		},
+	}
+	// Metadata of runners sharing the secret may differ, so only the ones of the controller are set not to fight over them
+	if runner.Spec.SharedTokenSecret {
+		secret.Labels = map[string]string{
+			sharedTokenSecretLabel: "true",
+		}
+		secret.Annotations = mergeMetadata(r.EncryptionAnnotations, map[string]string{
+			expiresAtAnnotation: expiresAt.Format(time.RFC3339),
+		})
+	}
+	return secret
+}
+
+// tokenSecretName returns the name of the token secret of the runner.
+// Shared token secrets are named after the repository and the required permissions, which determine the token
+// since a repository belongs to only one installation of the GitHub App.
+func tokenSecretName(runner *garV1.Runner) string {
+	if !runner.Spec.SharedTokenSecret {
+		return runner.Name
+	}
+	key := runner.Spec.Repository
+	if len(runner.Spec.RequiredPermissions) > 0 {
+		key += ":" + encodePermissions(runner.Spec.RequiredPermissions)
+	}
+	return fmt.Sprintf("%s-%x-token", runner.Namespace, sha256.Sum256([]byte(key)))
+}
+
+// setTokenSecretOwner sets the runner as the controller of its own token secret.
+// Shared token secrets are owned by all runners sharing them instead, so that they are garbage collected after the last one is deleted.
+func (r *RunnerReconciler) setTokenSecretOwner(runner *garV1.Runner, tokenSecret *v1.Secret) error {
+	if runner.Spec.SharedTokenSecret {
+		return controllerutil.SetOwnerReference(runner, tokenSecret, r.Scheme)
+	}
+	return controllerutil.SetControllerReference(runner, tokenSecret, r.Scheme)
+}
+
+// reconcileSharedTokenSecretOwner adds the runner to the owners of the shared token secret created by another runner
+func (r *RunnerReconciler) reconcileSharedTokenSecretOwner(ctx context.Context, runner *garV1.Runner, tokenSecret *v1.Secret) error {
+	if !runner.Spec.SharedTokenSecret {
+		return nil
+	}
+	for _, owner := range tokenSecret.OwnerReferences {
+		if owner.UID == runner.UID {
+			return nil
+		}
+	}
+	if err := controllerutil.SetOwnerReference(runner, tokenSecret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Update(ctx, tokenSecret); err != nil {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to share token secret %q: %v", tokenSecret.Name, err)
+		return err
+	}
+	return nil
+}
+
+// buildSecretAnnotations returns annotations of secrets managed by the controller,
+// where the encryption annotations take precedence over the ones of the runner, and the controlled ones over both
+func (r *RunnerReconciler) buildSecretAnnotations(runner *garV1.Runner, controlled map[string]string) map[string]string {
+	return mergeMetadata(mergeMetadata(runner.Spec.ManagedResourceAnnotations, r.EncryptionAnnotations), controlled)
+}
+
+// applyTokenSecret updates the token secret with the expected one and records the change
+func (r *RunnerReconciler) applyTokenSecret(ctx context.Context, runner *garV1.Runner, tokenSecret *v1.Secret, expectedTokenSecret *v1.Secret, logger logr.Logger) error {
+	r.logDiff(logger, "Secret", redactSecret(expectedTokenSecret), redactSecret(tokenSecret))
+	updated, err := r.updateTokenSecret(ctx, tokenSecret, expectedTokenSecret)
+	if err != nil {
+		r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to update token secret %q: %v", tokenSecret.Name, err)
+		return err
+	}
+	if updated {
+		r.recordChange(runner, logger, EventReasonUpdated, fmt.Sprintf("Updated token secret: %q", tokenSecret.Name), "")
+		logger.V(1).Info("update", "secret", tokenSecret)
+	}
+	return nil
+}
+
+// updateTokenSecret updates the token secret with the expected token unless it already has.
+// Conflicts are retried on the latest version a few times since the secret is only written by the controller,
+// so that a stale cache does not fail the whole reconciliation.
+func (r *RunnerReconciler) updateTokenSecret(ctx context.Context, tokenSecret *v1.Secret, expectedTokenSecret *v1.Secret) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		// The API server converts StringData into Data, so the cached token is compared with Data
+		if string(tokenSecret.Data["GITHUB_TOKEN"]) == expectedTokenSecret.StringData["GITHUB_TOKEN"] &&
+			reflect.DeepEqual(tokenSecret.Labels, expectedTokenSecret.Labels) &&
+			reflect.DeepEqual(tokenSecret.Annotations, expectedTokenSecret.Annotations) {
+			return false, nil
+		}
+		tokenSecret.Labels = expectedTokenSecret.Labels
+		tokenSecret.Annotations = expectedTokenSecret.Annotations
+		tokenSecret.Data = expectedTokenSecret.Data
+		tokenSecret.StringData = expectedTokenSecret.StringData
+
+		err := r.Update(ctx, tokenSecret)
+		if err == nil {
+			return true, nil
+		}
+		if !apierrors.IsConflict(err) || attempt >= tokenSecretUpdateAttempts {
+			return false, err
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(tokenSecret), tokenSecret); err != nil {
+			return false, err
+		}
+	}
+}
+
+// conflictBackoff returns jittered exponential backoff increasing with consecutive conflicts of the runner
+func (r *RunnerReconciler) conflictBackoff(req ctrl.Request) time.Duration {
+	maxBackoff := r.ConflictBackoffMax
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	var failures int
+	if v, ok := r.conflictFailures.Load(req.NamespacedName); ok {
+		failures = v.(int)
+	}
+	r.conflictFailures.Store(req.NamespacedName, failures+1)
+
+	backoff := maxBackoff
+	if failures < 32 && conflictBackoffBase<<failures < maxBackoff {
+		backoff = conflictBackoffBase << failures
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (r *RunnerReconciler) runnerVersion() string {
+	if v, ok := r.upgradedRunnerVersion.Load().(string); ok {
+		return v
+	}
+	return r.RunnerVersion
+}
+
+func (r *RunnerReconciler) setRunnerVersion(version string) {
+	r.upgradedRunnerVersion.Store(version)
+}
+
+// runnerVersionOf returns the version annotated to the runner by RunnerVersionPoller if any,
+// so that runners are not rolled back to RunnerVersion until the first poll after the controller restarts
+func (r *RunnerReconciler) runnerVersionOf(runner *garV1.Runner) string {
+	if v := runner.Annotations[runnerVersionAnnotation]; r.autoUpgrade && v != "" {
+		return v
+	}
+	return r.runnerVersion()
 }
 
-func (r *RunnerReconciler) buildWorkspaceConfigMap(runner *garV1.Runner) *v1.ConfigMap {
-	return &v1.ConfigMap{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      runner.Name + "-workspace",
-			Namespace: runner.Namespace,
-		},
-		Data: map[string]string{
-			"Dockerfile": fmt.Sprintf(`
-FROM %s
-USER root
-ENV DEBIAN_FRONTEND=noninteractive
-RUN (command -v apt && apt update && apt install -y ca-certificates iputils-ping tar sudo git) || \
-      (command -v apt-get && apt-get update && apt-get install -y --no-install-recommends ca-certificates iputils-ping tar sudo git) || \
-      (command -v dnf && dnf install -y ca-certificates iputils tar sudo git) || \
-      (command -v yum && yum install -y ca-certificates iputils tar sudo git) || \
-      (command -v zypper && zypper install -n ca-certificates iputils tar sudo git-core) || \
-      (echo "Unknown OS version" && exit 1)
-
-ADD https://github.com/kaidotdev/github-actions-runner-controller/releases/download/v%s/runner_%s_linux_amd64 /usr/local/bin/runner
-RUN chmod +x /usr/local/bin/runner
-
-RUN echo 'runner::60000:60000::/home/runner:/bin/sh' >> /etc/passwd
-RUN echo 'runner::60000:' >> /etc/group
-RUN mkdir -p /home/runner && chown -R runner:runner /home/runner
+func (r *RunnerReconciler) tokenRefreshBuffer() time.Duration {
+	if r.TokenRefreshBuffer <= 0 {
+		return time.Minute
+	}
+	return r.TokenRefreshBuffer
+}
 
-RUN echo "runner:!:0:0:99999:7:::" >> /etc/shadow
-RUN echo "runner ALL=(ALL) NOPASSWD: ALL" | sudo EDITOR='tee -a' visudo
+func (r *RunnerReconciler) circuitBreakerThreshold() int {
+	if r.CircuitBreakerThreshold <= 0 {
+		return 5
+	}
+	return r.CircuitBreakerThreshold
+}
 
-WORKDIR /home/runner
+func (r *RunnerReconciler) httpClient() *http.Client {
+	if r.HTTPClient == nil {
+		return defaultHTTPClient
+	}
+	return r.HTTPClient
+}
 
-RUN /usr/local/bin/runner --only-install --runner-version %s
+func (r *RunnerReconciler) circuitBreakerTimeout() time.Duration {
+	if r.CircuitBreakerTimeout <= 0 {
+		return 5 * time.Minute
+	}
+	return r.CircuitBreakerTimeout
+}
 
-USER 60000
+func (r *RunnerReconciler) getInstallationId(ctx context.Context, runner *garV1.Runner) (string, error) {
+	secretRef := runner.Spec.AppInstallationSecretRef
+	if secretRef == nil {
+		if r.DiscoverInstallationId {
+			return r.discoverInstallationId(ctx, runner.Spec.Repository)
+		}
+		return r.GitHubAppInstallationId, nil
+	}
 
-ENTRYPOINT ["/usr/local/bin/runner"]
-`, runner.Spec.Image, r.BinaryVersion, r.BinaryVersion, r.RunnerVersion),
-		},
+	var secret v1.Secret
+	if err := r.Get(ctx, client.ObjectKey{
+		Name:      secretRef.Name,
+		Namespace: runner.Namespace,
+	}, &secret); err != nil {
+		return "", xerrors.Errorf("failed to get installation id secret %q: %w", secretRef.Name, err)
+	}
+	installationId := strings.TrimSpace(string(secret.Data[secretRef.Key]))
+	if installationId == "" {
+		return "", xerrors.Errorf("installation id secret %q has no key %q", secretRef.Name, secretRef.Key)
 	}
+	return installationId, nil
 }
 
-func (r *RunnerReconciler) createTokenSecret(runner *garV1.Runner) (*v1.Secret, error) {
-	body := struct {
-		Repositories  []string          `json:"repositories"`
-		RepositoryIds []int             `json:"repository_ids"`
-		Permissions   map[string]string `json:"permissions"`
-	}{}
+// discoverInstallationId gets the installation of GitHub App on the repository,
+// which is cached per repository since it changes only when the app is reinstalled.
+func (r *RunnerReconciler) discoverInstallationId(ctx context.Context, repository string) (_ string, err error) {
+	if cached, ok := r.installationIds.Load(repository); ok {
+		return cached.(string), nil
+	}
 
-	accessToken := struct {
-		Token     string `json:"token"`
-		ExpiresAt string `json:"expires_at"`
-	}{}
+	ctx, span := r.Tracer.Start(ctx, "discoverInstallationId")
+	defer func() { endSpan(span, err) }()
 
-	err, jwtToken := signJwt(r.GitHubAppPrivateKey, r.GitHubAppClientId)
+	privateKey, err := r.getPrivateKey(ctx)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to sign jwt: %w", err)
+		return "", xerrors.Errorf("failed to get private key: %w", err)
+	}
+	jwtToken, err := signJwt(privateKey, r.GitHubAppClientId)
+	if err != nil {
+		return "", xerrors.Errorf("failed to sign jwt: %w", err)
 	}
 
-	body.Repositories = []string{strings.SplitN(runner.Spec.Repository, "/", 2)[1]}
-	body.Permissions = map[string]string{
-		"actions":        "read",
-		"administration": "write",
-		"metadata":       "read",
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/installation", repository), nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
 	}
-	b, err := json.Marshal(body)
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *jwtToken))
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if err := r.githubCircuit.allow(time.Now()); err != nil {
+		return "", err
+	}
+	_, requestSpan := r.Tracer.Start(ctx, "GET /repos/{owner}/{repo}/installation", trace.WithSpanKind(trace.SpanKindClient))
+	response, err := r.httpClient().Do(request)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to marshal body: %w", err)
+		endSpan(requestSpan, err)
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+		r.recordGitHubAPIResult(false)
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	requestSpan.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+	endSpan(requestSpan, nil)
+	if response.StatusCode >= http.StatusInternalServerError {
+		r.githubCircuit.failure(time.Now(), r.circuitBreakerThreshold(), r.circuitBreakerTimeout())
+	} else {
+		r.githubCircuit.success()
+	}
+
+	r.recordGitHubAPIResult(response.StatusCode == http.StatusOK)
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return "", xerrors.Errorf("GitHub App is not installed on repository %q", repository)
+	case response.StatusCode != http.StatusOK:
+		return "", xerrors.Errorf("failed to get installation: %d", response.StatusCode)
+	}
+
+	installation := struct {
+		Id int64 `json:"id"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&installation); err != nil {
+		return "", xerrors.Errorf("failed to decode installation: %w", err)
+	}
+	if installation.Id == 0 {
+		return "", xerrors.Errorf("installation of repository %q has no id", repository)
+	}
+	installationId := strconv.FormatInt(installation.Id, 10)
+	r.installationIds.Store(repository, installationId)
+	return installationId, nil
+}
+
+// validateGitHubAppCredentials checks the GitHub App configured at the controller on startup
+// so that misconfiguration is not found long after at reconciliation.
+// Unreachable GitHub API is only logged since it may be temporary.
+func (r *RunnerReconciler) validateGitHubAppCredentials(ctx context.Context) error {
+	if r.GitHubAppClientId == "" && !r.privateKeyConfigured() {
+		return nil
+	}
+	if !r.gitHubAppConfigured() {
+		return xerrors.New("both client id and private key must be specified")
 	}
 
-	accessTokenRequest, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/app/installations/%s/access_tokens", r.GitHubAppInstallationId), bytes.NewReader(b))
+	privateKey, err := r.getPrivateKey(ctx)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to create request: %w", err)
+		return xerrors.Errorf("failed to get private key: %w", err)
+	}
+	jwtToken, err := signJwt(privateKey, r.GitHubAppClientId)
+	if err != nil {
+		return xerrors.Errorf("failed to sign jwt: %w", err)
 	}
 
-	accessTokenRequest.Header.Set("Accept", "application/vnd.github+json")
-	accessTokenRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *jwtToken))
-	accessTokenRequest.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	accessTokenResponse, err := http.DefaultClient.Do(accessTokenRequest)
+	request, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/app", nil)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to do request: %w", err)
+		return xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *jwtToken))
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	response, err := r.httpClient().Do(request)
+	if err != nil {
+		r.Log.Error(err, "failed to verify GitHub App credentials")
+		return nil
 	}
 	defer func() {
-		_ = accessTokenResponse.Body.Close()
+		_ = response.Body.Close()
 	}()
 
-	if accessTokenResponse.StatusCode != http.StatusCreated {
-		return nil, xerrors.Errorf("failed to get access token: %d", accessTokenResponse.StatusCode)
+	r.recordGitHubAPIResult(response.StatusCode == http.StatusOK)
+	switch {
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusNotFound:
+		return xerrors.Errorf("GitHub App is rejected by GitHub API: %d", response.StatusCode)
+	case response.StatusCode != http.StatusOK:
+		r.Log.Info("failed to verify GitHub App credentials", "status", response.StatusCode)
 	}
+	return nil
+}
 
-	if err := json.NewDecoder(accessTokenResponse.Body).Decode(&accessToken); err != nil {
-		return nil, xerrors.Errorf("failed to decode access token: %w", err)
+// warnUnrenewedTokenSecrets sweeps token secrets on startup when GitHub App is not configured at the controller,
+// since the ones issued before it was removed are no longer renewed and expire soon.
+// They are only recorded rather than deleted because runner pods still refer to them until migrated to another token,
+// so that operators can clean them up after the migration.
+func (r *RunnerReconciler) warnUnrenewedTokenSecrets(ctx context.Context) error {
+	if r.gitHubAppConfigured() {
+		return nil
 	}
 
-	return &v1.Secret{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      runner.Name,
-			Namespace: runner.Namespace,
-			Annotations: map[string]string{
-				expiresAtAnnotation: accessToken.ExpiresAt,
-			},
-		},
-		StringData: map[string]string{
-			"GITHUB_TOKEN": accessToken.Token,
-		},
-	}, nil
+	var secrets v1.SecretList
+	if err := r.List(ctx, &secrets); err != nil {
+		return xerrors.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range secrets.Items {
+		if !isTokenSecret(&secret) {
+			continue
+		}
+		logger := r.Log.WithValues("secret", client.ObjectKeyFromObject(&secret))
+
+		// Token secrets are named after the runner, which is used when the owner reference is missing
+		runnerName := secret.Name
+		if owner := metaV1.GetControllerOf(&secret); owner != nil {
+			runnerName = owner.Name
+		}
+		var runner garV1.Runner
+		if err := r.Get(ctx, client.ObjectKey{Name: runnerName, Namespace: secret.Namespace}, &runner); apierrors.IsNotFound(err) {
+			// Orphaned secrets are deleted by OrphanedSecretCollector
+			continue
+		} else if err != nil {
+			return xerrors.Errorf("failed to get runner %q: %w", runnerName, err)
+		}
+		if !r.selectsRunner(&runner) {
+			continue
+		}
+
+		logger.Info("token secret is no longer renewed since GitHub App is not configured", "runner", runner.Name, "expiresAt", secret.Annotations[expiresAtAnnotation])
+		r.Recorder.Eventf(&runner, v1.EventTypeWarning, EventReasonTokenSecretNotRenewed, "Token secret %q expiring at %s is no longer renewed since GitHub App is not configured at the controller, and should be deleted after migrating to another token", secret.Name, secret.Annotations[expiresAtAnnotation])
+	}
+	return nil
+}
+
+// usesInstallationToken returns whether the runner registers with the token secret issued by the GitHub App of the controller
+func (r *RunnerReconciler) usesInstallationToken(runner *garV1.Runner) bool {
+	if runner.Spec.TokenSecretKeyRef != nil || (runner.Spec.PersonalAccessTokenRef != nil && runner.Spec.AppSecretRef == nil) {
+		return false
+	}
+	return r.gitHubAppConfigured() && (r.GitHubAppInstallationId != "" || r.DiscoverInstallationId || runner.Spec.AppInstallationSecretRef != nil)
+}
+
+func (r *RunnerReconciler) gitHubAppConfigured() bool {
+	return r.GitHubAppClientId != "" && r.privateKeyConfigured()
+}
+
+func (r *RunnerReconciler) privateKeyConfigured() bool {
+	return r.GitHubAppPrivateKey != "" || r.PrivateKeyProvider != nil || r.PrivateKeySecretRef.Name != ""
+}
+
+func (r *RunnerReconciler) getPrivateKey(ctx context.Context) (string, error) {
+	if r.PrivateKeySecretRef.Name != "" {
+		reader := r.apiReader
+		if reader == nil {
+			reader = r.Client
+		}
+		var secret v1.Secret
+		if err := reader.Get(ctx, r.PrivateKeySecretRef, &secret); err != nil {
+			return "", xerrors.Errorf("failed to get private key secret %q: %w", r.PrivateKeySecretRef, err)
+		}
+		privateKey := string(secret.Data[r.privateKeySecretKey()])
+		if privateKey == "" {
+			return "", xerrors.Errorf("private key secret %q has no key %q", r.PrivateKeySecretRef, r.privateKeySecretKey())
+		}
+		return privateKey, nil
+	}
+	if r.PrivateKeyProvider != nil {
+		return r.PrivateKeyProvider.GetPrivateKey(ctx)
+	}
+	return r.GitHubAppPrivateKey, nil
 }
 
-func signJwt(privateKey string, clientId string) (error, *string) {
+func signJwt(privateKey string, clientId string) (*string, error) {
 	block, _ := pem.Decode([]byte(privateKey))
 	if block == nil {
-		return xerrors.New("failed to decode private key"), nil
+		return nil, xerrors.New("failed to decode private key")
 	}
 
 	rsaPrivateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
-		return xerrors.Errorf("failed to parse private key: %w", err), nil
+		return nil, xerrors.Errorf("failed to parse private key: %w", err)
 	}
 
 	now := time.Now()
@@ -597,12 +2376,15 @@ func signJwt(privateKey string, clientId string) (error, *string) {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	jwtToken, err := token.SignedString(rsaPrivateKey)
 	if err != nil {
-		return xerrors.Errorf("failed to sign token: %w", err), nil
+		return nil, xerrors.Errorf("failed to sign token: %w", err)
 	}
-	return nil, &jwtToken
+	return &jwtToken, nil
 }
 
-func (r *RunnerReconciler) cleanupOwnedResources(ctx context.Context, runner *garV1.Runner) error {
+func (r *RunnerReconciler) cleanupOwnedResources(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (err error) {
+	ctx, span := r.Tracer.Start(ctx, "cleanupOwnedResources")
+	defer func() { endSpan(span, err) }()
+
 	var configMaps v1.ConfigMapList
 	if err := r.List(
 		ctx,
@@ -616,14 +2398,67 @@ func (r *RunnerReconciler) cleanupOwnedResources(ctx context.Context, runner *ga
 	for _, configMap := range configMaps.Items {
 		configMap := configMap
 
-		if configMap.Name == runner.Name+"-workspace" {
+		if configMap.Name == runner.Name+"-workspace" && runner.Spec.WorkspacePVC == nil && !runner.Spec.UseSecretForDockerfile {
 			continue
 		}
 
 		if err := r.Client.Delete(ctx, &configMap); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDeleteFailed, "Failed to delete config map %q: %v", configMap.Name, err)
+			return err
+		}
+		r.recordChange(runner, logger, EventReasonDeleted, fmt.Sprintf("Deleted config map: %q", configMap.Name), "")
+	}
+
+	var secrets v1.SecretList
+	if err := r.List(
+		ctx,
+		&secrets,
+		client.InNamespace(runner.Namespace),
+		client.MatchingFields{ownerKey: runner.Name},
+	); err != nil {
+		return err
+	}
+
+	for _, secret := range secrets.Items {
+		secret := secret
+
+		// The token secret of the runner is replaced by the shared one
+		if secret.Name == runner.Name && !runner.Spec.SharedTokenSecret {
+			continue
+		}
+		if secret.Name == runner.Name+"-workspace" && runner.Spec.WorkspacePVC == nil && runner.Spec.UseSecretForDockerfile {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, &secret); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDeleteFailed, "Failed to delete secret %q: %v", secret.Name, err)
+			return err
+		}
+		r.recordChange(runner, logger, EventReasonDeleted, fmt.Sprintf("Deleted secret: %q", secret.Name), "")
+	}
+
+	var persistentVolumeClaims v1.PersistentVolumeClaimList
+	if err := r.List(
+		ctx,
+		&persistentVolumeClaims,
+		client.InNamespace(runner.Namespace),
+		client.MatchingFields{ownerKey: runner.Name},
+	); err != nil {
+		return err
+	}
+
+	for _, persistentVolumeClaim := range persistentVolumeClaims.Items {
+		persistentVolumeClaim := persistentVolumeClaim
+
+		if persistentVolumeClaim.Name == runner.Name+"-workspace" && runner.Spec.WorkspacePVC != nil {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, &persistentVolumeClaim); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDeleteFailed, "Failed to delete persistent volume claim %q: %v", persistentVolumeClaim.Name, err)
 			return err
 		}
-		r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulDeleted", "Deleted config map: %q", configMap.Name)
+		r.recordChange(runner, logger, EventReasonDeleted, fmt.Sprintf("Deleted persistent volume claim: %q", persistentVolumeClaim.Name), "")
 	}
 
 	var deployments appsV1.DeploymentList
@@ -644,16 +2479,57 @@ func (r *RunnerReconciler) cleanupOwnedResources(ctx context.Context, runner *ga
 		}
 
 		if err := r.Client.Delete(ctx, &deployment); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonDeleteFailed, "Failed to delete deployment %q: %v", deployment.Name, err)
 			return err
 		}
-		r.Recorder.Eventf(runner, coreV1.EventTypeNormal, "SuccessfulDeleted", "Deleted deployment: %q", deployment.Name)
+		r.recordChange(runner, logger, EventReasonDeleted, fmt.Sprintf("Deleted deployment: %q", deployment.Name), "")
 	}
 
 	return nil
 }
 
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetupWithManager registers the controller, which runs only on the leader when leader election is enabled,
+// so that multiple replicas of the controller do not race to create resources of the same runner.
 func (r *RunnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	ctx := context.Background()
+
+	if r.ReconcileTotal == nil {
+		r.ReconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "github_actions_runner_controller_reconcile_total",
+			Help: "Total number of reconciliations per runner",
+		}, []string{"runner", "namespace", "result"})
+	}
+	if r.ReconcileDuration == nil {
+		r.ReconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "github_actions_runner_controller_reconcile_duration_seconds",
+			Help:    "Duration of reconciliations",
+			Buckets: prometheus.DefBuckets,
+		})
+	}
+	if r.TokenSecondsUntilExpiry == nil {
+		r.TokenSecondsUntilExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "github_actions_runner_controller_token_seconds_until_expiry",
+			Help: "Seconds until the token secret of runner expires",
+		}, []string{"runner", "namespace"})
+	}
+	if err := metrics.Registry.Register(r.ReconcileTotal); err != nil {
+		return err
+	}
+	if err := metrics.Registry.Register(r.ReconcileDuration); err != nil {
+		return err
+	}
+	if err := metrics.Registry.Register(r.TokenSecondsUntilExpiry); err != nil {
+		return err
+	}
+	// Field indexers are built on the cache of the manager, so they only index objects in the watched namespace when it is restricted
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1.ConfigMap{}, ownerKey, func(rawObj client.Object) []string {
 		configMap := rawObj.(*v1.ConfigMap)
 		owner := metaV1.GetControllerOf(configMap)
@@ -669,6 +2545,36 @@ func (r *RunnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1.Secret{}, ownerKey, func(rawObj client.Object) []string {
+		secret := rawObj.(*v1.Secret)
+		owner := metaV1.GetControllerOf(secret)
+		if owner == nil {
+			return nil
+		}
+		if owner.Kind != "Runner" {
+			return nil
+		}
+
+		return []string{owner.Name}
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1.PersistentVolumeClaim{}, ownerKey, func(rawObj client.Object) []string {
+		persistentVolumeClaim := rawObj.(*v1.PersistentVolumeClaim)
+		owner := metaV1.GetControllerOf(persistentVolumeClaim)
+		if owner == nil {
+			return nil
+		}
+		if owner.Kind != "Runner" {
+			return nil
+		}
+
+		return []string{owner.Name}
+	}); err != nil {
+		return err
+	}
+
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &appsV1.Deployment{}, ownerKey, func(rawObj client.Object) []string {
 		deployment := rawObj.(*appsV1.Deployment)
 		owner := metaV1.GetControllerOf(deployment)
@@ -684,11 +2590,206 @@ func (r *RunnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	maxConcurrentReconciles := r.MaxConcurrentReconciles
+	if maxConcurrentReconciles < 1 {
+		maxConcurrentReconciles = 1
+	}
+
+	// Runners are also reconciled when their labels are changed, which are inherited by owned resources and matched by the runner selector
+	runnerPredicate := predicate.Or(RunnerSpecChangedPredicate{}, predicate.LabelChangedPredicate{}, annotationsChangedPredicate(runnerVersionAnnotation, pausedAnnotation, queuedJobAnnotation, drainOnDeleteAnnotation), deletionRequestedPredicate())
+	if r.RunnerSelector != nil {
+		selectorPredicate, err := predicate.LabelSelectorPredicate(*r.RunnerSelector)
+		if err != nil {
+			return xerrors.Errorf("invalid runner selector: %w", err)
+		}
+		runnerPredicate = predicate.And(selectorPredicate, runnerPredicate)
+	}
+
+	r.startedAt = time.Now()
+	r.apiReader = mgr.GetAPIReader()
+	if err := r.validateGitHubAppCredentials(ctx); err != nil {
+		return xerrors.Errorf("invalid GitHub App credentials: %w", err)
+	}
+
+	if r.DryRun {
+		r.Client = client.NewDryRunClient(r.Client)
+	}
+	if r.TraceAPILatency {
+		r.Client = newLatencyLoggingClient(r.Client, r.Log.WithName("api"))
+	}
+	// The sweep waits for the cache to be started by the manager, and runs only on the leader as well as the reconciler
+	if err := mgr.Add(manager.RunnableFunc(r.warnUnrenewedTokenSecrets)); err != nil {
+		return err
+	}
+
+	if err := (&OrphanedSecretCollector{
+		Client: r.Client,
+		Log:    r.Log.WithName("OrphanedSecretCollector"),
+		Scheme: r.Scheme,
+	}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&garV1.Runner{}).
-		Owns(&v1.ConfigMap{}).
-		Owns(&appsV1.Deployment{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		// Runners are also reconciled when RunnerVersionPoller upgrades the runner version, when they are paused or resumed,
+		// and when WorkflowJobWebhookReceiver receives queued jobs
+		For(&garV1.Runner{}, builder.WithPredicates(runnerPredicate)).
+		// Config maps have no generation, so external modifications of the workspace are detected by their content
+		Owns(&v1.ConfigMap{}, builder.WithPredicates(configMapChangedPredicate())).
+		// So do secrets, whose modifications of tokens and Dockerfiles are repaired as well
+		Owns(&v1.Secret{}, builder.WithPredicates(secretChangedPredicate())).
+		Owns(&v1.PersistentVolumeClaim{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&appsV1.Deployment{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, deploymentReadinessChangedPredicate()))).
+		Owns(&batchV1.Job{}, builder.WithPredicates(jobFinishedPredicate())).
+		Watches(&v1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.mapConfigMapToRunners)).
+		Watches(&garV1.RunnerClass{}, handler.EnqueueRequestsFromMapFunc(r.mapRunnerClassToRunners), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.mapPrivateKeySecretToRunners)).
+		Watches(&batchV1.Job{}, handler.EnqueueRequestsFromMapFunc(r.mapBuildJobToRunners), builder.WithPredicates(buildJobPredicate())).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+			RateLimiter:             r.RateLimiter,
+		}).
 		Complete(r)
 }
+
+// RunnerSpecChangedPredicate passes updates of runners changing their spec, which is compared by hash rather than generation
+// so that updates bumping generation without changing spec, such as ones of metadata, do not cause spurious reconciliations.
+type RunnerSpecChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (RunnerSpecChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldRunner, ok := e.ObjectOld.(*garV1.Runner)
+	if !ok {
+		return false
+	}
+	newRunner, ok := e.ObjectNew.(*garV1.Runner)
+	if !ok {
+		return false
+	}
+	return hashRunnerSpec(&oldRunner.Spec) != hashRunnerSpec(&newRunner.Spec)
+}
+
+func hashRunnerSpec(spec *garV1.RunnerSpec) string {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		// RunnerSpec is always marshalable
+		panic(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// deletionRequestedPredicate passes deletion of objects with finalizers, which does not always change generation
+func deletionRequestedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return e.ObjectOld.GetDeletionTimestamp().IsZero() && !e.ObjectNew.GetDeletionTimestamp().IsZero()
+		},
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// configMapChangedPredicate passes updates of config maps changing their data or metadata managed by the controller
+func configMapChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldConfigMap, ok := e.ObjectOld.(*v1.ConfigMap)
+			if !ok {
+				return false
+			}
+			newConfigMap, ok := e.ObjectNew.(*v1.ConfigMap)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(oldConfigMap.Data, newConfigMap.Data) ||
+				!reflect.DeepEqual(oldConfigMap.BinaryData, newConfigMap.BinaryData) ||
+				!reflect.DeepEqual(oldConfigMap.Labels, newConfigMap.Labels) ||
+				!reflect.DeepEqual(oldConfigMap.Annotations, newConfigMap.Annotations)
+		},
+	}
+}
+
+// secretChangedPredicate passes updates of secrets changing their data or metadata managed by the controller
+func secretChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret, ok := e.ObjectOld.(*v1.Secret)
+			if !ok {
+				return false
+			}
+			newSecret, ok := e.ObjectNew.(*v1.Secret)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data) ||
+				!reflect.DeepEqual(oldSecret.StringData, newSecret.StringData) ||
+				!reflect.DeepEqual(oldSecret.Labels, newSecret.Labels) ||
+				!reflect.DeepEqual(oldSecret.Annotations, newSecret.Annotations)
+		},
+	}
+}
+
+// deploymentReadinessChangedPredicate passes status changes of deployments reflected in the DeploymentReady condition
+func deploymentReadinessChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDeployment, ok := e.ObjectOld.(*appsV1.Deployment)
+			if !ok {
+				return false
+			}
+			newDeployment, ok := e.ObjectNew.(*appsV1.Deployment)
+			if !ok {
+				return false
+			}
+			return oldDeployment.Status.ObservedGeneration != newDeployment.Status.ObservedGeneration ||
+				oldDeployment.Status.UpdatedReplicas != newDeployment.Status.UpdatedReplicas ||
+				oldDeployment.Status.ReadyReplicas != newDeployment.Status.ReadyReplicas
+		},
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// annotationsChangedPredicate passes only updates changing any of the annotations
+func annotationsChangedPredicate(keys ...string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			for _, key := range keys {
+				if e.ObjectOld.GetAnnotations()[key] != e.ObjectNew.GetAnnotations()[key] {
+					return true
+				}
+			}
+			return false
+		},
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
@@ -0,0 +1,4398 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func indexOwner(rawObj client.Object) []string {
+	owner := metaV1.GetControllerOf(rawObj)
+	if owner == nil {
+		return nil
+	}
+	if owner.Kind != "Runner" {
+		return nil
+	}
+
+	return []string{owner.Name}
+}
+
+// applyAsUpdate emulates server-side apply, which is not supported by the fake client, with create or update
+func applyAsUpdate(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+
+	if (&client.PatchOptions{}).ApplyOptions(opts).DryRun != nil {
+		return nil
+	}
+
+	current := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	if deployment, ok := obj.(*appsV1.Deployment); ok && deployment.Spec.Replicas == nil {
+		deployment.Spec.Replicas = current.(*appsV1.Deployment).Spec.Replicas
+	}
+	obj.SetResourceVersion(current.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+func newTestRunnerReconciler(t *testing.T, objects ...client.Object) *RunnerReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := garV1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return &RunnerReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&garV1.Runner{}).
+			WithIndex(&v1.ConfigMap{}, ownerKey, indexOwner).
+			WithIndex(&v1.Secret{}, ownerKey, indexOwner).
+			WithIndex(&v1.PersistentVolumeClaim{}, ownerKey, indexOwner).
+			WithIndex(&appsV1.Deployment{}, ownerKey, indexOwner).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: applyAsUpdate,
+			}).
+			Build(),
+		Log:              logr.Discard(),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		PushRegistryHost: "registry.example.com",
+		PullRegistryHost: "127.0.0.1:5000",
+		ExporterImage:    "exporter",
+		KanikoImage:      "kaniko",
+		WorkspaceImage:   "busybox",
+		BinaryVersion:    "0.0.0",
+		RunnerVersion:    "0.0.0",
+		Tracer:           trace.NewNoopTracerProvider().Tracer(""),
+		ReconcileTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_reconcile_total",
+		}, []string{"runner", "namespace", "result"}),
+		ReconcileDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "test_reconcile_duration_seconds",
+		}),
+		TokenSecondsUntilExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "test_token_seconds_until_expiry",
+		}, []string{"runner", "namespace"}),
+	}
+}
+
+func TestRunnerReconcilerReconcileSecretKeyRefEnv(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "npm",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"NPM_TOKEN": []byte("before"),
+		},
+	}
+	secretKeyRefEnv := v1.EnvVar{
+		Name: "NPM_TOKEN",
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "npm",
+				},
+				Key: "NPM_TOKEN",
+			},
+		},
+	}
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+			RunnerContainerSpec: garV1.RunnerContainerSpec{
+				Env: []v1.EnvVar{
+					secretKeyRefEnv,
+				},
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner, secret)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	deploymentKey := client.ObjectKey{
+		Name:      runner.Name + "-runner",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var created appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &created); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, container := range created.Spec.Template.Spec.Containers {
+		if container.Name != "runner" {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == secretKeyRefEnv.Name && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil &&
+				*env.ValueFrom.SecretKeyRef == *secretKeyRefEnv.ValueFrom.SecretKeyRef {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("runner container has no env %q referring to secret", secretKeyRefEnv.Name)
+	}
+
+	secret.Data["NPM_TOKEN"] = []byte("after")
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var reconciled appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.ResourceVersion != created.ResourceVersion {
+		t.Errorf("deployment was updated by a change of referenced secret: resourceVersion %s -> %s", created.ResourceVersion, reconciled.ResourceVersion)
+	}
+}
+
+func TestRunnerReconcilerReconcileStatus(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var reconciled garV1.Runner
+	if err := r.Get(ctx, req.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if want := r.buildRepositoryName(runner); reconciled.Status.BuiltImageRepository != want {
+		t.Errorf("status.builtImageRepository = %q, want %q", reconciled.Status.BuiltImageRepository, want)
+	}
+	if reconciled.Spec.TerminationGracePeriodSeconds != nil {
+		t.Errorf("defaults applied in memory must not be persisted by status update")
+	}
+	if !meta.IsStatusConditionFalse(reconciled.Status.Conditions, garV1.RunnerConditionDeploymentReady) {
+		t.Errorf("status.conditions = %v, want %s to be False before replicas are ready", reconciled.Status.Conditions, garV1.RunnerConditionDeploymentReady)
+	}
+	if reconciled.Status.TokenExpiresAt != nil {
+		t.Errorf("status.tokenExpiresAt = %v, want nil for a token not issued by GitHub App", reconciled.Status.TokenExpiresAt)
+	}
+}
+
+func TestBuildDeploymentReadyCondition(t *testing.T) {
+	type in struct {
+		deployment *appsV1.Deployment
+	}
+
+	type want struct {
+		status metaV1.ConditionStatus
+		reason string
+	}
+
+	replicas := int32(2)
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"missing",
+			in{
+				&appsV1.Deployment{},
+			},
+			want{
+				metaV1.ConditionFalse,
+				"RolloutInProgress",
+			},
+		},
+		{
+			"not observed",
+			in{
+				&appsV1.Deployment{
+					ObjectMeta: metaV1.ObjectMeta{
+						Generation: 2,
+					},
+					Status: appsV1.DeploymentStatus{
+						ObservedGeneration: 1,
+						UpdatedReplicas:    1,
+						ReadyReplicas:      1,
+					},
+				},
+			},
+			want{
+				metaV1.ConditionFalse,
+				"RolloutInProgress",
+			},
+		},
+		{
+			"not ready",
+			in{
+				&appsV1.Deployment{
+					Spec: appsV1.DeploymentSpec{
+						Replicas: &replicas,
+					},
+					Status: appsV1.DeploymentStatus{
+						UpdatedReplicas: 2,
+						ReadyReplicas:   1,
+					},
+				},
+			},
+			want{
+				metaV1.ConditionFalse,
+				"ReplicasNotReady",
+			},
+		},
+		{
+			"ready",
+			in{
+				&appsV1.Deployment{
+					Spec: appsV1.DeploymentSpec{
+						Replicas: &replicas,
+					},
+					Status: appsV1.DeploymentStatus{
+						UpdatedReplicas: 2,
+						ReadyReplicas:   2,
+					},
+				},
+			},
+			want{
+				metaV1.ConditionTrue,
+				"ReplicasReady",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := buildDeploymentReadyCondition(tt.in.deployment, 1)
+			if got.Status != tt.want.status || got.Reason != tt.want.reason {
+				t.Errorf("buildDeploymentReadyCondition() = %s/%s, want %s/%s", got.Status, got.Reason, tt.want.status, tt.want.reason)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileVolumes(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	deploymentKey := client.ObjectKey{
+		Name:      runner.Name + "-runner",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var created appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &created); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
+		t.Fatal(err)
+	}
+	runner.Spec.Template.Spec.Volumes = append(runner.Spec.Template.Spec.Volumes, v1.Volume{
+		Name: "cache",
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{},
+		},
+	})
+	if err := r.Update(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var updated appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.ResourceVersion == created.ResourceVersion {
+		t.Fatal("deployment was not updated by adding a volume")
+	}
+	var names []string
+	for _, volume := range updated.Spec.Template.Spec.Volumes {
+		names = append(names, volume.Name)
+	}
+	if want := []string{"workspace", "cache"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("volumes = %v, want %v", names, want)
+	}
+
+	// Reconciling again must not cause a spurious update since volumes are appended in a stable order
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var reconciled appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("deployment was updated spuriously: resourceVersion %s -> %s", updated.ResourceVersion, reconciled.ResourceVersion)
+	}
+}
+
+func TestRunnerReconcilerReconcileWorkspaceSecret(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:                  "ubuntu:22.04",
+			Repository:             "kaidotdev/github-actions-runner-controller",
+			SkipWarmup:             true,
+			UseSecretForDockerfile: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	workspaceKey := client.ObjectKey{
+		Name:      runner.Name + "-workspace",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var secret v1.Secret
+	if err := r.Get(ctx, workspaceKey, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(secret.Data["Dockerfile"]), r.buildDockerfile(runner); got != want {
+		t.Errorf("Dockerfile = %q, want %q", got, want)
+	}
+	if err := r.Get(ctx, workspaceKey, &v1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workspace config map must not be created: %v", err)
+	}
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if source := deployment.Spec.Template.Spec.Volumes[0].VolumeSource; source.Secret == nil || source.Secret.SecretName != workspaceKey.Name {
+		t.Errorf("workspace volume = %v, want secret %q", source, workspaceKey.Name)
+	}
+
+	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
+		t.Fatal(err)
+	}
+	runner.Spec.UseSecretForDockerfile = false
+	if err := r.Update(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Get(ctx, workspaceKey, &v1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workspace secret must be deleted when switched back to config map: %v", err)
+	}
+	if err := r.Get(ctx, workspaceKey, &v1.ConfigMap{}); err != nil {
+		t.Errorf("workspace config map must be created when switched back: %v", err)
+	}
+}
+
+func TestRunnerReconcilerReconcileWorkspacePVC(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			WorkspacePVC: &v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+				},
+			},
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	workspaceKey := client.ObjectKey{
+		Name:      runner.Name + "-workspace",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var pvc v1.PersistentVolumeClaim
+	if err := r.Get(ctx, workspaceKey, &pvc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pvc.Spec, *runner.Spec.WorkspacePVC) {
+		t.Errorf("persistent volume claim spec = %v, want %v", pvc.Spec, *runner.Spec.WorkspacePVC)
+	}
+	if owner := metaV1.GetControllerOf(&pvc); owner == nil || owner.Name != runner.Name {
+		t.Errorf("controller of persistent volume claim = %v, want runner %q", owner, runner.Name)
+	}
+	if err := r.Get(ctx, workspaceKey, &v1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workspace config map must not be created: %v", err)
+	}
+
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if source := deployment.Spec.Template.Spec.Volumes[0].VolumeSource; source.PersistentVolumeClaim == nil || source.PersistentVolumeClaim.ClaimName != workspaceKey.Name {
+		t.Errorf("workspace volume = %v, want persistent volume claim %q", source, workspaceKey.Name)
+	}
+	// The Dockerfile is written into the claim by the workspace container before the builder container reads it
+	initContainers := deployment.Spec.Template.Spec.InitContainers
+	if len(initContainers) < 2 || initContainers[0].Name != "workspace" || initContainers[1].Name != "kaniko" {
+		t.Fatalf("init containers = %v, want workspace followed by kaniko", initContainers)
+	}
+	if args := strings.Join(initContainers[0].Args, " "); !strings.Contains(args, r.buildDockerfile(runner)) {
+		t.Errorf("args of workspace container = %q, want to contain the Dockerfile", args)
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentWorkspacePVCWithoutBuild(t *testing.T) {
+	// Runners of pre-built images have no Dockerfile to write into the claim
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:         "ubuntu:22.04",
+			Repository:    "kaidotdev/github-actions-runner-controller",
+			PreBuiltImage: "registry.example.com/runner:latest",
+			WorkspacePVC:  &v1.PersistentVolumeClaimSpec{},
+		},
+	}
+	r := newTestRunnerReconciler(t)
+	deployment := r.buildDeployment(runner)
+
+	for _, container := range deployment.Spec.Template.Spec.InitContainers {
+		if container.Name == "workspace" || container.Name == "kaniko" {
+			t.Errorf("init container %q must not be added without build", container.Name)
+		}
+	}
+	if source := deployment.Spec.Template.Spec.Volumes[0].VolumeSource; source.PersistentVolumeClaim == nil {
+		t.Errorf("workspace volume = %v, want persistent volume claim", source)
+	}
+}
+
+func TestRunnerReconcilerReconcileRepairsDeployment(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	deploymentKey := client.ObjectKey{
+		Name:      runner.Name + "-runner",
+		Namespace: runner.Namespace,
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var modified appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &modified); err != nil {
+		t.Fatal(err)
+	}
+	modified.Spec.Strategy = appsV1.DeploymentStrategy{
+		Type: appsV1.RecreateDeploymentStrategyType,
+	}
+	delete(modified.Spec.Template.Labels, "app")
+	if err := r.Update(ctx, &modified); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var repaired appsV1.Deployment
+	if err := r.Get(ctx, deploymentKey, &repaired); err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Spec.Strategy.Type != appsV1.RollingUpdateDeploymentStrategyType {
+		t.Errorf("strategy = %q, want %q", repaired.Spec.Strategy.Type, appsV1.RollingUpdateDeploymentStrategyType)
+	}
+	if got := repaired.Spec.Template.Labels["app"]; got != runner.Name+"-runner" {
+		t.Errorf("app label = %q, want %q", got, runner.Name+"-runner")
+	}
+}
+
+func TestRunnerReconcilerReconcileKeepsRollingUpdateStrategy(t *testing.T) {
+	// Runners scaled by HPA leave replicas unset, and are not defaulted to surge by reconciliation
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	rollingUpdate := deployment.Spec.Strategy.RollingUpdate
+	if rollingUpdate == nil {
+		t.Fatal("rolling update strategy must be set")
+	}
+	if got := rollingUpdate.MaxSurge.String(); got != "25%" {
+		t.Errorf("maxSurge = %q, want %q", got, "25%")
+	}
+	if got := rollingUpdate.MaxUnavailable.String(); got != "1" {
+		t.Errorf("maxUnavailable = %q, want %q", got, "1")
+	}
+	var stored garV1.Runner
+	if err := r.Get(ctx, client.ObjectKeyFromObject(runner), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Spec.RollingUpdateStrategy != nil {
+		t.Errorf("rollingUpdateStrategy = %v, want nil", stored.Spec.RollingUpdateStrategy)
+	}
+}
+
+func TestRunnerReconcilerCreateTokenSecretCache(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:       "example",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	r := newTestRunnerReconciler(t)
+	r.GitHubAppInstallationId = "1"
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	r.tokenCache.Store("1:"+runner.Spec.Repository, cachedToken{
+		token:     "cached",
+		expiresAt: expiresAt,
+	})
+
+	// GitHub App private key is not configured, so anything other than a cache hit fails
+	secret, err := r.createTokenSecret(ctx, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := secret.StringData["GITHUB_TOKEN"]; got != "cached" {
+		t.Errorf("token = %q, want %q", got, "cached")
+	}
+	if got := secret.Annotations[expiresAtAnnotation]; got != expiresAt.Format(time.RFC3339) {
+		t.Errorf("expiresAt = %q, want %q", got, expiresAt.Format(time.RFC3339))
+	}
+
+	runner.Generation = 2
+	if _, err := r.createTokenSecret(ctx, runner); err == nil {
+		t.Error("cached token must be invalidated when runner spec changes")
+	}
+}
+
+func TestRunnerReconcilerConflictBackoff(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+	r.ConflictBackoffMax = 8 * time.Second
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "example",
+			Namespace: "default",
+		},
+	}
+
+	for i, want := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		8 * time.Second,
+	} {
+		got := r.conflictBackoff(req)
+		if got < want/2 || got > want {
+			t.Errorf("conflictBackoff() at %d = %s, want in [%s, %s]", i, got, want/2, want)
+		}
+	}
+
+	// Successful reconciliation resets the backoff
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.conflictBackoff(req); got > time.Second {
+		t.Errorf("conflictBackoff() after success = %s, want at most %s", got, time.Second)
+	}
+}
+
+func TestRunnerReconcilerReconcileConflictObservedAsSuccess(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*garV1.Runner); ok {
+				return apierrors.NewConflict(garV1.GroupVersion.WithResource("runners").GroupResource(), key.Name, errors.New("the object has been modified"))
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	})
+	exporter := tracetest.NewInMemoryExporter()
+	r.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("")
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter <= 0 {
+		t.Errorf("requeueAfter = %s, want positive", result.RequeueAfter)
+	}
+
+	// Conflicts retried with backoff are observed as successes by the metrics and the tracing
+	if got := testutil.ToFloat64(r.ReconcileTotal.WithLabelValues(runner.Name, runner.Namespace, "error")); got != 0 {
+		t.Errorf("reconcile total of error = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(r.ReconcileTotal.WithLabelValues(runner.Name, runner.Namespace, "success")); got != 1 {
+		t.Errorf("reconcile total of success = %v, want 1", got)
+	}
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "Reconcile" && span.Status.Code == codes.Error {
+			t.Errorf("status of span = %v, want not error", span.Status)
+		}
+	}
+}
+
+func TestRunnerReconcilerBuildDockerfileBinaryArch(t *testing.T) {
+	type in struct {
+		controllerArch string
+		runnerArch     string
+	}
+
+	type want struct {
+		binary string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				"",
+				"",
+			},
+			want{
+				"runner_0.0.0_linux_amd64",
+			},
+		},
+		{
+			"controller",
+			in{
+				"arm64",
+				"",
+			},
+			want{
+				"runner_0.0.0_linux_arm64",
+			},
+		},
+		{
+			"runner overrides controller",
+			in{
+				"arm64",
+				"amd64",
+			},
+			want{
+				"runner_0.0.0_linux_amd64",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.BinaryArch = tt.in.controllerArch
+			runner := &garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image:      "ubuntu:22.04",
+					BinaryArch: tt.in.runnerArch,
+				},
+			}
+
+			if got := r.buildDockerfile(runner); !strings.Contains(got, "/"+tt.want.binary+" ") {
+				t.Errorf("buildDockerfile() does not download %q:\n%s", tt.want.binary, got)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileDryRun(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	r.DryRun = true
+	r.Client = client.NewDryRunClient(r.Client)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+	ctx := context.Background()
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var deployments appsV1.DeploymentList
+	if err := r.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 0 {
+		t.Errorf("deployments = %d, want 0 in dry-run mode", len(deployments.Items))
+	}
+	var proposed bool
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, EventReasonDryRunProposedChange) {
+			proposed = true
+		}
+	}
+	if !proposed {
+		t.Errorf("no %s event is recorded", EventReasonDryRunProposedChange)
+	}
+}
+
+func TestRunnerReconcilerBuildExporterContainerCredentials(t *testing.T) {
+	type in struct {
+		tokenSecretKeyRef *v1.SecretKeySelector
+		appSecretRef      *v1.SecretEnvSource
+	}
+
+	type want struct {
+		args    []string
+		envFrom int
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"token",
+			in{
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+					Key: "TOKEN",
+				},
+				nil,
+			},
+			want{
+				[]string{
+					"--token=$(TOKEN)",
+				},
+				0,
+			},
+		},
+		{
+			"GitHub App",
+			in{
+				nil,
+				&v1.SecretEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+				},
+			},
+			want{
+				[]string{
+					"--github-app-id=$(github_app_id)",
+					"--github-app-installation-id=$(github_app_installation_id)",
+					"--github-app-private-key=$(github_app_private_key)",
+				},
+				1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			c := r.buildExporterContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Repository:        "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: tt.in.tokenSecretKeyRef,
+					AppSecretRef:      tt.in.appSecretRef,
+				},
+			})
+
+			if got := c.Args[len(c.Args)-len(tt.want.args):]; !reflect.DeepEqual(got, tt.want.args) {
+				t.Errorf("args = %v, want suffix %v", c.Args, tt.want.args)
+			}
+			if got := len(c.EnvFrom); got != tt.want.envFrom {
+				t.Errorf("envFrom = %d, want %d", got, tt.want.envFrom)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentWithoutDefaults(t *testing.T) {
+	// Runners admitted without the mutating webhook fall back to the defaults without mutating their spec
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	r := newTestRunnerReconciler(t)
+	deployment := r.buildDeployment(runner)
+
+	if got := deployment.Spec.Template.Spec.TerminationGracePeriodSeconds; got == nil || *got != garV1.DefaultTerminationGracePeriodSeconds {
+		t.Errorf("terminationGracePeriodSeconds = %v, want %d", got, garV1.DefaultTerminationGracePeriodSeconds)
+	}
+	var found bool
+	for _, container := range deployment.Spec.Template.Spec.InitContainers {
+		if container.Name != "kaniko" {
+			continue
+		}
+		found = true
+		if got := container.Resources.Limits.Memory(); !got.Equal(resource.MustParse(garV1.DefaultBuilderMemoryLimit)) {
+			t.Errorf("memory limit of builder = %s, want %s", got, garV1.DefaultBuilderMemoryLimit)
+		}
+	}
+	if !found {
+		t.Fatal("builder container is not found")
+	}
+	if runner.Spec.TerminationGracePeriodSeconds != nil || runner.Spec.BuilderContainerSpec.Resources.Limits != nil {
+		t.Errorf("spec = %v, want to be left unset", runner.Spec)
+	}
+}
+
+func TestBuildResources(t *testing.T) {
+	defaults := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse("4Gi"),
+		},
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("1"),
+			v1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+	}
+
+	type in struct {
+		resources v1.ResourceRequirements
+	}
+
+	type want struct {
+		resources v1.ResourceRequirements
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"empty",
+			in{
+				v1.ResourceRequirements{},
+			},
+			want{
+				defaults,
+			},
+		},
+		{
+			"only requests",
+			in{
+				v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("2"),
+					},
+				},
+			},
+			want{
+				v1.ResourceRequirements{
+					Limits: defaults.Limits,
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("2"),
+					},
+				},
+			},
+		},
+		{
+			"both",
+			in{
+				v1.ResourceRequirements{
+					Limits: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("8Gi"),
+					},
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("2"),
+					},
+				},
+			},
+			want{
+				v1.ResourceRequirements{
+					Limits: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("8Gi"),
+					},
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("2"),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := buildResources(tt.in.resources, defaults)
+			if !reflect.DeepEqual(got, tt.want.resources) {
+				t.Errorf("buildResources() = %v, want %v", got, tt.want.resources)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildDockerfileRunnerVersion(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+	r.setRunnerVersion("2.322.0")
+
+	got := r.buildDockerfile(&garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+		},
+	})
+	for _, want := range []string{
+		"RUN /usr/local/bin/runner --only-install --runner-version 2.322.0\n",
+		"LABEL runner_version=\"2.322.0\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("buildDockerfile() does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerContainerProcesses(t *testing.T) {
+	type in struct {
+		runnerProcesses int
+	}
+
+	type want struct {
+		supervised bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				0,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"single",
+			in{
+				1,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"multiple",
+			in{
+				3,
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			c := r.buildRunnerContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Repository:      "kaidotdev/github-actions-runner-controller",
+					RunnerProcesses: tt.in.runnerProcesses,
+				},
+			})
+
+			if got := c.Command != nil; got != tt.want.supervised {
+				t.Errorf("supervised = %v, want %v", got, tt.want.supervised)
+			}
+			var hostname bool
+			for _, arg := range c.Args {
+				if arg == "--hostname=$(HOSTNAME)" {
+					hostname = true
+				}
+			}
+			if hostname == tt.want.supervised {
+				t.Errorf("args = %v, hostname of pod must be passed only without supervisor", c.Args)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerValidateGitHubAppCredentials(t *testing.T) {
+	type in struct {
+		clientId   string
+		privateKey string
+	}
+
+	type want struct {
+		err bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"not configured",
+			in{
+				"",
+				"",
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"missing private key",
+			in{
+				"Iv1.0123456789abcdef",
+				"",
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"malformed private key",
+			in{
+				"Iv1.0123456789abcdef",
+				"malformed",
+			},
+			want{
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.GitHubAppClientId = tt.in.clientId
+			r.GitHubAppPrivateKey = tt.in.privateKey
+
+			if err := r.validateGitHubAppCredentials(context.Background()); (err != nil) != tt.want.err {
+				t.Errorf("validateGitHubAppCredentials() = %v, want error %v", err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerWarnUnrenewedTokenSecrets(t *testing.T) {
+	type in struct {
+		clientId    string
+		annotations map[string]string
+	}
+
+	type want struct {
+		warned bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"GitHub App removed",
+			in{
+				"",
+				map[string]string{
+					expiresAtAnnotation: "2006-01-02T15:04:05Z",
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"GitHub App configured",
+			in{
+				"Iv1.0123456789abcdef",
+				map[string]string{
+					expiresAtAnnotation: "2006-01-02T15:04:05Z",
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"not token secret",
+			in{
+				"",
+				nil,
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			secret := &v1.Secret{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:        runner.Name,
+					Namespace:   runner.Namespace,
+					Annotations: tt.in.annotations,
+				},
+			}
+			r := newTestRunnerReconciler(t, runner, secret)
+			recorder := record.NewFakeRecorder(100)
+			r.Recorder = recorder
+			r.GitHubAppClientId = tt.in.clientId
+			r.GitHubAppPrivateKey = "dummy"
+
+			if err := r.warnUnrenewedTokenSecrets(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var warned bool
+			for len(recorder.Events) > 0 {
+				if event := <-recorder.Events; strings.HasPrefix(event, "Warning "+EventReasonTokenSecretNotRenewed) {
+					warned = true
+				}
+			}
+			if warned != tt.want.warned {
+				t.Errorf("%s event recorded = %v, want %v", EventReasonTokenSecretNotRenewed, warned, tt.want.warned)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerContainerPodMetadata(t *testing.T) {
+	type in struct {
+		injectPodMetadata bool
+	}
+
+	type want struct {
+		env map[string]string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"disabled",
+			in{
+				false,
+			},
+			want{
+				map[string]string{},
+			},
+		},
+		{
+			"enabled",
+			in{
+				true,
+			},
+			want{
+				map[string]string{
+					"NODE_NAME": "spec.nodeName",
+					"NAMESPACE": "metadata.namespace",
+					"POD_IP":    "status.podIP",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			c := r.buildRunnerContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Repository:        "kaidotdev/github-actions-runner-controller",
+					InjectPodMetadata: tt.in.injectPodMetadata,
+				},
+			})
+
+			got := map[string]string{}
+			for _, env := range c.Env {
+				if _, ok := map[string]struct{}{"NODE_NAME": {}, "NAMESPACE": {}, "POD_IP": {}}[env.Name]; ok {
+					got[env.Name] = env.ValueFrom.FieldRef.FieldPath
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want.env) {
+				t.Errorf("env = %v, want %v", got, tt.want.env)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileInvalidDeployment(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The API server rejects the update by validation
+	var applied bool
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if _, ok := obj.(*appsV1.Deployment); !ok {
+				return applyAsUpdate(ctx, c, obj, patch, opts...)
+			}
+			if (&client.PatchOptions{}).ApplyOptions(opts).DryRun != nil {
+				return apierrors.NewInvalid(appsV1.SchemeGroupVersion.WithKind("Deployment").GroupKind(), obj.GetName(), field.ErrorList{
+					field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable"),
+				})
+			}
+			applied = true
+			return applyAsUpdate(ctx, c, obj, patch, opts...)
+		},
+	})
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+
+	var updated garV1.Runner
+	if err := r.Get(ctx, req.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Spec.Image = "ubuntu:24.04"
+	if err := r.Update(ctx, &updated); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("invalid deployment must not fail reconciliation: %v", err)
+	}
+	if result.RequeueAfter != invalidDeploymentRequeueInterval {
+		t.Errorf("requeueAfter = %s, want %s", result.RequeueAfter, invalidDeploymentRequeueInterval)
+	}
+	if applied {
+		t.Error("deployment must not be applied after the dry-run is rejected")
+	}
+
+	var recorded bool
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.HasPrefix(event, "Warning "+EventReasonInvalidDeployment) {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Errorf("%s event must be recorded", EventReasonInvalidDeployment)
+	}
+}
+
+func TestRunnerReconcilerReconcilePersonalAccessToken(t *testing.T) {
+	personalAccessTokenRef := &garV1.PersonalAccessTokenRef{
+		SecretRef: v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{
+				Name: "pat",
+			},
+			Key: "TOKEN",
+		},
+		Scopes: []string{
+			"repo",
+		},
+	}
+
+	type in struct {
+		secret            *v1.Secret
+		tokenSecretKeyRef *v1.SecretKeySelector
+	}
+
+	type want struct {
+		err   bool
+		event string
+		token *v1.SecretKeySelector
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"missing secret",
+			in{
+				nil,
+				nil,
+			},
+			want{
+				true,
+				EventReasonInvalidPersonalAccessToken,
+				nil,
+			},
+		},
+		{
+			"empty key",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "pat",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"OTHER": []byte("ghp_example"),
+					},
+				},
+				nil,
+			},
+			want{
+				true,
+				EventReasonInvalidPersonalAccessToken,
+				nil,
+			},
+		},
+		{
+			"fallback to personal access token",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "pat",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"TOKEN": []byte("ghp_example"),
+					},
+				},
+				nil,
+			},
+			want{
+				false,
+				"",
+				&personalAccessTokenRef.SecretRef,
+			},
+		},
+		{
+			"tokenSecretKeyRef takes precedence",
+			in{
+				&v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "pat",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"TOKEN": []byte("ghp_example"),
+					},
+				},
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+					Key: "TOKEN",
+				},
+			},
+			want{
+				false,
+				"",
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+					Key: "TOKEN",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:                  "ubuntu:22.04",
+					Repository:             "kaidotdev/github-actions-runner-controller",
+					SkipWarmup:             true,
+					PersonalAccessTokenRef: personalAccessTokenRef.DeepCopy(),
+					TokenSecretKeyRef:      tt.in.tokenSecretKeyRef,
+				},
+			}
+			objects := []client.Object{runner}
+			if tt.in.secret != nil {
+				objects = append(objects, tt.in.secret)
+			}
+			r := newTestRunnerReconciler(t, objects...)
+			recorder := record.NewFakeRecorder(100)
+			r.Recorder = recorder
+			ctx := context.Background()
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)})
+			if (err != nil) != tt.want.err {
+				t.Fatalf("Reconcile() error = %v, want error %v", err, tt.want.err)
+			}
+			if tt.want.event != "" {
+				var found bool
+				for len(recorder.Events) > 0 {
+					if event := <-recorder.Events; strings.HasPrefix(event, "Warning "+tt.want.event) {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("event %s is not recorded", tt.want.event)
+				}
+			}
+			if tt.want.token == nil {
+				return
+			}
+
+			var deployment appsV1.Deployment
+			if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+				t.Fatal(err)
+			}
+			var token *v1.SecretKeySelector
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				for _, e := range container.Env {
+					if container.Name == "runner" && e.Name == "TOKEN" && e.ValueFrom != nil {
+						token = e.ValueFrom.SecretKeyRef
+					}
+				}
+			}
+			if !reflect.DeepEqual(token, tt.want.token) {
+				t.Errorf("TOKEN = %v, want %v", token, tt.want.token)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcilePaused(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pausedAnnotation: "true",
+			},
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var deployments appsV1.DeploymentList
+	if err := r.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 0 {
+		t.Errorf("deployments = %d, want 0 while paused", len(deployments.Items))
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning "+EventReasonPaused) {
+		t.Errorf("event = %q, want %s", event, EventReasonPaused)
+	}
+
+	delete(runner.Annotations, pausedAnnotation)
+	if err := r.Update(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 1 {
+		t.Errorf("deployments = %d, want 1 after resumed", len(deployments.Items))
+	}
+}
+
+func TestRunnerReconcilerReconcileAllowedBaseImages(t *testing.T) {
+	type in struct {
+		allowedBaseImages []string
+		image             string
+	}
+
+	type want struct {
+		deployments int
+		notAllowed  bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"without allow-list",
+			in{
+				nil,
+				"ubuntu:22.04",
+			},
+			want{
+				1,
+				false,
+			},
+		},
+		{
+			"allowed",
+			in{
+				[]string{"docker.io/*", "internal-registry.example.com/*"},
+				"internal-registry.example.com/ubuntu:22.04",
+			},
+			want{
+				1,
+				false,
+			},
+		},
+		{
+			"not allowed",
+			in{
+				[]string{"internal-registry.example.com/*"},
+				"ubuntu:22.04",
+			},
+			want{
+				0,
+				true,
+			},
+		},
+		{
+			"nested path not matched by single star",
+			in{
+				[]string{"internal-registry.example.com/*"},
+				"internal-registry.example.com/team/ubuntu:22.04",
+			},
+			want{
+				0,
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:      tt.in.image,
+					Repository: "kaidotdev/github-actions-runner-controller",
+					SkipWarmup: true,
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			r := newTestRunnerReconciler(t, runner)
+			r.AllowedBaseImages = tt.in.allowedBaseImages
+			recorder := record.NewFakeRecorder(100)
+			r.Recorder = recorder
+			ctx := context.Background()
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      runner.Name,
+					Namespace: runner.Namespace,
+				},
+			}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+
+			var deployments appsV1.DeploymentList
+			if err := r.List(ctx, &deployments); err != nil {
+				t.Fatal(err)
+			}
+			if len(deployments.Items) != tt.want.deployments {
+				t.Errorf("deployments = %d, want %d", len(deployments.Items), tt.want.deployments)
+			}
+
+			var got garV1.Runner
+			if err := r.Get(ctx, req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if notAllowed := meta.IsStatusConditionTrue(got.Status.Conditions, garV1.RunnerConditionImageNotAllowed); notAllowed != tt.want.notAllowed {
+				t.Errorf("%s = %v, want %v", garV1.RunnerConditionImageNotAllowed, notAllowed, tt.want.notAllowed)
+			}
+
+			var recorded bool
+			for len(recorder.Events) > 0 {
+				if event := <-recorder.Events; strings.Contains(event, EventReasonImageNotAllowed) {
+					recorded = true
+				}
+			}
+			if recorded != tt.want.notAllowed {
+				t.Errorf("%s event recorded = %v, want %v", EventReasonImageNotAllowed, recorded, tt.want.notAllowed)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileRunnerSelector(t *testing.T) {
+	type in struct {
+		labels map[string]string
+	}
+
+	type want struct {
+		deployments int
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"selected",
+			in{
+				map[string]string{
+					"team": "platform",
+				},
+			},
+			want{
+				1,
+			},
+		},
+		{
+			"not selected",
+			in{
+				map[string]string{
+					"team": "backend",
+				},
+			},
+			want{
+				0,
+			},
+		},
+		{
+			"without labels",
+			in{
+				nil,
+			},
+			want{
+				0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+					Labels:    tt.in.labels,
+				},
+				Spec: garV1.RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					SkipWarmup: true,
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			r := newTestRunnerReconciler(t, runner)
+			r.RunnerSelector = &metaV1.LabelSelector{
+				MatchLabels: map[string]string{
+					"team": "platform",
+				},
+			}
+			ctx := context.Background()
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      runner.Name,
+					Namespace: runner.Namespace,
+				},
+			}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+
+			var deployments appsV1.DeploymentList
+			if err := r.List(ctx, &deployments); err != nil {
+				t.Fatal(err)
+			}
+			if len(deployments.Items) != tt.want.deployments {
+				t.Errorf("deployments = %d, want %d", len(deployments.Items), tt.want.deployments)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerUID(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+	defaultRunner := &garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+		},
+	}
+	runner := &garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image:     "ubuntu:22.04",
+			RunnerUID: func(i int64) *int64 { return &i }(1001),
+			RunnerGID: func(i int64) *int64 { return &i }(1002),
+		},
+	}
+
+	dockerfile := r.buildDockerfile(runner)
+	for _, want := range []string{
+		"RUN echo 'runner::1001:1002::/home/runner:/bin/sh' >> /etc/passwd\n",
+		"RUN echo 'runner::1002:' >> /etc/group\n",
+		"USER 1001\n",
+	} {
+		if !strings.Contains(dockerfile, want) {
+			t.Errorf("buildDockerfile() does not contain %q:\n%s", want, dockerfile)
+		}
+	}
+
+	c := r.buildRunnerContainer(runner)
+	if got := *c.SecurityContext.RunAsUser; got != 1001 {
+		t.Errorf("runAsUser = %d, want %d", got, 1001)
+	}
+	if got := *c.SecurityContext.RunAsGroup; got != 1002 {
+		t.Errorf("runAsGroup = %d, want %d", got, 1002)
+	}
+
+	if r.buildRepositoryName(runner) == r.buildRepositoryName(defaultRunner) {
+		t.Errorf("buildRepositoryName() must differ from the one of the default UID and GID")
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentDNS(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+
+	deployment := r.buildDeployment(&garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+		},
+	})
+	if got := deployment.Spec.Template.Spec.DNSPolicy; got != v1.DNSClusterFirst {
+		t.Errorf("dnsPolicy = %q, want %q by default", got, v1.DNSClusterFirst)
+	}
+
+	runner := &garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+		},
+	}
+	dnsPolicy := v1.DNSNone
+	dnsConfig := &v1.PodDNSConfig{
+		Nameservers: []string{"1.1.1.1"},
+		Searches:    []string{"example.com"},
+	}
+	runner.Spec.Template.Spec.DNSPolicy = &dnsPolicy
+	runner.Spec.Template.Spec.DNSConfig = dnsConfig
+	deployment = r.buildDeployment(runner)
+	if got := deployment.Spec.Template.Spec.DNSPolicy; got != v1.DNSNone {
+		t.Errorf("dnsPolicy = %q, want %q", got, v1.DNSNone)
+	}
+	if got := deployment.Spec.Template.Spec.DNSConfig; !reflect.DeepEqual(got, dnsConfig) {
+		t.Errorf("dnsConfig = %v, want %v", got, dnsConfig)
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentCSIVolumes(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+
+	readOnly := true
+	csiVolume := v1.Volume{
+		Name: "secrets-store",
+		VolumeSource: v1.VolumeSource{
+			CSI: &v1.CSIVolumeSource{
+				Driver:   "secrets-store.csi.k8s.io",
+				ReadOnly: &readOnly,
+				VolumeAttributes: map[string]string{
+					"secretProviderClass": "vault",
+				},
+			},
+		},
+	}
+	volumeMount := v1.VolumeMount{
+		Name:      "secrets-store",
+		MountPath: "/mnt/secrets-store",
+		ReadOnly:  true,
+	}
+	runner := &garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+			RunnerContainerSpec: garV1.RunnerContainerSpec{
+				VolumeMounts: []v1.VolumeMount{volumeMount},
+			},
+		},
+	}
+	runner.Spec.Template.Spec.Volumes = []v1.Volume{csiVolume}
+
+	deployment := r.buildDeployment(runner)
+
+	volumes := deployment.Spec.Template.Spec.Volumes
+	if len(volumes) != 2 || volumes[0].Name != "workspace" {
+		t.Fatalf("volumes = %v, want workspace followed by %s", volumes, csiVolume.Name)
+	}
+	if !reflect.DeepEqual(volumes[1], csiVolume) {
+		t.Errorf("volumes[1] = %v, want %v", volumes[1], csiVolume)
+	}
+
+	var runnerContainer *v1.Container
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "runner" {
+			runnerContainer = &deployment.Spec.Template.Spec.Containers[i]
+		}
+	}
+	if runnerContainer == nil {
+		t.Fatal("runner container not found")
+	}
+	if want := []v1.VolumeMount{volumeMount}; !reflect.DeepEqual(runnerContainer.VolumeMounts, want) {
+		t.Errorf("volumeMounts = %v, want %v", runnerContainer.VolumeMounts, want)
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentSecretVolumeMounts(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+
+	items := []v1.KeyToPath{
+		{
+			Key:  "config",
+			Path: "config",
+		},
+	}
+	runner := &garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+			SecretVolumeMounts: []garV1.SecretVolumeMount{
+				{
+					SecretName: "kubeconfig",
+					MountPath:  "/home/runner/.kube",
+					Items:      items,
+				},
+				{
+					SecretName: "npmrc",
+					MountPath:  "/home/runner/.npm",
+				},
+			},
+		},
+	}
+
+	deployment := r.buildDeployment(runner)
+
+	volumes := deployment.Spec.Template.Spec.Volumes
+	if len(volumes) != 3 || volumes[0].Name != "workspace" {
+		t.Fatalf("volumes = %v, want workspace followed by secret volumes", volumes)
+	}
+	for i, want := range []struct {
+		secretName string
+		items      []v1.KeyToPath
+	}{
+		{"kubeconfig", items},
+		{"npmrc", nil},
+	} {
+		volume := volumes[i+1]
+		if volume.Name != fmt.Sprintf("secret-volume-%d", i) || volume.Secret == nil {
+			t.Fatalf("volumes[%d] = %v, want secret volume", i+1, volume)
+		}
+		if volume.Secret.SecretName != want.secretName || !reflect.DeepEqual(volume.Secret.Items, want.items) {
+			t.Errorf("volumes[%d].secret = %v, want secret %q with items %v", i+1, volume.Secret, want.secretName, want.items)
+		}
+	}
+
+	var runnerContainer *v1.Container
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "runner" {
+			runnerContainer = &deployment.Spec.Template.Spec.Containers[i]
+		}
+	}
+	if runnerContainer == nil {
+		t.Fatal("runner container not found")
+	}
+	want := []v1.VolumeMount{
+		{
+			Name:      "secret-volume-0",
+			MountPath: "/home/runner/.kube",
+			ReadOnly:  true,
+		},
+		{
+			Name:      "secret-volume-1",
+			MountPath: "/home/runner/.npm",
+			ReadOnly:  true,
+		},
+	}
+	if !reflect.DeepEqual(runnerContainer.VolumeMounts, want) {
+		t.Errorf("volumeMounts = %v, want %v", runnerContainer.VolumeMounts, want)
+	}
+}
+
+func TestRunnerReconcilerReconcileProjectedVolume(t *testing.T) {
+	expirationSeconds := int64(3600)
+	projectedVolume := v1.Volume{
+		Name: "workload-identity",
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{
+						ServiceAccountToken: &v1.ServiceAccountTokenProjection{
+							Audience:          "sts.amazonaws.com",
+							ExpirationSeconds: &expirationSeconds,
+							Path:              "token",
+						},
+					},
+					{
+						Secret: &v1.SecretProjection{
+							LocalObjectReference: v1.LocalObjectReference{
+								Name: "custom-ca",
+							},
+							Items: []v1.KeyToPath{
+								{
+									Key:  "ca.crt",
+									Path: "ca.crt",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	volumeMount := v1.VolumeMount{
+		Name:      "workload-identity",
+		MountPath: "/var/run/secrets/workload-identity",
+		ReadOnly:  true,
+	}
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+			RunnerContainerSpec: garV1.RunnerContainerSpec{
+				VolumeMounts: []v1.VolumeMount{volumeMount},
+			},
+		},
+	}
+	runner.Spec.Template.Spec.Volumes = []v1.Volume{projectedVolume}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var deployment appsV1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	var got *v1.Volume
+	for i, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == projectedVolume.Name {
+			got = &deployment.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	if got == nil {
+		t.Fatalf("volumes = %v, want %s", deployment.Spec.Template.Spec.Volumes, projectedVolume.Name)
+	}
+	if !reflect.DeepEqual(*got, projectedVolume) {
+		t.Errorf("volume = %v, want %v", *got, projectedVolume)
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "runner" && !reflect.DeepEqual(container.VolumeMounts, []v1.VolumeMount{volumeMount}) {
+			t.Errorf("volumeMounts = %v, want %v", container.VolumeMounts, []v1.VolumeMount{volumeMount})
+		}
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerContainerGlobalEnv(t *testing.T) {
+	type in struct {
+		globalEnv []v1.EnvVar
+		env       []v1.EnvVar
+	}
+
+	type want struct {
+		env []v1.EnvVar
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"global only",
+			in{
+				[]v1.EnvVar{
+					{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector:4317"},
+				},
+				nil,
+			},
+			want{
+				[]v1.EnvVar{
+					{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector:4317"},
+				},
+			},
+		},
+		{
+			"runner wins on conflict",
+			in{
+				[]v1.EnvVar{
+					{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector:4317"},
+					{Name: "DD_AGENT_HOST", Value: "datadog"},
+				},
+				[]v1.EnvVar{
+					{Name: "DD_AGENT_HOST", Value: "localhost"},
+					{Name: "FOO", Value: "bar"},
+				},
+			},
+			want{
+				[]v1.EnvVar{
+					{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector:4317"},
+					{Name: "DD_AGENT_HOST", Value: "localhost"},
+					{Name: "FOO", Value: "bar"},
+				},
+			},
+		},
+		{
+			"runner only",
+			in{
+				nil,
+				[]v1.EnvVar{
+					{Name: "FOO", Value: "bar"},
+				},
+			},
+			want{
+				[]v1.EnvVar{
+					{Name: "FOO", Value: "bar"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.GlobalRunnerEnv = tt.in.globalEnv
+			container := r.buildRunnerContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image: "ubuntu:22.04",
+					RunnerContainerSpec: garV1.RunnerContainerSpec{
+						Env: tt.in.env,
+					},
+				},
+			})
+
+			// Variables of the controller such as REPOSITORY follow the ones above
+			if got := container.Env[:len(tt.want.env)]; !reflect.DeepEqual(got, tt.want.env) {
+				t.Errorf("env = %v, want %v", got, tt.want.env)
+			}
+			if container.Env[len(tt.want.env)].Name != "REPOSITORY" {
+				t.Errorf("env = %v, want REPOSITORY after %v", container.Env, tt.want.env)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentSchedulerName(t *testing.T) {
+	type in struct {
+		defaultSchedulerName string
+		schedulerName        string
+	}
+
+	type want struct {
+		schedulerName string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				"",
+				"",
+			},
+			want{
+				v1.DefaultSchedulerName,
+			},
+		},
+		{
+			"controller",
+			in{
+				"volcano",
+				"",
+			},
+			want{
+				"volcano",
+			},
+		},
+		{
+			"runner wins",
+			in{
+				"volcano",
+				"yunikorn",
+			},
+			want{
+				"yunikorn",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.DefaultSchedulerName = tt.in.defaultSchedulerName
+			deployment := r.buildDeployment(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image:         "ubuntu:22.04",
+					SchedulerName: tt.in.schedulerName,
+				},
+			})
+
+			if got := deployment.Spec.Template.Spec.SchedulerName; got != tt.want.schedulerName {
+				t.Errorf("schedulerName = %q, want %q", got, tt.want.schedulerName)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildDeploymentPodAntiAffinity(t *testing.T) {
+	type in struct {
+		disableGlobally bool
+		disable         bool
+	}
+
+	type want struct {
+		antiAffinity bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				false,
+				false,
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"disabled by controller",
+			in{
+				true,
+				false,
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"disabled by runner",
+			in{
+				false,
+				true,
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			r.DisablePodAntiAffinity = tt.in.disableGlobally
+			deployment := r.buildDeployment(&garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name: "example",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:                  "ubuntu:22.04",
+					DisablePodAntiAffinity: tt.in.disable,
+				},
+			})
+
+			affinity := deployment.Spec.Template.Spec.Affinity
+			if got := affinity != nil && affinity.PodAntiAffinity != nil; got != tt.want.antiAffinity {
+				t.Errorf("podAntiAffinity = %v, want %v", got, tt.want.antiAffinity)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerContainerRegistrationURL(t *testing.T) {
+	type in struct {
+		pattern string
+	}
+
+	type want struct {
+		registrationURL string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				"",
+			},
+			want{
+				"",
+			},
+		},
+		{
+			"GitHub Enterprise Server",
+			in{
+				"https://github.example.com/%s",
+			},
+			want{
+				"https://github.example.com/kaidotdev/github-actions-runner-controller",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			container := r.buildRunnerContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image:                  "ubuntu:22.04",
+					Repository:             "kaidotdev/github-actions-runner-controller",
+					RegistrationURLPattern: tt.in.pattern,
+				},
+			})
+
+			var registrationURL string
+			for _, e := range container.Env {
+				if e.Name == "REGISTRATION_URL" {
+					registrationURL = e.Value
+				}
+			}
+			if registrationURL != tt.want.registrationURL {
+				t.Errorf("REGISTRATION_URL = %q, want %q", registrationURL, tt.want.registrationURL)
+			}
+			if got := slices.Contains(container.Args, "--registration-url=$(REGISTRATION_URL)"); got != (tt.want.registrationURL != "") {
+				t.Errorf("--registration-url passed = %v, want %v", got, tt.want.registrationURL != "")
+			}
+			if !slices.Contains(container.Args, "--repository=$(REPOSITORY)") {
+				t.Error("--repository must be passed to issue registration tokens")
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildContainersTerminationMessage(t *testing.T) {
+	type in struct {
+		path   string
+		policy *v1.TerminationMessagePolicy
+	}
+
+	type want struct {
+		path   string
+		policy v1.TerminationMessagePolicy
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				"",
+				nil,
+			},
+			want{
+				v1.TerminationMessagePathDefault,
+				v1.TerminationMessageReadFile,
+			},
+		},
+		{
+			"fallback to logs",
+			in{
+				"/tmp/termination-log",
+				func(p v1.TerminationMessagePolicy) *v1.TerminationMessagePolicy { return &p }(v1.TerminationMessageFallbackToLogsOnError),
+			},
+			want{
+				"/tmp/termination-log",
+				v1.TerminationMessageFallbackToLogsOnError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			runner := &garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image: "ubuntu:22.04",
+					RunnerContainerSpec: garV1.RunnerContainerSpec{
+						TerminationMessagePath:   tt.in.path,
+						TerminationMessagePolicy: tt.in.policy,
+					},
+					BuilderContainerSpec: garV1.BuilderContainerSpec{
+						TerminationMessagePath:   tt.in.path,
+						TerminationMessagePolicy: tt.in.policy,
+					},
+				},
+			}
+
+			for _, container := range []v1.Container{r.buildRunnerContainer(runner), r.buildBuilderContainer(runner)} {
+				if container.TerminationMessagePath != tt.want.path {
+					t.Errorf("%s: terminationMessagePath = %q, want %q", container.Name, container.TerminationMessagePath, tt.want.path)
+				}
+				if container.TerminationMessagePolicy != tt.want.policy {
+					t.Errorf("%s: terminationMessagePolicy = %q, want %q", container.Name, container.TerminationMessagePolicy, tt.want.policy)
+				}
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildBuilderContainerBuildTimeout(t *testing.T) {
+	type in struct {
+		buildTimeout *metaV1.Duration
+	}
+
+	type want struct {
+		command               string
+		activeDeadlineSeconds *int64
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"without timeout",
+			in{
+				nil,
+			},
+			want{
+				"",
+				nil,
+			},
+		},
+		{
+			"timeout rounded up to seconds",
+			in{
+				&metaV1.Duration{Duration: 90*time.Second + time.Millisecond},
+			},
+			want{
+				"timeout 91 /kaniko/executor",
+				func(i int64) *int64 { return &i }((91 + 300) * 2),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					BuilderContainerSpec: garV1.BuilderContainerSpec{
+						BuildTimeout: tt.in.buildTimeout,
+					},
+				},
+			}
+
+			container := r.buildBuilderContainer(runner)
+			if tt.want.command == "" {
+				if container.Command != nil {
+					t.Errorf("command = %v, want entrypoint of image", container.Command)
+				}
+			} else if len(container.Command) != 4 || container.Command[0] != "sh" || !strings.Contains(container.Command[2], tt.want.command) || container.Command[3] != "--" {
+				t.Errorf("command = %v, want %s wrapped by shell", container.Command, tt.want.command)
+			}
+			if len(container.Args) == 0 || container.Args[0] != "--dockerfile=Dockerfile" {
+				t.Errorf("args = %v, want args of executor", container.Args)
+			}
+
+			job := r.buildWarmupJob(runner)
+			if !reflect.DeepEqual(job.Spec.ActiveDeadlineSeconds, tt.want.activeDeadlineSeconds) {
+				t.Errorf("activeDeadlineSeconds = %v, want %v", job.Spec.ActiveDeadlineSeconds, tt.want.activeDeadlineSeconds)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildRunnerContainerSecurityProfile(t *testing.T) {
+	type in struct {
+		securityProfile garV1.SecurityProfile
+		securityContext *garV1.RunnerSecurityContext
+	}
+
+	type want struct {
+		privileged               bool
+		allowPrivilegeEscalation *bool
+		dropAll                  bool
+		seccompProfileType       v1.SeccompProfileType
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				"",
+				nil,
+			},
+			want{
+				false,
+				nil,
+				false,
+				v1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		{
+			"baseline",
+			in{
+				garV1.SecurityProfileBaseline,
+				nil,
+			},
+			want{
+				false,
+				nil,
+				false,
+				v1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		{
+			"restricted",
+			in{
+				garV1.SecurityProfileRestricted,
+				nil,
+			},
+			want{
+				false,
+				func(b bool) *bool { return &b }(false),
+				true,
+				v1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		{
+			"privileged",
+			in{
+				garV1.SecurityProfilePrivileged,
+				nil,
+			},
+			want{
+				true,
+				nil,
+				false,
+				v1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		{
+			"security context wins",
+			in{
+				garV1.SecurityProfileRestricted,
+				&garV1.RunnerSecurityContext{
+					SeccompProfile: &v1.SeccompProfile{
+						Type: v1.SeccompProfileTypeUnconfined,
+					},
+				},
+			},
+			want{
+				false,
+				func(b bool) *bool { return &b }(false),
+				true,
+				v1.SeccompProfileTypeUnconfined,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newTestRunnerReconciler(t)
+			container := r.buildRunnerContainer(&garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					Image:           "ubuntu:22.04",
+					SecurityProfile: tt.in.securityProfile,
+					RunnerContainerSpec: garV1.RunnerContainerSpec{
+						SecurityContext: tt.in.securityContext,
+					},
+				},
+			})
+
+			securityContext := container.SecurityContext
+			if got := securityContext.Privileged != nil && *securityContext.Privileged; got != tt.want.privileged {
+				t.Errorf("privileged = %v, want %v", got, tt.want.privileged)
+			}
+			if !reflect.DeepEqual(securityContext.AllowPrivilegeEscalation, tt.want.allowPrivilegeEscalation) {
+				t.Errorf("allowPrivilegeEscalation = %v, want %v", securityContext.AllowPrivilegeEscalation, tt.want.allowPrivilegeEscalation)
+			}
+			if got := securityContext.Capabilities != nil && slices.Contains(securityContext.Capabilities.Drop, "ALL"); got != tt.want.dropAll {
+				t.Errorf("drop all capabilities = %v, want %v", got, tt.want.dropAll)
+			}
+			if got := securityContext.SeccompProfile.Type; got != tt.want.seccompProfileType {
+				t.Errorf("seccompProfile = %q, want %q", got, tt.want.seccompProfileType)
+			}
+			if securityContext.RunAsNonRoot == nil || !*securityContext.RunAsNonRoot {
+				t.Error("runAsNonRoot must be kept by any security profile")
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildBuilderContainerRegistries(t *testing.T) {
+	r := newTestRunnerReconciler(t)
+
+	container := r.buildBuilderContainer(&garV1.Runner{
+		Spec: garV1.RunnerSpec{
+			Image: "ubuntu:22.04",
+			BuilderContainerSpec: garV1.BuilderContainerSpec{
+				RegistryMirrors:      []string{"mirror.gcr.io", "registry.example.com:5000/dockerhub"},
+				InsecureRegistries:   []string{"registry.example.com:5000"},
+				InsecurePushRegistry: true,
+				SkipTLSVerifyPush:    true,
+				InsecurePullRegistry: true,
+				SkipTLSVerifyPull:    true,
+			},
+		},
+	})
+
+	want := []string{
+		"--registry-mirror=mirror.gcr.io",
+		"--registry-mirror=registry.example.com:5000/dockerhub",
+		"--insecure-registry=registry.example.com:5000",
+		"--insecure",
+		"--skip-tls-verify",
+		"--insecure-pull",
+		"--skip-tls-verify-pull",
+	}
+	if got := container.Args[len(container.Args)-len(want):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %v, want to end with %v", container.Args, want)
+	}
+}
+
+func TestRunnerReconcilerUpdateTokenSecretConflict(t *testing.T) {
+	type in struct {
+		conflicts int
+	}
+
+	type want struct {
+		updates int
+		err     bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"no conflict",
+			in{
+				0,
+			},
+			want{
+				1,
+				false,
+			},
+		},
+		{
+			"transient conflict",
+			in{
+				2,
+			},
+			want{
+				3,
+				false,
+			},
+		},
+		{
+			"persistent conflict",
+			in{
+				10,
+			},
+			want{
+				tokenSecretUpdateAttempts,
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenSecret := &v1.Secret{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"GITHUB_TOKEN": []byte("old"),
+				},
+			}
+			r := newTestRunnerReconciler(t, tokenSecret)
+			var updates int
+			r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+				Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+					updates++
+					if updates <= tt.in.conflicts {
+						return apierrors.NewConflict(v1.Resource("secrets"), obj.GetName(), errors.New("the object has been modified"))
+					}
+					return c.Update(ctx, obj, opts...)
+				},
+			})
+			ctx := context.Background()
+
+			var current v1.Secret
+			if err := r.Get(ctx, client.ObjectKeyFromObject(tokenSecret), &current); err != nil {
+				t.Fatal(err)
+			}
+			updated, err := r.updateTokenSecret(ctx, &current, r.buildTokenSecret(&garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}, "new", time.Now().Add(time.Hour)))
+			if (err != nil) != tt.want.err {
+				t.Fatalf("updateTokenSecret() error = %v, want error %v", err, tt.want.err)
+			}
+			if updated == tt.want.err {
+				t.Errorf("updateTokenSecret() = %v, want %v", updated, !tt.want.err)
+			}
+			if updates != tt.want.updates {
+				t.Errorf("updates = %d, want %d", updates, tt.want.updates)
+			}
+		})
+	}
+}
+
+func TestWithPeriodicResync(t *testing.T) {
+	type in struct {
+		result   ctrl.Result
+		interval *metaV1.Duration
+	}
+
+	type want struct {
+		result ctrl.Result
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"disabled",
+			in{
+				ctrl.Result{},
+				nil,
+			},
+			want{
+				ctrl.Result{},
+			},
+		},
+		{
+			"no requeue",
+			in{
+				ctrl.Result{},
+				&metaV1.Duration{Duration: 10 * time.Minute},
+			},
+			want{
+				ctrl.Result{RequeueAfter: 10 * time.Minute},
+			},
+		},
+		{
+			"later requeue",
+			in{
+				ctrl.Result{RequeueAfter: time.Hour},
+				&metaV1.Duration{Duration: 10 * time.Minute},
+			},
+			want{
+				ctrl.Result{RequeueAfter: 10 * time.Minute},
+			},
+		},
+		{
+			"earlier requeue",
+			in{
+				ctrl.Result{RequeueAfter: time.Minute},
+				&metaV1.Duration{Duration: 10 * time.Minute},
+			},
+			want{
+				ctrl.Result{RequeueAfter: time.Minute},
+			},
+		},
+		{
+			"immediate requeue",
+			in{
+				ctrl.Result{Requeue: true},
+				&metaV1.Duration{Duration: 10 * time.Minute},
+			},
+			want{
+				ctrl.Result{Requeue: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				Spec: garV1.RunnerSpec{
+					PeriodicResyncInterval: tt.in.interval,
+				},
+			}
+			if got := withPeriodicResync(tt.in.result, runner); got != tt.want.result {
+				t.Errorf("withPeriodicResync() = %v, want %v", got, tt.want.result)
+			}
+		})
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	expected := &v1.ConfigMap{
+		Data: map[string]string{
+			"Dockerfile": "FROM ubuntu:24.04",
+		},
+	}
+	actual := &v1.ConfigMap{
+		Data: map[string]string{
+			"Dockerfile": "FROM ubuntu:22.04",
+		},
+	}
+
+	if got := diffObjects(expected, expected.DeepCopy()); got != "" {
+		t.Errorf("diffObjects() = %q, want empty for equal objects", got)
+	}
+	// The output format of cmp is unstable, so only the changed values are checked
+	got := diffObjects(expected, actual)
+	if !strings.Contains(got, `"FROM ubuntu:22.04"`) || !strings.Contains(got, `"FROM ubuntu:24.04"`) {
+		t.Errorf("diffObjects() = %q, want lines of both Dockerfiles", got)
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	actual := redactSecret(&v1.Secret{
+		Data: map[string][]byte{
+			"GITHUB_TOKEN": []byte("token"),
+		},
+	})
+	expected := redactSecret(&v1.Secret{
+		StringData: map[string]string{
+			"GITHUB_TOKEN": "token",
+		},
+	})
+
+	if got := string(actual.Data["GITHUB_TOKEN"]); strings.Contains(got, "token") {
+		t.Errorf("data = %q, want the value redacted", got)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("redactSecret() = %v and %v, want equal for the same value in data and stringData", actual, expected)
+	}
+}
+
+func TestRunnerSpecChangedPredicate(t *testing.T) {
+	base := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:       "example",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+
+	type in struct {
+		update func(runner *garV1.Runner)
+	}
+
+	type want struct {
+		pass bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"spec",
+			in{
+				func(runner *garV1.Runner) {
+					runner.Spec.Image = "ubuntu:24.04"
+					runner.Generation++
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"annotations",
+			in{
+				func(runner *garV1.Runner) {
+					runner.Annotations = map[string]string{
+						"example.com/owner": "team",
+					}
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"generation without spec",
+			in{
+				func(runner *garV1.Runner) {
+					runner.Generation++
+				},
+			},
+			want{
+				false,
+			},
+		},
+		{
+			"status",
+			in{
+				func(runner *garV1.Runner) {
+					runner.Status.BuiltImageRepository = "ubuntu-0123456"
+				},
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			updated := base.DeepCopy()
+			tt.in.update(updated)
+			if got := (RunnerSpecChangedPredicate{}).Update(event.UpdateEvent{
+				ObjectOld: base,
+				ObjectNew: updated,
+			}); got != tt.want.pass {
+				t.Errorf("Update() = %v, want %v", got, tt.want.pass)
+			}
+		})
+	}
+}
+
+func TestConfigMapChangedPredicate(t *testing.T) {
+	base := &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            "example-workspace",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"Dockerfile": "FROM ubuntu:22.04",
+		},
+	}
+
+	type in struct {
+		update func(configMap *v1.ConfigMap)
+	}
+
+	type want struct {
+		pass bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"data",
+			in{
+				func(configMap *v1.ConfigMap) {
+					configMap.Data["Dockerfile"] = "FROM ubuntu:24.04"
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"binary data",
+			in{
+				func(configMap *v1.ConfigMap) {
+					configMap.BinaryData = map[string][]byte{
+						"entrypoint": []byte("#!/bin/sh"),
+					}
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"labels",
+			in{
+				func(configMap *v1.ConfigMap) {
+					configMap.Labels = map[string]string{
+						"team": "platform",
+					}
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"resource version only",
+			in{
+				func(configMap *v1.ConfigMap) {
+					configMap.ResourceVersion = "2"
+				},
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			updated := base.DeepCopy()
+			tt.in.update(updated)
+			if got := configMapChangedPredicate().Update(event.UpdateEvent{
+				ObjectOld: base,
+				ObjectNew: updated,
+			}); got != tt.want.pass {
+				t.Errorf("Update() = %v, want %v", got, tt.want.pass)
+			}
+		})
+	}
+}
+
+func TestSecretChangedPredicate(t *testing.T) {
+	base := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            "example-token",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Data: map[string][]byte{
+			"token": []byte("ghs_example"),
+		},
+	}
+
+	type in struct {
+		update func(secret *v1.Secret)
+	}
+
+	type want struct {
+		pass bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"data",
+			in{
+				func(secret *v1.Secret) {
+					secret.Data["token"] = []byte("ghs_modified")
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"annotations",
+			in{
+				func(secret *v1.Secret) {
+					secret.Annotations = map[string]string{
+						expiresAtAnnotation: "2026-01-01T00:00:00Z",
+					}
+				},
+			},
+			want{
+				true,
+			},
+		},
+		{
+			"resource version only",
+			in{
+				func(secret *v1.Secret) {
+					secret.ResourceVersion = "2"
+				},
+			},
+			want{
+				false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			updated := base.DeepCopy()
+			tt.in.update(updated)
+			if got := secretChangedPredicate().Update(event.UpdateEvent{
+				ObjectOld: base,
+				ObjectNew: updated,
+			}); got != tt.want.pass {
+				t.Errorf("Update() = %v, want %v", got, tt.want.pass)
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestRunnerReconcilerReconcileTimeout(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+	r.GitHubAppClientId = "Iv1.0123456789abcdef"
+	r.GitHubAppInstallationId = "1"
+	r.GitHubAppPrivateKey = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	r.ReconcileTimeout = 100 * time.Millisecond
+	// GitHub API hangs until the request is cancelled
+	r.HTTPClient = &http.Client{
+		Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			<-request.Context().Done()
+			return nil, request.Context().Err()
+		}),
+	}
+
+	done := make(chan ctrl.Result)
+	go func() {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      runner.Name,
+				Namespace: runner.Namespace,
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+	select {
+	case result := <-done:
+		if result.RequeueAfter != tokenRenewalRetryInterval {
+			t.Errorf("requeueAfter = %s, want %s", result.RequeueAfter, tokenRenewalRetryInterval)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reconciliation is not cancelled after ReconcileTimeout")
+	}
+
+	var recorded bool
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, EventReasonTokenRenewalFailed) && strings.Contains(event, context.DeadlineExceeded.Error()) {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Errorf("no %s event of the timeout is recorded", EventReasonTokenRenewalFailed)
+	}
+}
+
+func TestRunnerReconcilerCreateTokenSecretCancelled(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	r.GitHubAppClientId = "Iv1.0123456789abcdef"
+	r.GitHubAppInstallationId = "1"
+	r.GitHubAppPrivateKey = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	// The reconcile context is cancelled, e.g. on loss of leader election, while GitHub API is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	r.HTTPClient = &http.Client{
+		Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			cancel()
+			<-request.Context().Done()
+			return nil, request.Context().Err()
+		}),
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := r.createTokenSecret(ctx, runner)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("createTokenSecret() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("request to GitHub API is not cancelled with the reconcile context")
+	}
+}
+
+func TestRunnerReconcilerCreateTokenSecretPermissions(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type in struct {
+		requiredPermissions map[string]string
+	}
+
+	type want struct {
+		permissions map[string]string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"default",
+			in{
+				nil,
+			},
+			want{
+				defaultTokenPermissions,
+			},
+		},
+		{
+			"required",
+			in{
+				map[string]string{
+					"actions":                          "read",
+					"organization_self_hosted_runners": "write",
+				},
+			},
+			want{
+				map[string]string{
+					"actions":                          "read",
+					"organization_self_hosted_runners": "write",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:               "ubuntu:22.04",
+					Repository:          "kaidotdev/github-actions-runner-controller",
+					RequiredPermissions: tt.in.requiredPermissions,
+				},
+			}
+			r := newTestRunnerReconciler(t)
+			r.GitHubAppClientId = "Iv1.0123456789abcdef"
+			r.GitHubAppInstallationId = "1"
+			r.GitHubAppPrivateKey = string(pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(key),
+			}))
+			var got map[string]string
+			r.HTTPClient = &http.Client{
+				Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+					var body struct {
+						Permissions map[string]string `json:"permissions"`
+					}
+					if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+						return nil, err
+					}
+					got = body.Permissions
+					return &http.Response{
+						StatusCode: http.StatusCreated,
+						Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"token":"token","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339)))),
+					}, nil
+				}),
+			}
+
+			if _, err := r.createTokenSecret(context.Background(), runner); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want.permissions) {
+				t.Errorf("permissions = %v, want %v", got, tt.want.permissions)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerCreateTokenSecretDiscoverInstallationId(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type in struct {
+		repository string
+	}
+
+	type want struct {
+		accessTokensPath string
+		err              bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"installed",
+			in{
+				"kaidotdev/github-actions-runner-controller",
+			},
+			want{
+				"/app/installations/12345/access_tokens",
+				false,
+			},
+		},
+		{
+			"not installed",
+			in{
+				"kaidotdev/unknown",
+			},
+			want{
+				"",
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: tt.in.repository,
+				},
+			}
+			r := newTestRunnerReconciler(t)
+			r.GitHubAppClientId = "Iv1.0123456789abcdef"
+			r.DiscoverInstallationId = true
+			r.GitHubAppPrivateKey = string(pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(key),
+			}))
+			var discoveries int
+			var accessTokensPath string
+			r.HTTPClient = &http.Client{
+				Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+					switch request.URL.Path {
+					case "/repos/kaidotdev/github-actions-runner-controller/installation":
+						discoveries++
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(strings.NewReader(`{"id":12345}`)),
+						}, nil
+					case "/repos/kaidotdev/unknown/installation":
+						discoveries++
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       io.NopCloser(strings.NewReader(`{}`)),
+						}, nil
+					}
+					accessTokensPath = request.URL.Path
+					return &http.Response{
+						StatusCode: http.StatusCreated,
+						Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"token":"token","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339)))),
+					}, nil
+				}),
+			}
+			if !r.usesInstallationToken(runner) {
+				t.Fatal("runner must use installation token when the installation is discovered")
+			}
+
+			_, err := r.createTokenSecret(context.Background(), runner)
+			if (err != nil) != tt.want.err {
+				t.Fatalf("err = %v, want error %v", err, tt.want.err)
+			}
+			if accessTokensPath != tt.want.accessTokensPath {
+				t.Errorf("access tokens path = %q, want %q", accessTokensPath, tt.want.accessTokensPath)
+			}
+			if tt.want.err {
+				return
+			}
+
+			// The token is renewed with the cached installation
+			r.tokenCache.Delete("12345:" + tt.in.repository)
+			if _, err := r.createTokenSecret(context.Background(), runner); err != nil {
+				t.Fatal(err)
+			}
+			if discoveries != 1 {
+				t.Errorf("discoveries = %d, want 1 with the cache", discoveries)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileSharedTokenSecret(t *testing.T) {
+	newRunner := func(name string) *garV1.Runner {
+		return &garV1.Runner{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name),
+			},
+			Spec: garV1.RunnerSpec{
+				Image:             "ubuntu:22.04",
+				Repository:        "kaidotdev/github-actions-runner-controller",
+				SkipWarmup:        true,
+				SharedTokenSecret: true,
+			},
+		}
+	}
+	first := newRunner("first")
+	second := newRunner("second")
+	r := newTestRunnerReconciler(t, first, second)
+	r.GitHubAppClientId = "Iv1.0123456789abcdef"
+	r.GitHubAppInstallationId = "1"
+	// GitHub App private key is invalid, so tokens are issued only from the cache
+	r.GitHubAppPrivateKey = "invalid"
+	r.tokenCache.Store("1:"+first.Spec.Repository, cachedToken{
+		token:     "shared",
+		expiresAt: time.Now().Add(time.Hour),
+	})
+	ctx := context.Background()
+
+	for _, runner := range []*garV1.Runner{first, second} {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(runner)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var secrets v1.SecretList
+	if err := r.List(ctx, &secrets, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 1 {
+		t.Fatalf("secrets = %d, want 1 shared by runners", len(secrets.Items))
+	}
+	secret := secrets.Items[0]
+	if secret.Name != tokenSecretName(first) || secret.Name == first.Name {
+		t.Errorf("name = %q, want %q", secret.Name, tokenSecretName(first))
+	}
+	if len(secret.OwnerReferences) != 2 || metaV1.GetControllerOf(&secret) != nil {
+		t.Errorf("ownerReferences = %v, want both runners without controller", secret.OwnerReferences)
+	}
+
+	for _, runner := range []*garV1.Runner{first, second} {
+		var deployment appsV1.Deployment
+		if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		var referenced bool
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, e := range container.Env {
+				if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil && e.ValueFrom.SecretKeyRef.Name == secret.Name {
+					referenced = true
+				}
+			}
+		}
+		if !referenced {
+			t.Errorf("deployment of %s must refer to the shared token secret", runner.Name)
+		}
+	}
+
+	// The secret is left to the garbage collector rather than deleted as orphaned
+	collector := &OrphanedSecretCollector{
+		Client: r.Client,
+		Log:    logr.Discard(),
+		Scheme: r.Scheme,
+	}
+	if _, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&secret)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(&secret), &v1.Secret{}); err != nil {
+		t.Errorf("shared token secret must not be deleted as orphaned: %v", err)
+	}
+}
+
+func TestRunnerReconcilerReconcileManagedResourceMetadata(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+			ManagedResourceLabels: map[string]string{
+				"team": "platform",
+				"cost": "ci",
+			},
+			ManagedResourceAnnotations: map[string]string{
+				"owner":            "platform@example.com",
+				specHashAnnotation: "overridden",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	assertMetadata := func(wantLabels map[string]string, wantOwner string) {
+		t.Helper()
+
+		var deployment appsV1.Deployment
+		if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(deployment.Labels, wantLabels) {
+			t.Errorf("labels of deployment = %v, want %v", deployment.Labels, wantLabels)
+		}
+		if got := deployment.Annotations["owner"]; got != wantOwner {
+			t.Errorf("owner annotation of deployment = %q, want %q", got, wantOwner)
+		}
+		if deployment.Annotations[specHashAnnotation] != hashPodTemplate(&deployment.Spec.Template) {
+			t.Error("annotations of the controller must take precedence")
+		}
+
+		var configMap v1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-workspace", Namespace: runner.Namespace}, &configMap); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(configMap.Labels, wantLabels) {
+			t.Errorf("labels of config map = %v, want %v", configMap.Labels, wantLabels)
+		}
+		if got := configMap.Annotations["owner"]; got != wantOwner {
+			t.Errorf("owner annotation of config map = %q, want %q", got, wantOwner)
+		}
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	assertMetadata(map[string]string{"team": "platform", "cost": "ci"}, "platform@example.com")
+
+	var updated garV1.Runner
+	if err := r.Get(ctx, req.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Spec.ManagedResourceLabels = map[string]string{"team": "infra"}
+	updated.Spec.ManagedResourceAnnotations = nil
+	if err := r.Update(ctx, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	assertMetadata(map[string]string{"team": "infra"}, "")
+}
+
+func TestRunnerReconcilerReconcileInheritedLabels(t *testing.T) {
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+			Labels: map[string]string{
+				"team":                   "backend",
+				"app.kubernetes.io/name": "runner",
+			},
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	ctx := context.Background()
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+		},
+	}
+
+	assertLabels := func(want map[string]string) {
+		t.Helper()
+
+		var deployment appsV1.Deployment
+		if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(deployment.Labels, want) {
+			t.Errorf("labels of deployment = %v, want %v", deployment.Labels, want)
+		}
+
+		var configMap v1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-workspace", Namespace: runner.Namespace}, &configMap); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(configMap.Labels, want) {
+			t.Errorf("labels of config map = %v, want %v", configMap.Labels, want)
+		}
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	assertLabels(map[string]string{"team": "backend"})
+
+	var updated garV1.Runner
+	if err := r.Get(ctx, req.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Labels = map[string]string{"team": "frontend"}
+	updated.Spec.ManagedResourceLabels = map[string]string{"cost": "ci"}
+	if err := r.Update(ctx, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	assertLabels(map[string]string{"team": "frontend", "cost": "ci"})
+}
+
+func TestBuildManagedResourceLabels(t *testing.T) {
+	type in struct {
+		labels                map[string]string
+		managedResourceLabels map[string]string
+	}
+
+	type want struct {
+		labels map[string]string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"empty",
+			in{
+				nil,
+				nil,
+			},
+			want{
+				nil,
+			},
+		},
+		{
+			"inherited",
+			in{
+				map[string]string{
+					"team": "backend",
+				},
+				nil,
+			},
+			want{
+				map[string]string{
+					"team": "backend",
+				},
+			},
+		},
+		{
+			"reserved",
+			in{
+				map[string]string{
+					"kubernetes.io/metadata.name": "example",
+					"app.kubernetes.io/name":      "runner",
+					"k8s.io/component":            "runner",
+					"example.com/team":            "backend",
+				},
+				nil,
+			},
+			want{
+				map[string]string{
+					"example.com/team": "backend",
+				},
+			},
+		},
+		{
+			"managed resource labels win",
+			in{
+				map[string]string{
+					"team": "backend",
+					"cost": "ci",
+				},
+				map[string]string{
+					"team": "platform",
+				},
+			},
+			want{
+				map[string]string{
+					"team": "platform",
+					"cost": "ci",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := buildManagedResourceLabels(&garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Labels: tt.in.labels,
+				},
+				Spec: garV1.RunnerSpec{
+					ManagedResourceLabels: tt.in.managedResourceLabels,
+				},
+			})
+			if !reflect.DeepEqual(got, tt.want.labels) {
+				t.Errorf("buildManagedResourceLabels() = %v, want %v", got, tt.want.labels)
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerBuildTokenSecretManagedResourceMetadata(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newTestRunnerReconciler(t)
+	r.EncryptionAnnotations = map[string]string{
+		"example.com/kms-key": "github-tokens",
+	}
+	secret := r.buildTokenSecret(&garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerSpec{
+			ManagedResourceLabels: map[string]string{
+				"team": "platform",
+			},
+			ManagedResourceAnnotations: map[string]string{
+				expiresAtAnnotation:   "overridden",
+				"example.com/kms-key": "overridden",
+			},
+		},
+	}, "token", expiresAt)
+
+	if want := map[string]string{"team": "platform"}; !reflect.DeepEqual(secret.Labels, want) {
+		t.Errorf("labels = %v, want %v", secret.Labels, want)
+	}
+	if want := map[string]string{expiresAtAnnotation: expiresAt.Format(time.RFC3339), "example.com/kms-key": "github-tokens"}; !reflect.DeepEqual(secret.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", secret.Annotations, want)
+	}
+	if secret.Type != v1.SecretTypeOpaque {
+		t.Errorf("type = %q, want %q", secret.Type, v1.SecretTypeOpaque)
+	}
+}
+
+func newEnvtestRunner(namespace string) *garV1.Runner {
+	return &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: namespace,
+		},
+		Spec: garV1.RunnerSpec{
+			Image:      "ubuntu:22.04",
+			Repository: "kaidotdev/github-actions-runner-controller",
+			// Warm-up jobs never complete without the job controller
+			SkipWarmup: true,
+			TokenSecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "credentials",
+				},
+				Key: "TOKEN",
+			},
+		},
+	}
+}
+
+func TestRunnerReconcilerEnvtestCreate(t *testing.T) {
+	namespace := newEnvtestNamespace(t)
+	ctx := context.Background()
+	runner := newEnvtestRunner(namespace)
+	if err := envtestClient.Create(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, func(ctx context.Context) error {
+		var configMap v1.ConfigMap
+		if err := envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-workspace", Namespace: namespace}, &configMap); err != nil {
+			return err
+		}
+		if !strings.Contains(configMap.Data["Dockerfile"], "FROM ubuntu:22.04") {
+			return fmt.Errorf("Dockerfile = %q, want FROM ubuntu:22.04", configMap.Data["Dockerfile"])
+		}
+		return nil
+	})
+	eventually(t, func(ctx context.Context) error {
+		return envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: namespace}, &appsV1.Deployment{})
+	})
+}
+
+func TestRunnerReconcilerEnvtestUpdateImage(t *testing.T) {
+	namespace := newEnvtestNamespace(t)
+	ctx := context.Background()
+	runner := newEnvtestRunner(namespace)
+	if err := envtestClient.Create(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func(ctx context.Context) error {
+		return envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: namespace}, &appsV1.Deployment{})
+	})
+
+	patch := client.MergeFrom(runner.DeepCopy())
+	runner.Spec.Image = "ubuntu:24.04"
+	if err := envtestClient.Patch(ctx, runner, patch); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, func(ctx context.Context) error {
+		var configMap v1.ConfigMap
+		if err := envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-workspace", Namespace: namespace}, &configMap); err != nil {
+			return err
+		}
+		if !strings.Contains(configMap.Data["Dockerfile"], "FROM ubuntu:24.04") {
+			return fmt.Errorf("Dockerfile = %q, want FROM ubuntu:24.04", configMap.Data["Dockerfile"])
+		}
+		return nil
+	})
+	eventually(t, func(ctx context.Context) error {
+		var deployment appsV1.Deployment
+		if err := envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: namespace}, &deployment); err != nil {
+			return err
+		}
+		if image := deployment.Spec.Template.Annotations["image"]; image != "ubuntu:24.04" {
+			return fmt.Errorf("image = %q, want ubuntu:24.04", image)
+		}
+		return nil
+	})
+}
+
+// envtest runs no garbage collector, so deletion is verified by the owner references the garbage collector follows
+func TestRunnerReconcilerEnvtestDelete(t *testing.T) {
+	namespace := newEnvtestNamespace(t)
+	ctx := context.Background()
+	runner := newEnvtestRunner(namespace)
+	if err := envtestClient.Create(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+
+	owned := []client.Object{
+		&v1.ConfigMap{ObjectMeta: metaV1.ObjectMeta{Name: runner.Name + "-workspace", Namespace: namespace}},
+		&appsV1.Deployment{ObjectMeta: metaV1.ObjectMeta{Name: runner.Name + "-runner", Namespace: namespace}},
+	}
+	for _, obj := range owned {
+		eventually(t, func(ctx context.Context) error {
+			return envtestClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		})
+		owner := metaV1.GetControllerOf(obj)
+		if owner == nil || owner.UID != runner.UID || owner.BlockOwnerDeletion == nil || !*owner.BlockOwnerDeletion {
+			t.Errorf("%T %q: controller = %v, want runner blocking its deletion", obj, obj.GetName(), owner)
+		}
+	}
+
+	if err := envtestClient.Delete(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func(ctx context.Context) error {
+		if err := envtestClient.Get(ctx, client.ObjectKeyFromObject(runner), &garV1.Runner{}); !apierrors.IsNotFound(err) {
+			return fmt.Errorf("runner is not deleted: %v", err)
+		}
+		return nil
+	})
+}
+
+func TestRunnerReconcilerEnvtestTokenSecret(t *testing.T) {
+	namespace := newEnvtestNamespace(t)
+	ctx := context.Background()
+	runner := newEnvtestRunner(namespace)
+	// Installation tokens are issued by the stub of GitHub API to runners without tokens
+	runner.Spec.TokenSecretKeyRef = nil
+	if err := envtestClient.Create(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, func(ctx context.Context) error {
+		var secret v1.Secret
+		if err := envtestClient.Get(ctx, client.ObjectKey{Name: tokenSecretName(runner), Namespace: namespace}, &secret); err != nil {
+			return err
+		}
+		if _, err := time.Parse(time.RFC3339, secret.Annotations[expiresAtAnnotation]); err != nil {
+			return fmt.Errorf("invalid %s: %w", expiresAtAnnotation, err)
+		}
+		for _, value := range secret.Data {
+			if string(value) == envtestToken {
+				return nil
+			}
+		}
+		return fmt.Errorf("token secret %q has no token issued by the stub", secret.Name)
+	})
+	eventually(t, func(ctx context.Context) error {
+		var latest garV1.Runner
+		if err := envtestClient.Get(ctx, client.ObjectKeyFromObject(runner), &latest); err != nil {
+			return err
+		}
+		if latest.Status.TokenExpiresAt == nil {
+			return fmt.Errorf("tokenExpiresAt is not recorded")
+		}
+		return nil
+	})
+}
+
+func TestRunnerReconcilerEnvtestConflict(t *testing.T) {
+	namespace := newEnvtestNamespace(t)
+	ctx := context.Background()
+	runner := newEnvtestRunner(namespace)
+	if err := envtestClient.Create(ctx, runner); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func(ctx context.Context) error {
+		return envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: namespace}, &appsV1.Deployment{})
+	})
+
+	envtestConflictNamespaces.Store(namespace, struct{}{})
+	patch := client.MergeFrom(runner.DeepCopy())
+	runner.Spec.Image = "ubuntu:24.04"
+	if err := envtestClient.Patch(ctx, runner, patch); err != nil {
+		t.Fatal(err)
+	}
+
+	// The update is retried after the conflict
+	eventually(t, func(ctx context.Context) error {
+		if _, ok := envtestConflictNamespaces.Load(namespace); ok {
+			return fmt.Errorf("conflict is not injected yet")
+		}
+		var deployment appsV1.Deployment
+		if err := envtestClient.Get(ctx, client.ObjectKey{Name: runner.Name + "-runner", Namespace: namespace}, &deployment); err != nil {
+			return err
+		}
+		if image := deployment.Spec.Template.Annotations["image"]; image != "ubuntu:24.04" {
+			return fmt.Errorf("image = %q, want ubuntu:24.04", image)
+		}
+		return nil
+	})
+}
+
+func TestRunnerReconcilerMapRunnerClassToRunners(t *testing.T) {
+	type in struct {
+		selector metaV1.LabelSelector
+	}
+
+	type want struct {
+		runners []string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"selected and inherited",
+			in{
+				metaV1.LabelSelector{
+					MatchLabels: map[string]string{
+						"team": "a",
+					},
+				},
+			},
+			want{
+				[]string{"inherited", "selected"},
+			},
+		},
+		{
+			"empty selector",
+			in{
+				metaV1.LabelSelector{},
+			},
+			want{
+				[]string{"inherited"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runnerClass := &garV1.RunnerClass{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name: "team-a",
+				},
+				Spec: garV1.RunnerClassSpec{
+					Selector: tt.in.selector,
+				},
+			}
+			r := newTestRunnerReconciler(t,
+				&garV1.Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "selected",
+						Namespace: "default",
+						Labels: map[string]string{
+							"team": "a",
+						},
+					},
+				},
+				// Runners which inherited the runner class stop inheriting it when its selector changes
+				&garV1.Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "inherited",
+						Namespace: "default",
+						Annotations: map[string]string{
+							runnerClassAnnotation: "team-a",
+						},
+					},
+				},
+				&garV1.Runner{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      "other",
+						Namespace: "default",
+						Labels: map[string]string{
+							"team": "b",
+						},
+					},
+				},
+			)
+
+			var got []string
+			for _, request := range r.mapRunnerClassToRunners(context.Background(), runnerClass) {
+				got = append(got, request.Name)
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want.runners) {
+				t.Errorf("mapRunnerClassToRunners() = %v, want %v", got, tt.want.runners)
+			}
+		})
+	}
+}
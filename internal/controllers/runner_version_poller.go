@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/xerrors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const latestRunnerReleaseURL = "https://api.github.com/repos/actions/runner/releases/latest"
+
+// RunnerVersionPoller periodically fetches the latest release of GitHub Actions runner
+// and upgrades all runners to it by annotating them
+type RunnerVersionPoller struct {
+	client.Client
+	Log        logr.Logger
+	Reconciler *RunnerReconciler
+	// Interval of polling. Defaults to 1 hour.
+	Interval time.Duration
+	// URL of GitHub API returning the latest release. Defaults to the one of actions/runner.
+	ReleaseURL string
+}
+
+func (p *RunnerVersionPoller) Start(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(ctx); err != nil {
+			p.Log.Error(err, "failed to poll runner version")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes only the leader upgrade runners as well as RunnerReconciler
+func (p *RunnerVersionPoller) NeedLeaderElection() bool {
+	return true
+}
+
+func (p *RunnerVersionPoller) poll(ctx context.Context) error {
+	version, err := p.fetchLatestVersion(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to fetch latest runner version: %w", err)
+	}
+
+	// Runners are listed from the cache, which is restricted to the namespace watched by the controller
+	var runners garV1.RunnerList
+	if err := p.List(ctx, &runners); err != nil {
+		return xerrors.Errorf("failed to list runners: %w", err)
+	}
+	for i := range runners.Items {
+		runner := &runners.Items[i]
+		if !p.Reconciler.selectsRunner(runner) || p.Reconciler.runnerVersionOf(runner) == version {
+			continue
+		}
+		patch := client.MergeFrom(runner.DeepCopy())
+		if runner.Annotations == nil {
+			runner.Annotations = map[string]string{}
+		}
+		runner.Annotations[runnerVersionAnnotation] = version
+		if err := p.Patch(ctx, runner, patch); err != nil {
+			return xerrors.Errorf("failed to annotate runner %q: %w", client.ObjectKeyFromObject(runner), err)
+		}
+	}
+
+	// The version is upgraded only after all runners are annotated,
+	// otherwise runners failed to be annotated are never upgraded by the following polls
+	if version != p.Reconciler.runnerVersion() {
+		p.Log.Info("upgrade runner version", "from", p.Reconciler.runnerVersion(), "to", version)
+		p.Reconciler.setRunnerVersion(version)
+	}
+	return nil
+}
+
+func (p *RunnerVersionPoller) fetchLatestVersion(ctx context.Context) (string, error) {
+	url := p.ReleaseURL
+	if url == "" {
+		url = latestRunnerReleaseURL
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	response, err := p.Reconciler.httpClient().Do(request)
+	if err != nil {
+		return "", xerrors.Errorf("failed to do request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("failed to get latest release: %d", response.StatusCode)
+	}
+
+	release := struct {
+		TagName string `json:"tag_name"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&release); err != nil {
+		return "", xerrors.Errorf("failed to decode release: %w", err)
+	}
+	version := strings.TrimPrefix(release.TagName, "v")
+	if version == "" {
+		return "", xerrors.New("latest release has no tag")
+	}
+	return version, nil
+}
+
+func (p *RunnerVersionPoller) SetupWithManager(mgr ctrl.Manager) error {
+	p.Reconciler.autoUpgrade = true
+	return mgr.Add(p)
+}
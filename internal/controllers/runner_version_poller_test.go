@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/xerrors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestRunnerVersionPollerPoll(t *testing.T) {
+	type in struct {
+		tagName        string
+		annotation     string
+		runnerSelector *metaV1.LabelSelector
+	}
+
+	type want struct {
+		runnerVersion string
+		annotation    string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"upgraded",
+			in{
+				"v2.322.0",
+				"",
+				nil,
+			},
+			want{
+				"2.322.0",
+				"2.322.0",
+			},
+		},
+		{
+			"up to date",
+			in{
+				"v0.0.0",
+				"",
+				nil,
+			},
+			want{
+				"0.0.0",
+				"",
+			},
+		},
+		{
+			"annotated before restart",
+			in{
+				"v2.322.0",
+				"2.322.0",
+				nil,
+			},
+			want{
+				"2.322.0",
+				"2.322.0",
+			},
+		},
+		{
+			"not selected",
+			in{
+				"v2.322.0",
+				"",
+				&metaV1.LabelSelector{
+					MatchLabels: map[string]string{
+						"team": "example",
+					},
+				},
+			},
+			want{
+				"2.322.0",
+				"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprintf(w, `{"tag_name":%q}`, tt.in.tagName)
+			}))
+			defer server.Close()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			if tt.in.annotation != "" {
+				runner.Annotations = map[string]string{
+					runnerVersionAnnotation: tt.in.annotation,
+				}
+			}
+			r := newTestRunnerReconciler(t, runner)
+			r.RunnerSelector = tt.in.runnerSelector
+			r.autoUpgrade = true
+			p := &RunnerVersionPoller{
+				Client:     r.Client,
+				Log:        logr.Discard(),
+				Reconciler: r,
+				ReleaseURL: server.URL,
+			}
+
+			if err := p.poll(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.runnerVersion(); got != tt.want.runnerVersion {
+				t.Errorf("runnerVersion() = %q, want %q", got, tt.want.runnerVersion)
+			}
+			var got garV1.Runner
+			if err := r.Get(context.Background(), client.ObjectKeyFromObject(runner), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got := got.Annotations[runnerVersionAnnotation]; got != tt.want.annotation {
+				t.Errorf("annotation = %q, want %q", got, tt.want.annotation)
+			}
+		})
+	}
+}
+
+func TestRunnerVersionPollerPollPatchFailure(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"tag_name":"v2.322.0"}`)
+	}))
+	defer server.Close()
+
+	runner := &garV1.Runner{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+	}
+	r := newTestRunnerReconciler(t, runner)
+	r.autoUpgrade = true
+	var failed atomic.Bool
+	p := &RunnerVersionPoller{
+		Client: interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if failed.CompareAndSwap(false, true) {
+					return xerrors.New("failed to patch")
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}),
+		Log:        logr.Discard(),
+		Reconciler: r,
+		ReleaseURL: server.URL,
+	}
+
+	if err := p.poll(context.Background()); err == nil {
+		t.Fatal("poll() succeeded, want error")
+	}
+	if got := r.runnerVersion(); got != "0.0.0" {
+		t.Errorf("runnerVersion() = %q, want %q", got, "0.0.0")
+	}
+
+	// The runner failed to be annotated is annotated by the next poll
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.runnerVersion(); got != "2.322.0" {
+		t.Errorf("runnerVersion() = %q, want %q", got, "2.322.0")
+	}
+	var got garV1.Runner
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(runner), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got := got.Annotations[runnerVersionAnnotation]; got != "2.322.0" {
+		t.Errorf("annotation = %q, want %q", got, "2.322.0")
+	}
+}
+
+func TestRunnerReconcilerRunnerVersionOf(t *testing.T) {
+	type in struct {
+		autoUpgrade bool
+		annotation  string
+	}
+
+	type want struct {
+		version string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"annotated",
+			in{
+				true,
+				"2.322.0",
+			},
+			want{
+				"2.322.0",
+			},
+		},
+		{
+			"not annotated",
+			in{
+				true,
+				"",
+			},
+			want{
+				"0.0.0",
+			},
+		},
+		{
+			"auto upgrade disabled",
+			in{
+				false,
+				"2.322.0",
+			},
+			want{
+				"0.0.0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+					Annotations: map[string]string{
+						runnerVersionAnnotation: tt.in.annotation,
+					},
+				},
+				Spec: garV1.RunnerSpec{
+					Image: "ubuntu:22.04",
+				},
+			}
+			r := newTestRunnerReconciler(t)
+			r.autoUpgrade = tt.in.autoUpgrade
+
+			if got := r.runnerVersionOf(runner); got != tt.want.version {
+				t.Errorf("runnerVersionOf() = %q, want %q", got, tt.want.version)
+			}
+			if !strings.Contains(r.buildDockerfile(runner), tt.want.version) {
+				t.Errorf("buildDockerfile() does not contain %q", tt.want.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+type RunnerClassReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+func (r *RunnerClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	runnerClass := &garV1.RunnerClass{}
+	logger := r.Log.WithValues("runnerclass", req.NamespacedName)
+	if err := r.Get(ctx, req.NamespacedName, runnerClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := runnerClass.Validate(); err != nil {
+		r.Recorder.Eventf(runnerClass, coreV1.EventTypeWarning, EventReasonInvalidRunnerClass, "Invalid runner class: %v", err)
+		logger.Info("invalid runner class", "error", err.Error())
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *RunnerClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&garV1.RunnerClass{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
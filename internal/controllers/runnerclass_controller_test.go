@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunnerClassReconcilerReconcile(t *testing.T) {
+	type in struct {
+		runnerClass *garV1.RunnerClass
+	}
+
+	type want struct {
+		events int
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"valid",
+			in{
+				&garV1.RunnerClass{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name: "valid",
+					},
+					Spec: garV1.RunnerClassSpec{
+						Selector: metaV1.LabelSelector{
+							MatchLabels: map[string]string{
+								"team": "a",
+							},
+						},
+					},
+				},
+			},
+			want{
+				0,
+			},
+		},
+		{
+			"invalid",
+			in{
+				&garV1.RunnerClass{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name: "invalid",
+					},
+					Spec: garV1.RunnerClassSpec{
+						DefaultSpec: garV1.RunnerSpec{
+							Replicas: func(i int32) *int32 { return &i }(-1),
+						},
+					},
+				},
+			},
+			want{
+				1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scheme := runtime.NewScheme()
+			if err := garV1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &RunnerClassReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.in.runnerClass).Build(),
+				Log:      logr.Discard(),
+				Scheme:   scheme,
+				Recorder: recorder,
+			}
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name: tt.in.runnerClass.Name,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(recorder.Events); got != tt.want.events {
+				t.Errorf("events = %d, want %d", got, tt.want.events)
+			}
+		})
+	}
+}
+
+func TestRunnerClassReconcilerReconcileNotFound(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := garV1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	r := &RunnerClassReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Log:      logr.Discard(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name: "missing",
+		},
+	}); err != nil {
+		t.Errorf("Reconcile() error = %v, want nil", err)
+	}
+}
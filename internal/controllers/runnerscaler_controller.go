@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/xerrors"
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ScaledObjectGroupVersionKind is the kind of KEDA ScaledObject, which is handled as unstructured to avoid depending on KEDA
+var ScaledObjectGroupVersionKind = schema.GroupVersionKind{
+	Group:   "keda.sh",
+	Version: "v1alpha1",
+	Kind:    "ScaledObject",
+}
+
+// ScaledObjectAvailable returns whether KEDA ScaledObject CRD is installed in the cluster
+func ScaledObjectAvailable(mapper meta.RESTMapper) (bool, error) {
+	if _, err := mapper.RESTMapping(ScaledObjectGroupVersionKind.GroupKind(), ScaledObjectGroupVersionKind.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type RunnerScalerReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+func (r *RunnerScalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	runnerScaler := &garV1.RunnerScaler{}
+	logger := r.Log.WithValues("runnerscaler", req.NamespacedName)
+	if err := r.Get(ctx, req.NamespacedName, runnerScaler); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	scaledObject, err := r.buildScaledObject(runnerScaler)
+	if err != nil {
+		r.Recorder.Eventf(runnerScaler, coreV1.EventTypeWarning, EventReasonInvalidScaledObjectTemplate, "Invalid scaled object template: %v", err)
+		logger.Info("invalid scaled object template", "error", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err := controllerutil.SetControllerReference(runnerScaler, scaledObject, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Patch(ctx, scaledObject, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+		r.Recorder.Eventf(runnerScaler, coreV1.EventTypeWarning, EventReasonUpdateFailed, "Failed to apply scaled object %q: %v", scaledObject.GetName(), err)
+		return ctrl.Result{}, err
+	}
+	logger.V(1).Info("apply", "scaled object", scaledObject)
+
+	return ctrl.Result{}, nil
+}
+
+func (r *RunnerScalerReconciler) buildScaledObject(runnerScaler *garV1.RunnerScaler) (*unstructured.Unstructured, error) {
+	spec := map[string]interface{}{}
+	if len(runnerScaler.Spec.ScaledObjectTemplate.Raw) != 0 {
+		if err := json.Unmarshal(runnerScaler.Spec.ScaledObjectTemplate.Raw, &spec); err != nil {
+			return nil, xerrors.Errorf("failed to unmarshal scaled object template: %w", err)
+		}
+	}
+	spec["scaleTargetRef"] = map[string]interface{}{
+		"apiVersion": appsV1.SchemeGroupVersion.String(),
+		"kind":       "Deployment",
+		"name":       runnerScaler.Spec.RunnerName + "-runner",
+	}
+
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(ScaledObjectGroupVersionKind)
+	scaledObject.SetName(runnerScaler.Name)
+	scaledObject.SetNamespace(runnerScaler.Namespace)
+	if err := unstructured.SetNestedField(scaledObject.Object, spec, "spec"); err != nil {
+		return nil, xerrors.Errorf("failed to set spec: %w", err)
+	}
+	return scaledObject, nil
+}
+
+func (r *RunnerScalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(ScaledObjectGroupVersionKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&garV1.RunnerScaler{}).
+		Owns(scaledObject).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
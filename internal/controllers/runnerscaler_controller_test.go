@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestRunnerScalerReconcilerReconcile(t *testing.T) {
+	runnerScaler := &garV1.RunnerScaler{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: garV1.RunnerScalerSpec{
+			RunnerName: "example",
+			ScaledObjectTemplate: runtime.RawExtension{
+				Raw: []byte(`{"maxReplicaCount":10,"scaleTargetRef":{"name":"overridden"}}`),
+			},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := garV1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypeWithName(ScaledObjectGroupVersionKind, &unstructured.Unstructured{})
+	r := &RunnerScalerReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(runnerScaler).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: applyAsUpdate,
+			}).
+			Build(),
+		Log:      logr.Discard(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      runnerScaler.Name,
+			Namespace: runnerScaler.Namespace,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(ScaledObjectGroupVersionKind)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(runnerScaler), scaledObject); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := unstructured.NestedInt64(scaledObject.Object, "spec", "maxReplicaCount"); got != 10 {
+		t.Errorf("maxReplicaCount = %d, want %d", got, 10)
+	}
+	if got, _, _ := unstructured.NestedString(scaledObject.Object, "spec", "scaleTargetRef", "name"); got != "example-runner" {
+		t.Errorf("scaleTargetRef.name = %q, want %q", got, "example-runner")
+	}
+	if owner := metaV1.GetControllerOf(scaledObject); owner == nil || owner.Name != runnerScaler.Name {
+		t.Errorf("scaled object is not owned by runner scaler")
+	}
+}
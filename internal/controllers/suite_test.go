@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// Integration tests run the controller against the API server of envtest, whose binaries are installed by setup-envtest,
+// e.g. KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test ./...
+// They are skipped when KUBEBUILDER_ASSETS is not set.
+var (
+	// Client reading the API server directly, so that tests observe the changes of the controller without the lag of the cache
+	envtestClient client.Client
+	// Namespaces in which the next server-side apply of a deployment fails with a conflict
+	envtestConflictNamespaces sync.Map
+)
+
+const (
+	envtestInstallationId = "1"
+	envtestToken          = "ghs_envtest"
+	envtestTimeout        = 30 * time.Second
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		return m.Run()
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "manifests", "crd")},
+		ErrorIfCRDPathMissing: true,
+	}
+	config, err := testEnv.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start envtest: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to stop envtest: %v\n", err)
+		}
+	}()
+
+	github := httptest.NewServer(newStubGitHubAPI())
+	defer github.Close()
+
+	// The manager is stopped before envtest, otherwise it keeps retrying to reach the stopped API server
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	if err := startEnvtestManager(ctx, config, github.URL, done); err != nil {
+		cancel()
+		fmt.Fprintf(os.Stderr, "failed to start manager: %v\n", err)
+		return 1
+	}
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run manager: %v\n", err)
+		}
+	}()
+
+	return m.Run()
+}
+
+// startEnvtestManager runs RunnerReconciler with GitHub App of the stub, whose installation tokens are issued to runners without tokenSecretKeyRef
+func startEnvtestManager(ctx context.Context, config *rest.Config, githubURL string, done chan<- error) error {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := garV1.AddToScheme(scheme); err != nil {
+		return err
+	}
+
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	envtestClient = c
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme: scheme,
+		Metrics: metricsserver.Options{
+			BindAddress: "0",
+		},
+		Logger: logr.Discard(),
+	})
+	if err != nil {
+		return err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	stub, err := url.Parse(githubURL)
+	if err != nil {
+		return err
+	}
+	r := &RunnerReconciler{
+		Client:                  &conflictingClient{Client: mgr.GetClient()},
+		Log:                     logr.Discard(),
+		Scheme:                  scheme,
+		Recorder:                mgr.GetEventRecorderFor("github-actions-runner-controller"),
+		PushRegistryHost:        "registry.example.com",
+		PullRegistryHost:        "127.0.0.1:5000",
+		ExporterImage:           "exporter",
+		KanikoImage:             "kaniko",
+		WorkspaceImage:          "busybox",
+		BinaryVersion:           "0.0.0",
+		RunnerVersion:           "0.0.0",
+		Tracer:                  trace.NewNoopTracerProvider().Tracer(""),
+		GitHubAppClientId:       "Iv1.0123456789abcdef",
+		GitHubAppInstallationId: envtestInstallationId,
+		GitHubAppPrivateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		// Requests to GitHub API are redirected to the stub
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				request = request.Clone(request.Context())
+				request.URL.Scheme = stub.Scheme
+				request.URL.Host = stub.Host
+				return http.DefaultTransport.RoundTrip(request)
+			}),
+		},
+	}
+	if err := r.SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	go func() {
+		done <- mgr.Start(ctx)
+	}()
+	return nil
+}
+
+// newStubGitHubAPI serves the endpoints of GitHub API called to verify GitHub App and to issue installation tokens
+func newStubGitHubAPI() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /app", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	mux.HandleFunc(fmt.Sprintf("POST /app/installations/%s/access_tokens", envtestInstallationId), func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"token":      envtestToken,
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	})
+	return mux
+}
+
+// conflictingClient fails the next server-side apply of a deployment in the namespaces registered by tests,
+// as if the deployment were modified by another client at the same time
+type conflictingClient struct {
+	client.Client
+}
+
+func (c *conflictingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*appsV1.Deployment); ok && (&client.PatchOptions{}).ApplyOptions(opts).DryRun == nil {
+		if _, ok := envtestConflictNamespaces.LoadAndDelete(obj.GetNamespace()); ok {
+			return apierrors.NewConflict(appsV1.Resource("deployments"), obj.GetName(), fmt.Errorf("the object has been modified"))
+		}
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// newEnvtestNamespace creates a namespace per test, so that tests sharing the controller do not interfere with each other
+func newEnvtestNamespace(t *testing.T) string {
+	t.Helper()
+
+	if envtestClient == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	namespace := &v1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			GenerateName: "test-",
+		},
+	}
+	if err := envtestClient.Create(context.Background(), namespace); err != nil {
+		t.Fatal(err)
+	}
+	// Namespaces are never removed by envtest since it runs no namespace controller, so this is only for tidiness
+	t.Cleanup(func() {
+		_ = envtestClient.Delete(context.Background(), namespace)
+	})
+	return namespace.Name
+}
+
+// eventually polls the condition until it succeeds, and fails the test with its last error after envtestTimeout
+func eventually(t *testing.T, condition func(ctx context.Context) error) {
+	t.Helper()
+
+	var last error
+	if err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, envtestTimeout, true, func(ctx context.Context) (bool, error) {
+		last = condition(ctx)
+		return last == nil, nil
+	}); err != nil {
+		t.Fatalf("condition is not met within %s: %v", envtestTimeout, last)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// TokenRefresher renews token secrets issued by the GitHub App of the controller before their expiry,
+// independently of RunnerReconciler so that renewal is not delayed by reconciliation of deployments.
+// Token secrets are created by RunnerReconciler, and the ones not created yet are left to it.
+type TokenRefresher struct {
+	client.Client
+	Log        logr.Logger
+	Reconciler *RunnerReconciler
+}
+
+func (r *TokenRefresher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("runner", req.NamespacedName)
+
+	runner := &garV1.Runner{}
+	if err := r.Get(ctx, req.NamespacedName, runner); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !runner.DeletionTimestamp.IsZero() || runner.Annotations[pausedAnnotation] == "true" || !r.Reconciler.selectsRunner(runner) {
+		return ctrl.Result{}, nil
+	}
+	// The status is patched on the runner as stored, not on the one with RunnerClass applied
+	latest := runner.DeepCopy()
+
+	// RunnerClass may select the installation of the GitHub App
+	runnerClass, err := runner.MatchRunnerClass(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if runnerClass != nil {
+		if err := runner.ApplyRunnerClass(runnerClass); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !r.Reconciler.usesInstallationToken(runner) {
+		return ctrl.Result{}, nil
+	}
+
+	// Token secrets not created yet are checked again later in case their creation is not observed,
+	// e.g. when RunnerReconciler fails to create them
+	var tokenSecret coreV1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Name: tokenSecretName(runner), Namespace: runner.Namespace}, &tokenSecret); apierrors.IsNotFound(err) {
+		return ctrl.Result{RequeueAfter: tokenRenewalRetryInterval}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	// Secrets with a broken expiry are renewed immediately
+	if expire, err := time.Parse(time.RFC3339, tokenSecret.Annotations[expiresAtAnnotation]); err == nil {
+		if until := time.Until(expire) - r.Reconciler.tokenRefreshBuffer(); until > 0 {
+			return ctrl.Result{RequeueAfter: until}, nil
+		}
+	}
+
+	expectedTokenSecret, err := r.Reconciler.createTokenSecret(ctx, runner)
+	if err != nil {
+		return r.Reconciler.tokenRenewalFailed(runner, logger, err), nil
+	}
+	if err := r.Reconciler.applyTokenSecret(ctx, runner, &tokenSecret, expectedTokenSecret, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	expire, err := time.Parse(time.RFC3339, expectedTokenSecret.Annotations[expiresAtAnnotation])
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Reconciler.TokenSecondsUntilExpiry.WithLabelValues(req.Name, req.Namespace).Set(time.Until(expire).Seconds())
+	if latest.Status.TokenExpiresAt == nil || !latest.Status.TokenExpiresAt.Time.Equal(expire) {
+		patch := client.MergeFrom(latest.DeepCopy())
+		latest.Status.TokenExpiresAt = &metaV1.Time{Time: expire}
+		if err := r.Status().Patch(ctx, latest, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: time.Until(expire) - r.Reconciler.tokenRefreshBuffer()}, nil
+}
+
+// SetupWithManager watches token secrets as well so that the renewal is scheduled once RunnerReconciler creates them
+func (r *TokenRefresher) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("token-refresher").
+		For(&garV1.Runner{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(
+			&coreV1.Secret{},
+			tokenSecretOwnersHandler(mgr.GetScheme(), mgr.GetRESTMapper()),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isTokenSecret)),
+		).
+		Complete(r)
+}
+
+// tokenSecretOwnersHandler enqueues all runners owning token secrets rather than only the controller,
+// since shared token secrets are owned by the runners sharing them without a controller
+func tokenSecretOwnersHandler(scheme *runtime.Scheme, mapper meta.RESTMapper) handler.EventHandler {
+	return handler.EnqueueRequestForOwner(scheme, mapper, &garV1.Runner{})
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestTokenRefresherReconcile(t *testing.T) {
+	type in struct {
+		tokenSecretKeyRef *v1.SecretKeySelector
+		sharedTokenSecret bool
+		expiresIn         time.Duration
+		withoutSecret     bool
+	}
+
+	type want struct {
+		token     string
+		refreshed bool
+		requeue   bool
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"not expiring",
+			in{
+				nil,
+				false,
+				time.Hour,
+				false,
+			},
+			want{
+				"current",
+				false,
+				true,
+			},
+		},
+		{
+			"expiring",
+			in{
+				nil,
+				false,
+				30 * time.Second,
+				false,
+			},
+			want{
+				"renewed",
+				true,
+				true,
+			},
+		},
+		{
+			"expired",
+			in{
+				nil,
+				false,
+				-time.Minute,
+				false,
+			},
+			want{
+				"renewed",
+				true,
+				true,
+			},
+		},
+		{
+			"not created yet",
+			in{
+				nil,
+				false,
+				0,
+				true,
+			},
+			want{
+				"",
+				false,
+				true,
+			},
+		},
+		{
+			"token secret key ref",
+			in{
+				&v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: "credentials",
+					},
+					Key: "TOKEN",
+				},
+				false,
+				30 * time.Second,
+				false,
+			},
+			want{
+				"current",
+				false,
+				false,
+			},
+		},
+		{
+			"shared token secret expiring",
+			in{
+				nil,
+				true,
+				30 * time.Second,
+				false,
+			},
+			want{
+				"renewed",
+				true,
+				true,
+			},
+		},
+		{
+			"shared token secret not created yet",
+			in{
+				nil,
+				true,
+				0,
+				true,
+			},
+			want{
+				"",
+				false,
+				true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:       "example",
+					Namespace:  "default",
+					Generation: 1,
+				},
+				Spec: garV1.RunnerSpec{
+					Image:             "ubuntu:22.04",
+					Repository:        "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: tt.in.tokenSecretKeyRef,
+					SharedTokenSecret: tt.in.sharedTokenSecret,
+				},
+			}
+			objects := []client.Object{runner}
+			if !tt.in.withoutSecret {
+				objects = append(objects, &v1.Secret{
+					ObjectMeta: metaV1.ObjectMeta{
+						Name:      tokenSecretName(runner),
+						Namespace: runner.Namespace,
+						Annotations: map[string]string{
+							expiresAtAnnotation: time.Now().Add(tt.in.expiresIn).Format(time.RFC3339),
+						},
+					},
+					Data: map[string][]byte{
+						"GITHUB_TOKEN": []byte("current"),
+					},
+				})
+			}
+			reconciler := newTestRunnerReconciler(t, objects...)
+			reconciler.GitHubAppClientId = "Iv1.0123456789abcdef"
+			reconciler.GitHubAppInstallationId = "1"
+			// GitHub App private key is invalid, so tokens are renewed only from the cache
+			reconciler.GitHubAppPrivateKey = "invalid"
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+			reconciler.tokenCache.Store("1:"+runner.Spec.Repository, cachedToken{
+				token:     "renewed",
+				expiresAt: expiresAt,
+			})
+			r := &TokenRefresher{
+				Client:     reconciler.Client,
+				Log:        logr.Discard(),
+				Reconciler: reconciler,
+			}
+			ctx := context.Background()
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      runner.Name,
+					Namespace: runner.Namespace,
+				},
+			}
+
+			maxRequeueAfter := time.Until(expiresAt) - reconciler.tokenRefreshBuffer()
+			result, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := result.RequeueAfter > 0; got != tt.want.requeue {
+				t.Errorf("requeue = %v, want %v", got, tt.want.requeue)
+			}
+
+			var secret v1.Secret
+			if err := r.Get(ctx, client.ObjectKey{Name: tokenSecretName(runner), Namespace: runner.Namespace}, &secret); err == nil {
+				token := string(secret.Data["GITHUB_TOKEN"])
+				if s, ok := secret.StringData["GITHUB_TOKEN"]; ok {
+					token = s
+				}
+				if token != tt.want.token {
+					t.Errorf("token = %q, want %q", token, tt.want.token)
+				}
+			} else if tt.want.token != "" {
+				t.Fatal(err)
+			}
+
+			var got garV1.Runner
+			if err := r.Get(ctx, req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want.refreshed {
+				if got.Status.TokenExpiresAt != nil {
+					t.Errorf("status.tokenExpiresAt = %v, want nil without renewal", got.Status.TokenExpiresAt)
+				}
+				return
+			}
+			if got.Status.TokenExpiresAt == nil || !got.Status.TokenExpiresAt.Time.Equal(expiresAt) {
+				t.Errorf("status.tokenExpiresAt = %v, want %v", got.Status.TokenExpiresAt, expiresAt)
+			}
+			if result.RequeueAfter <= 0 || result.RequeueAfter > maxRequeueAfter {
+				t.Errorf("requeueAfter = %s, want up to %s", result.RequeueAfter, maxRequeueAfter)
+			}
+		})
+	}
+}
+
+func TestTokenSecretOwnersHandler(t *testing.T) {
+	reconciler := newTestRunnerReconciler(t)
+	var runners []*garV1.Runner
+	for _, name := range []string{"first", "second"} {
+		runners = append(runners, &garV1.Runner{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name),
+			},
+			Spec: garV1.RunnerSpec{
+				Image:             "ubuntu:22.04",
+				Repository:        "kaidotdev/github-actions-runner-controller",
+				SharedTokenSecret: true,
+			},
+		})
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      tokenSecretName(runners[0]),
+			Namespace: "default",
+		},
+	}
+	for _, runner := range runners {
+		if err := reconciler.setTokenSecretOwner(runner, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The mapper of the fake client does not know custom resources
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{garV1.GroupVersion})
+	mapper.Add(garV1.GroupVersion.WithKind("Runner"), meta.RESTScopeNamespace)
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	tokenSecretOwnersHandler(reconciler.Scheme, mapper).Create(context.Background(), event.CreateEvent{Object: secret}, queue)
+
+	var got []string
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		got = append(got, item.(reconcile.Request).Name)
+		queue.Done(item)
+	}
+	slices.Sort(got)
+	if want := []string{"first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("enqueued = %v, want all runners sharing the token secret %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// Finished warm-up jobs are kept for a while to inspect their logs
+	warmupJobTTLSecondsAfterFinished = 3600
+	warmupJobBackoffLimit            = 1
+)
+
+// reconcileWarmup runs the warm-up job before the deployment of a new runner is created,
+// which builds the runner image without pushing it so that the layer cache in the registry is filled before runner pods start.
+// It returns whether the deployment can be created, i.e. the job has finished or the warm-up is not needed.
+// A failed warm-up is only recorded since runner pods can build the image without the cache.
+func (r *RunnerReconciler) reconcileWarmup(ctx context.Context, runner *garV1.Runner, logger logr.Logger) (bool, error) {
+	// The build job of the build namespace fills the cache by itself
+	if runner.Spec.SkipWarmup || runner.Spec.SkipBuild || runner.Spec.PreBuiltImage != "" || runner.Spec.BuildNamespace != "" {
+		return true, nil
+	}
+
+	var job batchV1.Job
+	if err := r.Get(ctx, client.ObjectKey{Name: runner.Name + "-warmup", Namespace: runner.Namespace}, &job); apierrors.IsNotFound(err) {
+		job = *r.buildWarmupJob(runner)
+		if err := controllerutil.SetControllerReference(runner, &job, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, &job); err != nil {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonCreateFailed, "Failed to create warm-up job %q: %v", job.Name, err)
+			return false, err
+		}
+		r.recordChange(runner, logger, EventReasonCreated, fmt.Sprintf("Created warm-up job: %q", job.Name), "")
+		logger.V(1).Info("create", "job", job)
+		// The job is never created in dry-run mode, so the deployment is proposed without waiting for it
+		return r.DryRun, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchV1.JobFailed && condition.Status == coreV1.ConditionTrue {
+			r.Recorder.Eventf(runner, coreV1.EventTypeWarning, EventReasonWarmupFailed, "Warm-up job %q failed, and runner pods build the image without cache: %s", job.Name, condition.Message)
+			return true, nil
+		}
+	}
+	logger.Info("wait for warm-up job to complete", "job", job.Name)
+	return false, nil
+}
+
+// buildWarmupJob builds the job running the builder container with --no-push after the init containers of the runner pod,
+// since kaniko pushes cached layers to the registry regardless of the destination.
+func (r *RunnerReconciler) buildWarmupJob(runner *garV1.Runner) *batchV1.Job {
+	template := r.buildDeployment(runner).Spec.Template
+
+	builder := r.buildBuilderContainer(runner)
+	builder.Args = append(builder.Args, "--no-push")
+	var initContainers []coreV1.Container
+	for _, container := range template.Spec.InitContainers {
+		if container.Name != builder.Name {
+			initContainers = append(initContainers, container)
+		}
+	}
+	template.Spec.InitContainers = initContainers
+	// Sidecar containers are omitted, otherwise the job never completes
+	template.Spec.Containers = []coreV1.Container{builder}
+	template.Spec.RestartPolicy = coreV1.RestartPolicyNever
+	template.Spec.Affinity = nil
+
+	// Pods of the job must not be selected as runner pods
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels["app"] = runner.Name + "-warmup"
+	template.Labels = labels
+
+	return &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        runner.Name + "-warmup",
+			Namespace:   runner.Namespace,
+			Labels:      buildManagedResourceLabels(runner),
+			Annotations: mergeMetadata(runner.Spec.ManagedResourceAnnotations, nil),
+		},
+		Spec: batchV1.JobSpec{
+			BackoffLimit:            func(i int32) *int32 { return &i }(warmupJobBackoffLimit),
+			TTLSecondsAfterFinished: func(i int32) *int32 { return &i }(warmupJobTTLSecondsAfterFinished),
+			ActiveDeadlineSeconds:   buildActiveDeadlineSeconds(runner.Spec.BuilderContainerSpec.BuildTimeout, warmupJobBackoffLimit),
+			Template:                template,
+		},
+	}
+}
+
+// jobFinishedPredicate passes status changes of jobs completing or failing
+func jobFinishedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldJob, ok := e.ObjectOld.(*batchV1.Job)
+			if !ok {
+				return false
+			}
+			newJob, ok := e.ObjectNew.(*batchV1.Job)
+			if !ok {
+				return false
+			}
+			return oldJob.Status.Succeeded != newJob.Status.Succeeded ||
+				len(oldJob.Status.Conditions) != len(newJob.Status.Conditions)
+		},
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestRunnerReconcilerReconcileWarmup(t *testing.T) {
+	type in struct {
+		status batchV1.JobStatus
+	}
+
+	type want struct {
+		event string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"succeeded",
+			in{
+				batchV1.JobStatus{
+					Succeeded: 1,
+				},
+			},
+			want{
+				"",
+			},
+		},
+		{
+			"failed",
+			in{
+				batchV1.JobStatus{
+					Failed: 2,
+					Conditions: []batchV1.JobCondition{
+						{
+							Type:    batchV1.JobFailed,
+							Status:  v1.ConditionTrue,
+							Message: "Job has reached the specified backoff limit",
+						},
+					},
+				},
+			},
+			want{
+				EventReasonWarmupFailed,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Image:      "ubuntu:22.04",
+					Repository: "kaidotdev/github-actions-runner-controller",
+					TokenSecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "credentials",
+						},
+						Key: "TOKEN",
+					},
+				},
+			}
+			r := newTestRunnerReconciler(t, runner)
+			recorder := record.NewFakeRecorder(100)
+			r.Recorder = recorder
+			ctx := context.Background()
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      runner.Name,
+					Namespace: runner.Namespace,
+				},
+			}
+			deploymentKey := types.NamespacedName{Name: runner.Name + "-runner", Namespace: runner.Namespace}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			var job batchV1.Job
+			if err := r.Get(ctx, types.NamespacedName{Name: runner.Name + "-warmup", Namespace: runner.Namespace}, &job); err != nil {
+				t.Fatal(err)
+			}
+			containers := job.Spec.Template.Spec.Containers
+			if len(containers) != 1 || containers[0].Name != "kaniko" || containers[0].Args[len(containers[0].Args)-1] != "--no-push" {
+				t.Errorf("containers = %v, want kaniko with --no-push", containers)
+			}
+			for _, container := range job.Spec.Template.Spec.InitContainers {
+				if container.Name == "kaniko" {
+					t.Error("kaniko must not run as an init container of the warm-up job")
+				}
+			}
+			if got := job.Spec.Template.Labels["app"]; got != runner.Name+"-warmup" {
+				t.Errorf("app label = %q, want %q not to be selected as runner pods", got, runner.Name+"-warmup")
+			}
+			if err := r.Get(ctx, deploymentKey, &appsV1.Deployment{}); !apierrors.IsNotFound(err) {
+				t.Errorf("deployment must not be created before the warm-up job finishes: %v", err)
+			}
+
+			job.Status = tt.in.status
+			if err := r.Status().Update(ctx, &job); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Get(ctx, deploymentKey, &appsV1.Deployment{}); err != nil {
+				t.Errorf("deployment must be created after the warm-up job finishes: %v", err)
+			}
+
+			var recorded bool
+			for len(recorder.Events) > 0 {
+				if event := <-recorder.Events; strings.Contains(event, EventReasonWarmupFailed) {
+					recorded = true
+				}
+			}
+			if recorded != (tt.want.event != "") {
+				t.Errorf("%s event recorded = %v, want %v", EventReasonWarmupFailed, recorded, tt.want.event != "")
+			}
+		})
+	}
+}
+
+func TestRunnerReconcilerReconcileWarmupSkipped(t *testing.T) {
+	type in struct {
+		spec garV1.RunnerSpec
+	}
+
+	tests := []struct {
+		name string
+		in   in
+	}{
+		{
+			"skip warmup",
+			in{
+				garV1.RunnerSpec{
+					SkipWarmup: true,
+				},
+			},
+		},
+		{
+			"skip build",
+			in{
+				garV1.RunnerSpec{
+					SkipBuild: true,
+				},
+			},
+		},
+		{
+			"pre-built image",
+			in{
+				garV1.RunnerSpec{
+					PreBuiltImage: "ghcr.io/kaidotdev/runner:v1",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: tt.in.spec,
+			}
+			r := newTestRunnerReconciler(t, runner)
+
+			ready, err := r.reconcileWarmup(context.Background(), runner, r.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ready {
+				t.Error("reconcileWarmup() = false, want true without warm-up")
+			}
+			var jobs batchV1.JobList
+			if err := r.List(context.Background(), &jobs); err != nil {
+				t.Fatal(err)
+			}
+			if len(jobs.Items) != 0 {
+				t.Errorf("jobs = %d, want 0", len(jobs.Items))
+			}
+		})
+	}
+}
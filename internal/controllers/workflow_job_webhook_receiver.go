@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WorkflowJobWebhookReceiver receives workflow_job webhook events of GitHub
+// and reconciles runners of the repository immediately when a job is queued.
+// Runners are annotated with the queued job to trigger reconciliation,
+// so that events received by any replica reach the leader.
+type WorkflowJobWebhookReceiver struct {
+	client.Client
+	Log logr.Logger
+	// Secret of the webhook used to validate signatures of events
+	WebhookSecret string
+	// Address the receiver binds to
+	BindAddress string
+}
+
+type workflowJobEvent struct {
+	Action      string `json:"action"`
+	WorkflowJob struct {
+		ID int64 `json:"id"`
+	} `json:"workflow_job"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func (s *WorkflowJobWebhookReceiver) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", s.handleEvent)
+
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.Log.Error(err, "failed to shutdown workflow job webhook receiver")
+		}
+	}()
+
+	s.Log.Info("starting workflow job webhook receiver", "address", s.BindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection makes all replicas receive events since they only annotate runners
+func (s *WorkflowJobWebhookReceiver) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *WorkflowJobWebhookReceiver) handleEvent(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !validateSignature(body, req.Header.Get("X-Hub-Signature-256"), s.WebhookSecret) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if req.Header.Get("X-GitHub-Event") != "workflow_job" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var e workflowJobEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		http.Error(w, "failed to decode event", http.StatusBadRequest)
+		return
+	}
+	if e.Action != "queued" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := s.annotateRunners(req.Context(), e.Repository.FullName, strconv.FormatInt(e.WorkflowJob.ID, 10)); err != nil {
+		s.Log.Error(err, "failed to annotate runners", "repository", e.Repository.FullName)
+		http.Error(w, "failed to annotate runners", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WorkflowJobWebhookReceiver) annotateRunners(ctx context.Context, repository string, jobId string) error {
+	var runners garV1.RunnerList
+	if err := s.List(ctx, &runners); err != nil {
+		return err
+	}
+	for i := range runners.Items {
+		runner := &runners.Items[i]
+		if !strings.EqualFold(runner.Spec.Repository, repository) {
+			continue
+		}
+		patch := client.MergeFrom(runner.DeepCopy())
+		if runner.Annotations == nil {
+			runner.Annotations = map[string]string{}
+		}
+		runner.Annotations[queuedJobAnnotation] = jobId
+		if err := s.Patch(ctx, runner, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSignature validates X-Hub-Signature-256 header, which is the HMAC-SHA256 of body keyed by the webhook secret
+func validateSignature(body []byte, signature string, secret string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hmac.Equal(got, h.Sum(nil))
+}
+
+func (s *WorkflowJobWebhookReceiver) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(s)
+}
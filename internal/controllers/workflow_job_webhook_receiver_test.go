@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	garV1 "github-actions-runner-controller/api/v1"
+
+	"github.com/go-logr/logr"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestWorkflowJobWebhookReceiverHandleEvent(t *testing.T) {
+	sign := func(body string) string {
+		h := hmac.New(sha256.New, []byte("secret"))
+		h.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(h.Sum(nil))
+	}
+	queued := `{"action":"queued","workflow_job":{"id":42},"repository":{"full_name":"kaidotdev/github-actions-runner-controller"}}`
+
+	type in struct {
+		event     string
+		body      string
+		signature string
+	}
+
+	type want struct {
+		statusCode int
+		annotation string
+	}
+
+	tests := []struct {
+		name string
+		in   in
+		want want
+	}{
+		{
+			"queued",
+			in{
+				"workflow_job",
+				queued,
+				sign(queued),
+			},
+			want{
+				http.StatusNoContent,
+				"42",
+			},
+		},
+		{
+			"completed",
+			in{
+				"workflow_job",
+				strings.Replace(queued, "queued", "completed", 1),
+				sign(strings.Replace(queued, "queued", "completed", 1)),
+			},
+			want{
+				http.StatusNoContent,
+				"",
+			},
+		},
+		{
+			"other repository",
+			in{
+				"workflow_job",
+				strings.Replace(queued, "kaidotdev/", "example/", 1),
+				sign(strings.Replace(queued, "kaidotdev/", "example/", 1)),
+			},
+			want{
+				http.StatusNoContent,
+				"",
+			},
+		},
+		{
+			"ping",
+			in{
+				"ping",
+				`{"zen":"Keep it logically awesome."}`,
+				sign(`{"zen":"Keep it logically awesome."}`),
+			},
+			want{
+				http.StatusNoContent,
+				"",
+			},
+		},
+		{
+			"invalid signature",
+			in{
+				"workflow_job",
+				queued,
+				sign(queued + " "),
+			},
+			want{
+				http.StatusUnauthorized,
+				"",
+			},
+		},
+		{
+			"missing signature",
+			in{
+				"workflow_job",
+				queued,
+				"",
+			},
+			want{
+				http.StatusUnauthorized,
+				"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &garV1.Runner{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: garV1.RunnerSpec{
+					Repository: "kaidotdev/github-actions-runner-controller",
+				},
+			}
+			s := &WorkflowJobWebhookReceiver{
+				Client:        newTestRunnerReconciler(t, runner).Client,
+				Log:           logr.Discard(),
+				WebhookSecret: "secret",
+			}
+
+			request := httptest.NewRequest("POST", "/", strings.NewReader(tt.in.body))
+			request.Header.Set("X-GitHub-Event", tt.in.event)
+			request.Header.Set("X-Hub-Signature-256", tt.in.signature)
+			w := httptest.NewRecorder()
+			s.handleEvent(w, request)
+
+			if w.Code != tt.want.statusCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.want.statusCode)
+			}
+			var got garV1.Runner
+			if err := s.Get(context.Background(), client.ObjectKeyFromObject(runner), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got := got.Annotations[queuedJobAnnotation]; got != tt.want.annotation {
+				t.Errorf("annotation = %q, want %q", got, tt.want.annotation)
+			}
+		})
+	}
+}
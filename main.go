@@ -1,21 +1,37 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	garV1 "github-actions-runner-controller/api/v1"
 	"github-actions-runner-controller/internal/controllers"
 	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -38,34 +54,110 @@ func main() {
 	var enableHTTP2 bool
 	var probeAddr string
 	var enableLeaderElection bool
+	var leaderElectionNamespace string
+	var watchNamespace string
+	var disableResourceQuotaCheck bool
+	var disablePodAntiAffinity bool
+	var debug bool
 	var pushRegistryHost string
 	var pullRegistryHost string
 	var enableRunnerMetrics bool
 	var exporterImage string
 	var githubAppClientId string
 	var githubAppInstallationId string
+	var discoverInstallationId bool
 	var githubAppPrivateKey string
+	var privateKeyProviderType string
+	var privateKeySecret string
+	var privateKeySecretKey string
+	var privateKeyRef string
 	var kanikoImage string
+	var workspaceImage string
 	var binaryVersion string
+	var binaryArch string
+	var dryRun bool
+	var traceAPILatency bool
+	var disableAutoUpgrade bool
 	var runnerVersion string
 	var disableupdate bool
+	var enableWebhook bool
+	var enableTracing bool
+	var maxConcurrentReconciles int
+	var tokenRefreshBuffer time.Duration
+	var conflictBackoffMax time.Duration
+	var circuitBreakerThreshold int
+	var circuitBreakerTimeout time.Duration
+	var configMap string
+	var githubAPIReadinessThreshold time.Duration
+	var maxReconcileAge time.Duration
+	var reconcileTimeout time.Duration
+	var jobAuditLogAddr string
+	var webhookTLSSecret string
+	var workflowJobWebhookAddr string
+	var workflowJobWebhookSecret string
+	var jobAuditLogURL string
+	var defaultRunnerResources string
+	var defaultBuilderResources string
+	var globalRunnerEnv string
+	var runnerSelector string
+	var encryptionAnnotations string
+	var allowedBaseImages string
+	var defaultSchedulerName string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", false, "If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false, "If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", "0.0.0.0:8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "", "Namespace where the leader election lease is created. Defaults to the namespace of the controller when running in cluster.")
+	flag.StringVar(&watchNamespace, "watch-namespace", "", "Namespace whose runners are managed by the controller. All namespaces are watched when empty.")
 	flag.StringVar(&pushRegistryHost, "push-registry-host", "ghcr.io/kaidotdev/github-actions-runner-controller", "Host of Docker Registry used as push destination.")
 	flag.StringVar(&pullRegistryHost, "pull-registry-host", "ghcr.io/kaidotdev/github-actions-runner-controller", "Host of Docker Registry used as pull source.")
 	flag.BoolVar(&enableRunnerMetrics, "enable-runner-metrics", false, "Enable to expose runner metrics using prometheus exporter.")
 	flag.StringVar(&exporterImage, "exporter-image", "ghcr.io/kaidotdev/github-actions-exporter/github-actions-exporter:v0.1.1", "Docker Image of exporter used by exporter container")
 	flag.StringVar(&githubAppClientId, "github-app-client-id", "", "GitHub App Client ID")
 	flag.StringVar(&githubAppInstallationId, "github-app-installation-id", "", "GitHub App Installation ID")
+	flag.BoolVar(&discoverInstallationId, "discover-installation-id", false, "If set the installation of GitHub App is discovered per repository of runners instead of --github-app-installation-id")
 	flag.StringVar(&githubAppPrivateKey, "github-app-private-key", "", "GitHub App Private Key")
+	flag.StringVar(&privateKeyProviderType, "github-app-private-key-provider", controllers.PrivateKeyProviderTypeStatic, "Provider of GitHub App Private Key, one of static, aws-secrets-manager, gcp-secret-manager and azure-key-vault. static uses --github-app-private-key.")
+	flag.StringVar(&privateKeyRef, "github-app-private-key-ref", "", "Reference to GitHub App Private Key in the provider: the secret ID for aws-secrets-manager, the secret version name for gcp-secret-manager, and the secret URL for azure-key-vault.")
+	flag.StringVar(&privateKeySecret, "github-app-private-key-secret", "", "Secret in the form of <namespace>/<name> holding GitHub App Private Key, which takes precedence over --github-app-private-key and --github-app-private-key-provider. Tokens are renewed on its changes.")
+	flag.StringVar(&privateKeySecretKey, "github-app-private-key-secret-key", "private-key", "Key of GitHub App Private Key in --github-app-private-key-secret")
 	flag.StringVar(&kanikoImage, "kaniko-image", "gcr.io/kaniko-project/executor:v1.23.0", "Docker Image of kaniko used by builder container")
+	flag.StringVar(&workspaceImage, "workspace-image", "busybox:1.36", "Docker Image used to write Dockerfile into workspace persistent volume claim")
 	flag.StringVar(&binaryVersion, "binary-version", "0.4.5", "Version of own runner binary")
+	flag.StringVar(&binaryArch, "binary-arch", "amd64", "Architecture of own runner binary, which is overridden by binaryArch of Runner")
 	flag.StringVar(&runnerVersion, "runner-version", "2.321.0", "Version of GitHub Actions runner")
+	flag.BoolVar(&disableAutoUpgrade, "disable-auto-upgrade", false, "Disable upgrading runners automatically to the latest released version of GitHub Actions runner, which overrides --runner-version")
 	flag.BoolVar(&disableupdate, "disableupdate", false, "Disable self-hosted runner automatic update to the latest released version")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false, "Enable admission webhooks for Runner. TLS certificate for webhook server is required.")
+	flag.StringVar(&webhookTLSSecret, "webhook-tls-secret", "", "TLS secret in the form of <namespace>/<name> served by the webhook server instead of the certificate files, e.g. issued by cert-manager. The certificate is reloaded on its changes without restart.")
+	flag.BoolVar(&enableTracing, "enable-tracing", false, "Enable OpenTelemetry tracing. Exporter is configured by OTEL_EXPORTER_OTLP_* environment variables.")
+	flag.DurationVar(&tokenRefreshBuffer, "token-refresh-buffer", time.Minute, "Duration before expiry at which GitHub App installation access tokens are renewed. Tokens are cached and shared by runners until then.")
+	flag.DurationVar(&conflictBackoffMax, "conflict-backoff-max", 30*time.Second, "Maximum delay of requeue with exponential backoff on conflicts at update.")
+	flag.BoolVar(&debug, "debug", false, "Log diffs between expected and actual resources owned by runners on every reconciliation, e.g. to diagnose deployments flapping.")
+	flag.BoolVar(&traceAPILatency, "trace-api-latency", false, "Log every call to the API server made by reconciliation of runners with its latency, e.g. to find the bottleneck of slow reconciliations.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only propose changes of runner resources by events and logs without applying them.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "Maximum number of runners reconciled concurrently. Increasing it is safe, but GitHub API rate limits may become a bottleneck.")
+	flag.IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 5, "Number of consecutive failures to reach GitHub API at which calls to it are stopped.")
+	flag.DurationVar(&circuitBreakerTimeout, "circuit-breaker-timeout", 5*time.Minute, "Duration for which calls to GitHub API are stopped after consecutive failures.")
+	flag.DurationVar(&githubAPIReadinessThreshold, "github-api-readiness-threshold", 15*time.Minute, "Duration for which calls to GitHub API can keep failing before the controller becomes unready. Disabled when zero.")
+	flag.DurationVar(&maxReconcileAge, "max-reconcile-age", 10*time.Minute, "Maximum duration of a reconciliation before the controller is regarded as stuck and restarted by the liveness check. Disabled when zero.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 5*time.Minute, "Maximum duration of a reconciliation, after which requests to the API server and GitHub API are cancelled. Disabled when zero.")
+	flag.StringVar(&configMap, "config-map", "", "Config map in the form of <namespace>/<name> whose keys override flags of the same names without restart, e.g. push-registry-host and exporter-image.")
+	flag.StringVar(&jobAuditLogAddr, "job-audit-log-bind-address", "", "The address the job audit log endpoint binds to. Job audit log is disabled when empty.")
+	flag.StringVar(&jobAuditLogURL, "job-audit-log-url", "", "Base URL of the job audit log endpoint reachable from runner pods, e.g. http://github-actions-runner-controller.github-actions-runner-controller.svc:8082")
+	flag.StringVar(&workflowJobWebhookAddr, "workflow-job-webhook-bind-address", "", "The address the receiver of workflow_job webhook events of GitHub binds to. The receiver is disabled when empty.")
+	flag.StringVar(&workflowJobWebhookSecret, "workflow-job-webhook-secret", "", "Secret of the webhook used to validate signatures of workflow_job events")
+	flag.BoolVar(&disablePodAntiAffinity, "disable-pod-anti-affinity", false, "Disable the anti-affinity spreading runner pods across nodes for all runners, e.g. in single-node development clusters.")
+	flag.BoolVar(&disableResourceQuotaCheck, "disable-resource-quota-check", false, "Disable checking that runner deployments fit in resource quotas of the namespace before creating them.")
+	flag.StringVar(&defaultRunnerResources, "default-runner-resources", "", `Resources of runner container in JSON used when limits or requests are not specified by Runner (e.g. {"requests":{"cpu":"1","memory":"2Gi"}})`)
+	flag.StringVar(&defaultBuilderResources, "default-builder-resources", "", "Resources of builder container in JSON used when limits or requests are not specified by Runner")
+	flag.StringVar(&defaultSchedulerName, "default-scheduler-name", "", "Scheduler of runner pods used when schedulerName is not specified by Runner. Defaults to the default scheduler of Kubernetes.")
+	flag.StringVar(&globalRunnerEnv, "global-runner-env", "", `Environment variables in JSON injected into all runner containers, which are overridden by the ones of Runner with the same names (e.g. [{"name":"DD_AGENT_HOST","valueFrom":{"fieldRef":{"fieldPath":"status.hostIP"}}}])`)
+	flag.StringVar(&encryptionAnnotations, "encryption-annotations", "", `Annotations in JSON added to all secrets managed by the controller, e.g. to select the key of a KMS provider encrypting them at rest (e.g. {"example.com/kms-key":"github-tokens"})`)
+	flag.StringVar(&allowedBaseImages, "allowed-base-images", "", "Comma-separated patterns of images allowed for runners, where * does not match / (e.g. internal-registry.example.com/*,internal-registry.example.com/*/*). All images are allowed when empty.")
+	flag.StringVar(&runnerSelector, "runner-selector", "", "Label selector of runners managed by the controller, e.g. to shard runners among multiple controllers (e.g. team=platform). All runners are managed when empty.")
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	klog.InitFlags(flag.CommandLine)
@@ -93,46 +185,333 @@ func main() {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
 
+	var runnerResources coreV1.ResourceRequirements
+	if defaultRunnerResources != "" {
+		if err := json.Unmarshal([]byte(defaultRunnerResources), &runnerResources); err != nil {
+			entrypointLogger.Error(err, "unable to parse default runner resources")
+			os.Exit(1)
+		}
+	}
+	var builderResources coreV1.ResourceRequirements
+	if defaultBuilderResources != "" {
+		if err := json.Unmarshal([]byte(defaultBuilderResources), &builderResources); err != nil {
+			entrypointLogger.Error(err, "unable to parse default builder resources")
+			os.Exit(1)
+		}
+	}
+
+	var runnerEnv []coreV1.EnvVar
+	if globalRunnerEnv != "" {
+		if err := json.Unmarshal([]byte(globalRunnerEnv), &runnerEnv); err != nil {
+			entrypointLogger.Error(err, "unable to parse global runner env")
+			os.Exit(1)
+		}
+	}
+
+	var secretAnnotations map[string]string
+	if encryptionAnnotations != "" {
+		if err := json.Unmarshal([]byte(encryptionAnnotations), &secretAnnotations); err != nil {
+			entrypointLogger.Error(err, "unable to parse encryption annotations")
+			os.Exit(1)
+		}
+	}
+
+	var baseImages []string
+	if allowedBaseImages != "" {
+		for _, pattern := range strings.Split(allowedBaseImages, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				entrypointLogger.Error(err, "unable to parse allowed base images", "pattern", pattern)
+				os.Exit(1)
+			}
+			baseImages = append(baseImages, pattern)
+		}
+	}
+
+	var selector *metaV1.LabelSelector
+	if runnerSelector != "" {
+		s, err := metaV1.ParseToLabelSelector(runnerSelector)
+		if err != nil {
+			entrypointLogger.Error(err, "unable to parse runner selector")
+			os.Exit(1)
+		}
+		selector = s
+	}
+
+	// The static provider is not needed since the reconciler falls back to --github-app-private-key
+	var privateKeyProvider controllers.PrivateKeyProvider
+	if privateKeyProviderType != controllers.PrivateKeyProviderTypeStatic {
+		provider, err := controllers.NewPrivateKeyProvider(privateKeyProviderType, privateKeyRef, githubAppPrivateKey)
+		if err != nil {
+			entrypointLogger.Error(err, "unable to create private key provider")
+			os.Exit(1)
+		}
+		privateKeyProvider = provider
+	}
+
+	var configMapRef types.NamespacedName
+	if configMap != "" {
+		namespace, name, ok := strings.Cut(configMap, "/")
+		if !ok || namespace == "" || name == "" {
+			entrypointLogger.Error(nil, "config map must be in the form of <namespace>/<name>", "config-map", configMap)
+			os.Exit(1)
+		}
+		configMapRef = types.NamespacedName{
+			Namespace: namespace,
+			Name:      name,
+		}
+	}
+
+	var privateKeySecretRef types.NamespacedName
+	if privateKeySecret != "" {
+		namespace, name, ok := strings.Cut(privateKeySecret, "/")
+		if !ok || namespace == "" || name == "" {
+			entrypointLogger.Error(nil, "private key secret must be in the form of <namespace>/<name>", "github-app-private-key-secret", privateKeySecret)
+			os.Exit(1)
+		}
+		privateKeySecretRef = types.NamespacedName{
+			Namespace: namespace,
+			Name:      name,
+		}
+	}
+
+	var certificateReloader *controllers.CertificateReloader
+	if webhookTLSSecret != "" {
+		namespace, name, ok := strings.Cut(webhookTLSSecret, "/")
+		if !ok || namespace == "" || name == "" {
+			entrypointLogger.Error(nil, "webhook TLS secret must be in the form of <namespace>/<name>", "webhook-tls-secret", webhookTLSSecret)
+			os.Exit(1)
+		}
+		certificateReloader = &controllers.CertificateReloader{
+			Log: ctrl.Log.WithName("controllers").WithName("CertificateReloader"),
+			SecretRef: types.NamespacedName{
+				Namespace: namespace,
+				Name:      name,
+			},
+		}
+	}
+
+	if enableTracing {
+		exporter, err := otlptracegrpc.New(context.Background())
+		if err != nil {
+			entrypointLogger.Error(err, "unable to create trace exporter")
+			os.Exit(1)
+		}
+		tracerProvider := sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceName("github-actions-runner-controller"),
+			)),
+		)
+		defer func() {
+			if err := tracerProvider.Shutdown(context.Background()); err != nil {
+				entrypointLogger.Error(err, "unable to shutdown tracer provider")
+			}
+		}()
+		otel.SetTracerProvider(tracerProvider)
+	}
+
+	var cacheOptions cache.Options
+	if watchNamespace != "" {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{
+			watchNamespace: {},
+		}
+		// The config map and the secrets of the controller are typically placed in the namespace of the controller rather than the watched one
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{}
+		if configMapRef.Name != "" && configMapRef.Namespace != watchNamespace {
+			cacheOptions.ByObject[&coreV1.ConfigMap{}] = cache.ByObject{
+				Namespaces: map[string]cache.Config{
+					watchNamespace:         {},
+					configMapRef.Namespace: {},
+				},
+			}
+		}
+		secretNamespaces := map[string]cache.Config{
+			watchNamespace: {},
+		}
+		if privateKeySecretRef.Name != "" {
+			secretNamespaces[privateKeySecretRef.Namespace] = cache.Config{}
+		}
+		if certificateReloader != nil {
+			secretNamespaces[certificateReloader.SecretRef.Namespace] = cache.Config{}
+		}
+		if len(secretNamespaces) > 1 {
+			cacheOptions.ByObject[&coreV1.Secret{}] = cache.ByObject{
+				Namespaces: secretNamespaces,
+			}
+		}
+	}
+
+	webhookTLSOpts := tlsOpts
+	if certificateReloader != nil {
+		webhookTLSOpts = append(append([]func(*tls.Config){}, tlsOpts...), certificateReloader.ConfigureTLS)
+	}
 	webhookServer := webhook.NewServer(webhook.Options{
-		TLSOpts: tlsOpts,
+		TLSOpts: webhookTLSOpts,
 	})
 	m, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOptions,
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
 			TLSOpts:       tlsOpts,
 		},
-		WebhookServer:          webhookServer,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "github-actions-runner-controller",
+		WebhookServer:           webhookServer,
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        "github-actions-runner-controller",
+		LeaderElectionNamespace: leaderElectionNamespace,
 	})
 	if err != nil {
 		entrypointLogger.Error(err, "unable to create manager")
 		os.Exit(1)
 	}
 
-	if err := (&controllers.RunnerReconciler{
-		Client:                  m.GetClient(),
-		Scheme:                  m.GetScheme(),
-		Log:                     ctrl.Log.WithName("controllers").WithName("Runner"),
-		Recorder:                m.GetEventRecorderFor("github-actions-runner-controller"),
-		PushRegistryHost:        pushRegistryHost,
-		PullRegistryHost:        pullRegistryHost,
-		EnableRunnerMetrics:     enableRunnerMetrics,
-		ExporterImage:           exporterImage,
-		GitHubAppClientId:       githubAppClientId,
-		GitHubAppInstallationId: githubAppInstallationId,
-		GitHubAppPrivateKey:     githubAppPrivateKey, KanikoImage: kanikoImage,
-		BinaryVersion: binaryVersion,
-		RunnerVersion: runnerVersion,
-		Disableupdate: disableupdate,
-	}).SetupWithManager(m); err != nil {
+	runnerReconciler := &controllers.RunnerReconciler{
+		Client:                      m.GetClient(),
+		Scheme:                      m.GetScheme(),
+		Log:                         ctrl.Log.WithName("controllers").WithName("Runner"),
+		Recorder:                    m.GetEventRecorderFor("github-actions-runner-controller"),
+		PushRegistryHost:            pushRegistryHost,
+		PullRegistryHost:            pullRegistryHost,
+		EnableRunnerMetrics:         enableRunnerMetrics,
+		ExporterImage:               exporterImage,
+		GitHubAppClientId:           githubAppClientId,
+		GitHubAppInstallationId:     githubAppInstallationId,
+		DiscoverInstallationId:      discoverInstallationId,
+		GitHubAppPrivateKey:         githubAppPrivateKey,
+		PrivateKeyProvider:          privateKeyProvider,
+		PrivateKeySecretRef:         privateKeySecretRef,
+		PrivateKeySecretKey:         privateKeySecretKey,
+		KanikoImage:                 kanikoImage,
+		WorkspaceImage:              workspaceImage,
+		BinaryVersion:               binaryVersion,
+		BinaryArch:                  binaryArch,
+		Debug:                       debug,
+		DryRun:                      dryRun,
+		TraceAPILatency:             traceAPILatency,
+		RunnerVersion:               runnerVersion,
+		Disableupdate:               disableupdate,
+		Tracer:                      otel.Tracer("github-actions-runner-controller"),
+		MaxConcurrentReconciles:     maxConcurrentReconciles,
+		TokenRefreshBuffer:          tokenRefreshBuffer,
+		ConflictBackoffMax:          conflictBackoffMax,
+		CircuitBreakerThreshold:     circuitBreakerThreshold,
+		CircuitBreakerTimeout:       circuitBreakerTimeout,
+		ConfigMapRef:                configMapRef,
+		GitHubAPIReadinessThreshold: githubAPIReadinessThreshold,
+		MaxReconcileAge:             maxReconcileAge,
+		ReconcileTimeout:            reconcileTimeout,
+		JobAuditLogURL:              jobAuditLogURL,
+		DefaultRunnerResources:      runnerResources,
+		DefaultBuilderResources:     builderResources,
+		GlobalRunnerEnv:             runnerEnv,
+		RunnerSelector:              selector,
+		EncryptionAnnotations:       secretAnnotations,
+		AllowedBaseImages:           baseImages,
+		DefaultSchedulerName:        defaultSchedulerName,
+		DisableResourceQuotaCheck:   disableResourceQuotaCheck,
+		DisablePodAntiAffinity:      disablePodAntiAffinity,
+	}
+	if err := runnerReconciler.SetupWithManager(m); err != nil {
 		entrypointLogger.Error(err, "unable to create controller", "controller", "Runner")
 		os.Exit(1)
 	}
 
+	if err := (&controllers.TokenRefresher{
+		Client:     runnerReconciler.Client,
+		Log:        ctrl.Log.WithName("controllers").WithName("TokenRefresher"),
+		Reconciler: runnerReconciler,
+	}).SetupWithManager(m); err != nil {
+		entrypointLogger.Error(err, "unable to create controller", "controller", "TokenRefresher")
+		os.Exit(1)
+	}
+
+	if jobAuditLogAddr != "" {
+		if err := (&controllers.JobAuditLogServer{
+			Client:      m.GetClient(),
+			Log:         ctrl.Log.WithName("controllers").WithName("JobAuditLogServer"),
+			Recorder:    m.GetEventRecorderFor("github-actions-runner-controller"),
+			BindAddress: jobAuditLogAddr,
+		}).SetupWithManager(m); err != nil {
+			entrypointLogger.Error(err, "unable to create runnable", "runnable", "JobAuditLogServer")
+			os.Exit(1)
+		}
+	}
+
+	if workflowJobWebhookAddr != "" {
+		if workflowJobWebhookSecret == "" {
+			entrypointLogger.Error(nil, "workflow job webhook secret is required to receive workflow_job events")
+			os.Exit(1)
+		}
+		if err := (&controllers.WorkflowJobWebhookReceiver{
+			Client:        runnerReconciler.Client,
+			Log:           ctrl.Log.WithName("controllers").WithName("WorkflowJobWebhookReceiver"),
+			WebhookSecret: workflowJobWebhookSecret,
+			BindAddress:   workflowJobWebhookAddr,
+		}).SetupWithManager(m); err != nil {
+			entrypointLogger.Error(err, "unable to create runnable", "runnable", "WorkflowJobWebhookReceiver")
+			os.Exit(1)
+		}
+	}
+
+	if !disableAutoUpgrade {
+		if err := (&controllers.RunnerVersionPoller{
+			Client:     runnerReconciler.Client,
+			Log:        ctrl.Log.WithName("controllers").WithName("RunnerVersionPoller"),
+			Reconciler: runnerReconciler,
+		}).SetupWithManager(m); err != nil {
+			entrypointLogger.Error(err, "unable to create runnable", "runnable", "RunnerVersionPoller")
+			os.Exit(1)
+		}
+	}
+
+	if err := (&controllers.RunnerClassReconciler{
+		Client:   m.GetClient(),
+		Scheme:   m.GetScheme(),
+		Log:      ctrl.Log.WithName("controllers").WithName("RunnerClass"),
+		Recorder: m.GetEventRecorderFor("github-actions-runner-controller"),
+	}).SetupWithManager(m); err != nil {
+		entrypointLogger.Error(err, "unable to create controller", "controller", "RunnerClass")
+		os.Exit(1)
+	}
+
+	if available, err := controllers.ScaledObjectAvailable(m.GetRESTMapper()); err != nil {
+		entrypointLogger.Error(err, "unable to check KEDA availability")
+		os.Exit(1)
+	} else if available {
+		if err := (&controllers.RunnerScalerReconciler{
+			Client:   m.GetClient(),
+			Scheme:   m.GetScheme(),
+			Log:      ctrl.Log.WithName("controllers").WithName("RunnerScaler"),
+			Recorder: m.GetEventRecorderFor("github-actions-runner-controller"),
+		}).SetupWithManager(m); err != nil {
+			entrypointLogger.Error(err, "unable to create controller", "controller", "RunnerScaler")
+			os.Exit(1)
+		}
+	} else {
+		entrypointLogger.Info("RunnerScaler is disabled since KEDA ScaledObject is not installed")
+	}
+
+	if certificateReloader != nil {
+		certificateReloader.Client = m.GetClient()
+		if err := certificateReloader.SetupWithManager(m); err != nil {
+			entrypointLogger.Error(err, "unable to create controller", "controller", "CertificateReloader")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhook {
+		if err := (&garV1.Runner{}).SetupWebhookWithManager(m, &garV1.RunnerValidator{
+			GitHubAppConfigured:             githubAppClientId != "" && (githubAppPrivateKey != "" || privateKeyProvider != nil || privateKeySecretRef.Name != ""),
+			GitHubAppInstallationConfigured: githubAppInstallationId != "" || discoverInstallationId,
+		}); err != nil {
+			entrypointLogger.Error(err, "unable to create webhook", "webhook", "Runner")
+			os.Exit(1)
+		}
+	}
+
 	if err := m.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		entrypointLogger.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -141,6 +520,14 @@ func main() {
 		entrypointLogger.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := m.AddHealthzCheck("reconcile", runnerReconciler.HealthzCheck); err != nil {
+		entrypointLogger.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := m.AddReadyzCheck("github-api", runnerReconciler.ReadyzCheck); err != nil {
+		entrypointLogger.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	entrypointLogger.Info("starting manager")
 	if err := m.Start(ctrl.SetupSignalHandler()); err != nil {